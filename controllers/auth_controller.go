@@ -51,6 +51,7 @@ func Register(c *gin.Context) {
 		Name:     adminReq.Name,
 		Email:    adminReq.Email,
 		Password: adminReq.Password,
+		Role:     models.AdminRoleAdmin,
 	}
 
 	// Hash the admin's password
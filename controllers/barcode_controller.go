@@ -0,0 +1,151 @@
+// controllers/barcode_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LookupVariant finds a variant of the admin's catalog by SKU or barcode.
+func LookupVariant(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	sku := strings.TrimSpace(c.Query("sku"))
+	barcode := strings.TrimSpace(c.Query("barcode"))
+	if (sku == "") == (barcode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either sku or barcode"})
+		return
+	}
+
+	query := utils.CatalogVariants(db, adminUUID).Select("variants.*").Preload("Attributes")
+	if sku != "" {
+		query = query.Where("variants.sku = ?", sku)
+	} else {
+		code, err := utils.NormalizeGTIN(barcode)
+		if err != nil || code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidBarcode.Error()})
+			return
+		}
+		query = utils.WhereBarcode(query, code)
+	}
+
+	var variant models.Variant
+	if err := query.First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Variant not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch variant"})
+		return
+	}
+
+	// Compute bundle availability
+	variants := []models.Variant{variant}
+	if err := utils.FillBundleAvailability(db, variants); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch bundle components"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"variant": variants[0]})
+}
+
+// GetVariantBarcode renders the barcode of a variant as a PNG or SVG label image.
+func GetVariantBarcode(c *gin.Context) {
+	existingVariant := c.MustGet("variant").(models.Variant)
+
+	format := c.DefaultQuery("format", utils.BarcodeFormatPNG)
+	if format != utils.BarcodeFormatPNG && format != utils.BarcodeFormatSVG {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be png or svg"})
+		return
+	}
+
+	width, err := strconv.Atoi(c.DefaultQuery("width", "300"))
+	if err != nil || width < 1 || width > 2000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Width must be between 1 and 2000"})
+		return
+	}
+	height, err := strconv.Atoi(c.DefaultQuery("height", "100"))
+	if err != nil || height < 1 || height > 2000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Height must be between 1 and 2000"})
+		return
+	}
+
+	// EAN-13 draws the barcode, Code128 the SKU or, without one, the barcode
+	symbology := c.Query("symbology")
+	if symbology == "" {
+		symbology = utils.BarcodeCode128
+		if len(existingVariant.Barcode) == 12 || len(existingVariant.Barcode) == 13 {
+			symbology = utils.BarcodeEAN13
+		}
+	}
+
+	value := existingVariant.Barcode
+	if symbology == utils.BarcodeCode128 && existingVariant.SKU != "" {
+		value = existingVariant.SKU
+	}
+	if value == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant has no SKU or barcode"})
+		return
+	}
+
+	label, contentType, err := utils.RenderBarcode(value, symbology, format, width, height)
+	if err != nil {
+		if errors.Is(err, utils.ErrBarcodeNotEncodable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to render barcode"})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, label)
+}
+
+// applyVariantIdentifiers sets the SKU and barcode given in a request on the variant, leaving
+// those not given unchanged, and checks they are valid and unused in the admin's catalog.
+// It writes an error response on failure.
+func applyVariantIdentifiers(c *gin.Context, db *gorm.DB, adminUUID uuid.UUID, variant *models.Variant, sku *string, barcode *string) bool {
+	if sku != nil {
+		normalized, err := utils.NormalizeSKU(*sku)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		variant.SKU = normalized
+	}
+
+	if barcode != nil {
+		normalized, err := utils.NormalizeGTIN(*barcode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		variant.Barcode = normalized
+	}
+
+	if err := utils.CheckVariantIdentifiers(db, adminUUID, *variant); err != nil {
+		if errors.Is(err, utils.ErrDuplicateSKU) || errors.Is(err, utils.ErrDuplicateBarcode) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to check SKU and barcode"})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,112 @@
+// controllers/bundle_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BundleComponentItem represents a component variant and its quantity in a bundle components request.
+type BundleComponentItem struct {
+	VariantUUID string `json:"variant_uuid" valid:"required,uuid"`
+	Quantity    uint   `json:"quantity" valid:"required"`
+}
+
+// BundleComponentsRequest represents the request body for setting the components of a bundle variant.
+type BundleComponentsRequest struct {
+	Components []BundleComponentItem `json:"components" binding:"required"`
+}
+
+// GetVariantComponents retrieves the components of a bundle variant and how many bundles are available.
+func GetVariantComponents(c *gin.Context) {
+	db := utils.GetDB()
+	existingVariant := c.MustGet("variant").(models.Variant)
+
+	variants := []models.Variant{existingVariant}
+	if err := utils.FillBundleAvailability(db, variants); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch components"})
+		return
+	}
+
+	respondVariantComponents(c, db, variants[0])
+}
+
+// SetVariantComponents replaces the components of a bundle variant.
+func SetVariantComponents(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+	existingVariant := c.MustGet("variant").(models.Variant)
+
+	var componentsReq BundleComponentsRequest
+	if err := c.ShouldBindJSON(&componentsReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := govalidator.ValidateStruct(componentsReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items := make([]utils.BundleItem, 0, len(componentsReq.Components))
+	for _, component := range componentsReq.Components {
+		items = append(items, utils.BundleItem{VariantUUID: component.VariantUUID, Quantity: component.Quantity})
+	}
+
+	if _, err := utils.SetBundleComponents(db, adminUUID, existingVariant, items); err != nil {
+		respondBundleError(c, err, "Failed to set components")
+		return
+	}
+
+	variants := []models.Variant{existingVariant}
+	if err := utils.FillBundleAvailability(db, variants); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch components"})
+		return
+	}
+
+	respondVariantComponents(c, db, variants[0])
+}
+
+// respondVariantComponents writes the components of a bundle variant with their variants.
+func respondVariantComponents(c *gin.Context, db *gorm.DB, variant models.Variant) {
+	componentUUIDs := make([]string, 0, len(variant.Components))
+	for _, component := range variant.Components {
+		componentUUIDs = append(componentUUIDs, component.ComponentVariantUUID.String())
+	}
+
+	componentVariants := []models.Variant{}
+	if len(componentUUIDs) > 0 {
+		if err := db.Where("uuid IN ?", componentUUIDs).Find(&componentVariants).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch components"})
+			return
+		}
+	}
+
+	components := variant.Components
+	if components == nil {
+		components = []models.BundleComponent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"components": components, "variants": componentVariants, "available": variant.Quantity})
+}
+
+// respondBundleError maps bundle errors to HTTP responses.
+func respondBundleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, utils.ErrNotBundle), errors.Is(err, utils.ErrInvalidComponent), errors.Is(err, utils.ErrComponentInUse), errors.Is(err, utils.ErrBundleHasComponents):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": message})
+	default:
+		respondOrderError(c, err, message)
+	}
+}
@@ -0,0 +1,458 @@
+// controllers/cart_controller.go
+
+package controllers
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CartCreateRequest represents the request body for creating a new cart.
+type CartCreateRequest struct {
+	CustomerEmail string `form:"customer_email" json:"customer_email" valid:"email"`
+}
+
+// CartLineRequest represents the request body for adding a variant to a cart.
+type CartLineRequest struct {
+	VariantUUID string `form:"variant_uuid" json:"variant_uuid" valid:"required,uuid"`
+	Quantity    uint   `form:"quantity" json:"quantity" valid:"required"`
+}
+
+// CartLineUpdateRequest represents the request body for changing the quantity of a cart line.
+type CartLineUpdateRequest struct {
+	Quantity uint `form:"quantity" json:"quantity" valid:"required"`
+}
+
+// CartLineResponse represents a cart line priced with the current variant price.
+type CartLineResponse struct {
+	VariantUUID string  `json:"variant_uuid"`
+	VariantName string  `json:"variant_name"`
+	Quantity    uint    `json:"quantity"`
+	Available   uint    `json:"available"`
+	InStock     bool    `json:"in_stock"`
+	UnitPrice   float64 `json:"unit_price"`
+	Total       float64 `json:"total"`
+}
+
+// CartResponse represents a cart with its lines and totals.
+type CartResponse struct {
+	Token         string             `json:"token"`
+	CustomerEmail string             `json:"customer_email,omitempty"`
+	ExpiresAt     time.Time          `json:"expires_at"`
+	OrderUUID     *uuid.UUID         `json:"order_uuid,omitempty"`
+	Lines         []CartLineResponse `json:"lines"`
+	ItemCount     uint               `json:"item_count"`
+	Subtotal      float64            `json:"subtotal"`
+	InStock       bool               `json:"in_stock"`
+}
+
+var (
+	errCartNotFound      = errors.New("Cart not found")
+	errCartExpired       = errors.New("Cart has expired")
+	errCartCheckedOut    = errors.New("Cart has already been checked out")
+	errCartEmpty         = errors.New("Cart is empty")
+	errCartMixedCatalogs = errors.New("A cart can only hold variants from one catalog")
+	errCartLineNotInCart = errors.New("Variant is not in the cart")
+)
+
+// CreateCart creates a new empty cart and returns its token.
+func CreateCart(c *gin.Context) {
+	db := utils.GetDB()
+	contentType := utils.GetContentType(c)
+
+	// The request body is optional for anonymous carts
+	var createReq CartCreateRequest
+	if c.Request.ContentLength != 0 {
+		var err error
+		if contentType == appJSON {
+			err = c.ShouldBindJSON(&createReq)
+		} else {
+			err = c.ShouldBind(&createReq)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if _, err := govalidator.ValidateStruct(createReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := utils.NewCartToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to generate cart token"})
+		return
+	}
+
+	newCart := models.Cart{
+		Token:         token,
+		CustomerEmail: createReq.CustomerEmail,
+		ExpiresAt:     time.Now().Add(helpers.EnvCartIdleTimeout()),
+	}
+
+	if err := db.Create(&newCart).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to create cart"})
+		return
+	}
+
+	respondCart(c, db, http.StatusCreated, newCart)
+}
+
+// GetCart retrieves a cart with its lines validated against current stock.
+func GetCart(c *gin.Context) {
+	db := utils.GetDB()
+
+	cart, err := touchCart(db, c.Param("cartToken"), false)
+	if err != nil {
+		respondCartError(c, err, "Failed to fetch cart")
+		return
+	}
+
+	respondCart(c, db, http.StatusOK, cart)
+}
+
+// AddCartLine adds a variant to a cart, or increases its quantity when it is already there.
+func AddCartLine(c *gin.Context) {
+	db := utils.GetDB()
+	contentType := utils.GetContentType(c)
+
+	var lineReq CartLineRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&lineReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		if err := c.ShouldBind(&lineReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if _, err := govalidator.ValidateStruct(lineReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var cart models.Cart
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		cart, err = touchCart(tx, c.Param("cartToken"), true)
+		if err != nil {
+			return err
+		}
+
+		quantity := lineReq.Quantity
+		var existingLine models.CartLine
+		lineErr := tx.Where("cart_uuid = ? AND variant_uuid = ?", cart.UUID, lineReq.VariantUUID).First(&existingLine).Error
+		if lineErr == nil {
+			quantity += existingLine.Quantity
+		} else if !errors.Is(lineErr, gorm.ErrRecordNotFound) {
+			return lineErr
+		}
+
+		if err := checkCartVariant(tx, &cart, lineReq.VariantUUID, quantity); err != nil {
+			return err
+		}
+
+		if lineErr == nil {
+			return tx.Model(&existingLine).Update("quantity", quantity).Error
+		}
+
+		newLine := models.CartLine{
+			CartUUID:    uuid.MustParse(cart.UUID),
+			VariantUUID: uuid.MustParse(lineReq.VariantUUID),
+			Quantity:    quantity,
+		}
+		return tx.Create(&newLine).Error
+	})
+	if err != nil {
+		respondCartError(c, err, "Failed to add variant to cart")
+		return
+	}
+
+	respondCart(c, db, http.StatusOK, cart)
+}
+
+// UpdateCartLine sets the quantity of a variant in a cart.
+func UpdateCartLine(c *gin.Context) {
+	db := utils.GetDB()
+	contentType := utils.GetContentType(c)
+
+	var updateReq CartLineUpdateRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&updateReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		if err := c.ShouldBind(&updateReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if _, err := govalidator.ValidateStruct(updateReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var cart models.Cart
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		cart, err = touchCart(tx, c.Param("cartToken"), true)
+		if err != nil {
+			return err
+		}
+
+		var existingLine models.CartLine
+		if err := tx.Where("cart_uuid = ? AND variant_uuid = ?", cart.UUID, c.Param("variantUUID")).First(&existingLine).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errCartLineNotInCart
+			}
+			return err
+		}
+
+		if err := checkCartVariant(tx, &cart, existingLine.VariantUUID.String(), updateReq.Quantity); err != nil {
+			return err
+		}
+
+		return tx.Model(&existingLine).Update("quantity", updateReq.Quantity).Error
+	})
+	if err != nil {
+		respondCartError(c, err, "Failed to update cart line")
+		return
+	}
+
+	respondCart(c, db, http.StatusOK, cart)
+}
+
+// DeleteCartLine removes a variant from a cart.
+func DeleteCartLine(c *gin.Context) {
+	db := utils.GetDB()
+
+	var cart models.Cart
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		cart, err = touchCart(tx, c.Param("cartToken"), true)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("cart_uuid = ? AND variant_uuid = ?", cart.UUID, c.Param("variantUUID")).Delete(&models.CartLine{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errCartLineNotInCart
+		}
+		return nil
+	})
+	if err != nil {
+		respondCartError(c, err, "Failed to remove variant from cart")
+		return
+	}
+
+	respondCart(c, db, http.StatusOK, cart)
+}
+
+// CheckoutCart converts a cart into a pending order, decrementing stock in the same transaction.
+func CheckoutCart(c *gin.Context) {
+	db := utils.GetDB()
+
+	var newOrder models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		cart, err := touchCart(tx, c.Param("cartToken"), true)
+		if err != nil {
+			return err
+		}
+
+		if len(cart.Lines) == 0 || cart.AdminUUID == nil {
+			return errCartEmpty
+		}
+
+		items := make([]utils.OrderItem, 0, len(cart.Lines))
+		for _, line := range cart.Lines {
+			items = append(items, utils.OrderItem{VariantUUID: line.VariantUUID.String(), Quantity: line.Quantity})
+		}
+
+		newOrder, err = utils.PlaceOrderTx(tx, *cart.AdminUUID, items)
+		if err != nil {
+			return err
+		}
+
+		orderUUID := uuid.MustParse(newOrder.UUID)
+		return tx.Model(&cart).Update("order_uuid", orderUUID).Error
+	})
+	if err != nil {
+		respondCartError(c, err, "Failed to check out cart")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"order": newOrder})
+}
+
+// touchCart loads an active cart by token and extends its expiry. When lock is set the
+// cart row is locked for the rest of the transaction and a checked out cart is rejected.
+func touchCart(db *gorm.DB, token string, lock bool) (models.Cart, error) {
+	var cart models.Cart
+
+	query := db.Preload("Lines", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") })
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	if err := query.Where("token = ?", token).First(&cart).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cart, errCartNotFound
+		}
+		return cart, err
+	}
+
+	now := time.Now()
+	if !cart.ExpiresAt.After(now) {
+		return cart, errCartExpired
+	}
+	if lock && cart.OrderUUID != nil {
+		return cart, errCartCheckedOut
+	}
+
+	// Any activity restarts the idle period, except on checked out carts
+	if cart.OrderUUID == nil {
+		cart.ExpiresAt = now.Add(helpers.EnvCartIdleTimeout())
+		if err := db.Model(&cart).Update("expires_at", cart.ExpiresAt).Error; err != nil {
+			return cart, err
+		}
+	}
+
+	return cart, nil
+}
+
+// checkCartVariant checks that a variant can be put in the cart at the given quantity, and
+// ties the cart to the variant's catalog when it is the first one.
+func checkCartVariant(tx *gorm.DB, cart *models.Cart, variantUUID string, quantity uint) error {
+	var variant models.Variant
+	if err := tx.Where("uuid = ?", variantUUID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrVariantNotFound
+		}
+		return err
+	}
+
+	// Bundles are available as long as their components are
+	variants := []models.Variant{variant}
+	if err := utils.FillBundleAvailability(tx, variants); err != nil {
+		return err
+	}
+	variant = variants[0]
+
+	if variant.Quantity < quantity {
+		return &utils.StockError{VariantUUID: variantUUID, Requested: quantity, Available: variant.Quantity}
+	}
+
+	var product models.Product
+	if err := tx.Where("uuid = ?", variant.ProductUUID).First(&product).Error; err != nil {
+		return err
+	}
+
+	if product.Status != models.ProductStatusActive {
+		return utils.ErrProductUnavailable
+	}
+
+	if cart.AdminUUID == nil {
+		adminUUID := product.AdminUUID
+		cart.AdminUUID = &adminUUID
+		return tx.Model(cart).Update("admin_uuid", adminUUID).Error
+	}
+
+	if *cart.AdminUUID != product.AdminUUID {
+		return errCartMixedCatalogs
+	}
+
+	return nil
+}
+
+// respondCart writes the cart with its lines priced and checked against current stock.
+func respondCart(c *gin.Context, db *gorm.DB, status int, cart models.Cart) {
+	var lines []models.CartLine
+	if err := db.Where("cart_uuid = ?", cart.UUID).Order("id").Find(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch cart lines"})
+		return
+	}
+
+	variantUUIDs := make([]string, 0, len(lines))
+	for _, line := range lines {
+		variantUUIDs = append(variantUUIDs, line.VariantUUID.String())
+	}
+
+	var variants []models.Variant
+	if len(variantUUIDs) > 0 {
+		if err := db.Where("uuid IN ?", variantUUIDs).Find(&variants).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch variants"})
+			return
+		}
+		if err := utils.FillBundleAvailability(db, variants); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch bundle components"})
+			return
+		}
+	}
+
+	variantsByUUID := make(map[string]models.Variant, len(variants))
+	for _, variant := range variants {
+		variantsByUUID[variant.UUID] = variant
+	}
+
+	currency := helpers.EnvBaseCurrency()
+	response := CartResponse{
+		Token:         cart.Token,
+		CustomerEmail: cart.CustomerEmail,
+		ExpiresAt:     cart.ExpiresAt,
+		OrderUUID:     cart.OrderUUID,
+		Lines:         make([]CartLineResponse, 0, len(lines)),
+		InStock:       true,
+	}
+
+	for _, line := range lines {
+		// A variant deleted after it was added shows as out of stock
+		variant := variantsByUUID[line.VariantUUID.String()]
+		lineResponse := CartLineResponse{
+			VariantUUID: line.VariantUUID.String(),
+			VariantName: variant.VariantName,
+			Quantity:    line.Quantity,
+			Available:   variant.Quantity,
+			InStock:     variant.UUID != "" && variant.Quantity >= line.Quantity,
+			UnitPrice:   variant.Price,
+			Total:       utils.RoundPrice(variant.Price*float64(line.Quantity), currency),
+		}
+
+		response.Lines = append(response.Lines, lineResponse)
+		response.ItemCount += line.Quantity
+		response.Subtotal += lineResponse.Total
+		response.InStock = response.InStock && lineResponse.InStock
+	}
+	response.Subtotal = utils.RoundPrice(response.Subtotal, currency)
+
+	c.JSON(status, gin.H{"cart": response})
+}
+
+// respondCartError maps cart errors to HTTP responses.
+func respondCartError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, errCartNotFound), errors.Is(err, errCartLineNotInCart):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, errCartExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, errCartCheckedOut), errors.Is(err, errCartMixedCatalogs), errors.Is(err, errCartEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "messages": message})
+	default:
+		respondOrderError(c, err, message)
+	}
+}
@@ -0,0 +1,294 @@
+// controllers/category_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CategoryRequest represents the request body for creating or updating a category.
+type CategoryRequest struct {
+	Name       string `form:"name" json:"name" valid:"required"`
+	ParentUUID string `form:"parent_uuid" json:"parent_uuid" valid:"uuid"`
+}
+
+// ProductCategoriesRequest represents the request body for assigning categories to a product.
+type ProductCategoriesRequest struct {
+	CategoryUUIDs []string `form:"category_uuids" json:"category_uuids" binding:"required,min=1,dive,uuid"`
+}
+
+// GetAllCategories retrieves the admin's categories as a flat list, or nested when tree=true.
+func GetAllCategories(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var categories []models.Category
+	if err := db.Where("admin_uuid = ?", adminUUID).Order("path").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch categories"})
+		return
+	}
+
+	if c.Query("tree") == "true" {
+		c.JSON(http.StatusOK, gin.H{"categories": utils.BuildCategoryTree(categories)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// CreateCategory creates a new category, under a parent when parent_uuid is given.
+func CreateCategory(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	categoryReq, ok := bindCategoryRequest(c)
+	if !ok {
+		return
+	}
+
+	newCategory, err := utils.CreateCategory(db, adminUUID, categoryReq.Name, categoryReq.ParentUUID)
+	if err != nil {
+		respondCategoryError(c, err, "Failed to create category")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"category": newCategory})
+}
+
+// GetCategoryDetail retrieves a category with its ancestors and direct children.
+func GetCategoryDetail(c *gin.Context) {
+	db := utils.GetDB()
+	existingCategory := c.MustGet("category").(models.Category)
+
+	ancestors := []models.Category{}
+	ancestorUUIDs := utils.CategoryPathUUIDs(existingCategory.Path)
+	if len(ancestorUUIDs) > 1 {
+		if err := db.Where("uuid IN ?", ancestorUUIDs[:len(ancestorUUIDs)-1]).Order("depth").Find(&ancestors).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch ancestors"})
+			return
+		}
+	}
+
+	children := []models.Category{}
+	if err := db.Where("parent_uuid = ?", existingCategory.UUID).Order("name").Find(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch children"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"category": existingCategory, "ancestors": ancestors, "children": children})
+}
+
+// UpdateCategory renames a category and moves it, with its descendants, under a new parent,
+// with a product.updated event for each product of the category and its descendants.
+func UpdateCategory(c *gin.Context) {
+	db := utils.GetDB()
+	existingCategory := c.MustGet("category").(models.Category)
+
+	categoryReq, ok := bindCategoryRequest(c)
+	if !ok {
+		return
+	}
+
+	currentParent := ""
+	if existingCategory.ParentUUID != nil {
+		currentParent = existingCategory.ParentUUID.String()
+	}
+	if categoryReq.ParentUUID == currentParent && categoryReq.Name == existingCategory.Name {
+		c.JSON(http.StatusOK, gin.H{"category": existingCategory})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if categoryReq.ParentUUID != currentParent {
+			movedCategory, err := utils.MoveCategory(tx, existingCategory, categoryReq.ParentUUID)
+			if err != nil {
+				return err
+			}
+			existingCategory = movedCategory
+		}
+
+		existingCategory.Name = categoryReq.Name
+		if err := tx.Model(&existingCategory).Update("name", existingCategory.Name).Error; err != nil {
+			return err
+		}
+
+		productUUIDs, err := utils.CategoryProductUUIDs(tx, existingCategory)
+		if err != nil {
+			return err
+		}
+		return utils.RecordProductsUpdated(tx, productUUIDs)
+	})
+	if err != nil {
+		respondCategoryError(c, err, "Failed to update category")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"category": existingCategory})
+}
+
+// DeleteCategory deletes a category without children and unassigns it from its products, with
+// a product.updated event for each of them.
+func DeleteCategory(c *gin.Context) {
+	db := utils.GetDB()
+	existingCategory := c.MustGet("category").(models.Category)
+
+	// Check if the category has children
+	var children int64
+	if err := db.Model(&models.Category{}).Where("parent_uuid = ?", existingCategory.UUID).Count(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch children"})
+		return
+	}
+	if children > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete category with child categories", "messages": "Please move or delete the child categories first"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		productUUIDs, err := utils.CategoryProductUUIDs(tx, existingCategory)
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("product_categories").Where("category_uuid = ?", existingCategory.UUID).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&existingCategory).Error; err != nil {
+			return err
+		}
+		return utils.RecordProductsUpdated(tx, productUUIDs)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to delete category"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// AddProductCategories assigns categories to a product.
+func AddProductCategories(c *gin.Context) {
+	db := utils.GetDB()
+	existingProduct := c.MustGet("product").(models.Product)
+
+	var categoriesReq ProductCategoriesRequest
+	if err := c.ShouldBind(&categoriesReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories := make([]models.Category, 0, len(categoriesReq.CategoryUUIDs))
+	for _, categoryUUID := range categoriesReq.CategoryUUIDs {
+		category, err := utils.FindCatalogCategory(db, existingProduct.AdminUUID, categoryUUID)
+		if err != nil {
+			respondCategoryError(c, err, "Failed to assign categories")
+			return
+		}
+		categories = append(categories, category)
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existingProduct).Association("Categories").Append(categories); err != nil {
+			return err
+		}
+		return utils.RecordProductEvent(tx, models.EventProductUpdated, existingProduct)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to assign categories"})
+		return
+	}
+
+	respondProductCategories(c, db, existingProduct)
+}
+
+// RemoveProductCategory unassigns a category from a product.
+func RemoveProductCategory(c *gin.Context) {
+	db := utils.GetDB()
+	existingProduct := c.MustGet("product").(models.Product)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Table("product_categories").
+			Where("product_uuid = ? AND category_uuid = ?", existingProduct.UUID, c.Param("categoryUUID")).
+			Delete(nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return utils.RecordProductEvent(tx, models.EventProductUpdated, existingProduct)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category is not assigned to the product"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to remove category"})
+		return
+	}
+
+	respondProductCategories(c, db, existingProduct)
+}
+
+// respondProductCategories writes the categories currently assigned to the product.
+func respondProductCategories(c *gin.Context, db *gorm.DB, product models.Product) {
+	categories := []models.Category{}
+	if err := db.Model(&product).Association("Categories").Find(&categories); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// bindCategoryRequest parses and validates a category request, writing an error response on failure.
+func bindCategoryRequest(c *gin.Context) (CategoryRequest, bool) {
+	contentType := utils.GetContentType(c)
+
+	var categoryReq CategoryRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&categoryReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return categoryReq, false
+		}
+	} else {
+		if err := c.ShouldBind(&categoryReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return categoryReq, false
+		}
+	}
+	if _, err := govalidator.ValidateStruct(categoryReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return categoryReq, false
+	}
+
+	return categoryReq, true
+}
+
+// respondCategoryError maps category errors to HTTP responses.
+func respondCategoryError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, utils.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrCategoryNotInCatalog):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrCategoryCycle), errors.Is(err, utils.ErrCategoryTooDeep):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": message})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": message})
+	}
+}
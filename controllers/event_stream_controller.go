@@ -0,0 +1,161 @@
+// controllers/event_stream_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamReplayBatch is how many missed events are loaded at a time when a stream resumes.
+	streamReplayBatch = 500
+	// streamHeartbeat is how often an idle stream sends a comment to keep the connection open.
+	streamHeartbeat = 25 * time.Second
+)
+
+// streamEvent is the data of an event sent on the event stream.
+type streamEvent struct {
+	ID          uint            `json:"id"`
+	Sequence    uint64          `json:"sequence"`
+	Type        string          `json:"type"`
+	ProductUUID string          `json:"product_uuid"`
+	VariantUUID string          `json:"variant_uuid,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// StreamEvents streams the events of the admin's catalog as Server-Sent Events, optionally
+// only the types listed in types. A client reconnecting with the Last-Event-ID header, or
+// lastEventId, first receives the events it missed.
+func StreamEvents(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	// Parse the event types to stream, every type by default
+	var types []string
+	for _, eventType := range strings.Split(c.Query("types"), ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			types = append(types, eventType)
+		}
+	}
+	if len(types) == 0 {
+		types = models.EventTypes
+	}
+	if err := utils.ValidateEventTypes(types); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Parse the last event the client received, if it is resuming
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	// Receive new events before loading the missed ones, so none falls in between
+	stream := utils.OpenEventStream(adminUUID)
+	defer utils.CloseEventStream(stream)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	// Send the events missed since the last one
+	if lastEventID != "" {
+		for {
+			events, err := utils.EventsSince(db, adminUUID, lastID, streamReplayBatch)
+			if err != nil {
+				log.Println("Failed to load missed events:", err)
+				return
+			}
+			for _, event := range events {
+				lastID = *event.DispatchSeq
+				if containsEventType(types, event.Type) {
+					writeStreamEvent(c, event)
+				}
+			}
+			c.Writer.Flush()
+			if len(events) < streamReplayBatch {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-stream.Events:
+			// A stream that fell behind is closed; the client resumes from its last event
+			if !ok {
+				return
+			}
+			// Events are broadcast in dispatch order, so those already replayed come first
+			if *event.DispatchSeq <= lastID || !containsEventType(types, event.Type) {
+				continue
+			}
+			writeStreamEvent(c, event)
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeStreamEvent sends an event on the stream, with its dispatch sequence as the event ID.
+func writeStreamEvent(c *gin.Context, event models.OutboxEvent) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(*event.DispatchSeq, 10),
+		Event: event.Type,
+		Data: streamEvent{
+			ID:          event.ID,
+			Sequence:    *event.DispatchSeq,
+			Type:        event.Type,
+			ProductUUID: event.ProductUUID,
+			VariantUUID: event.VariantUUID,
+			CreatedAt:   event.CreatedAt,
+			Data:        json.RawMessage(event.Payload),
+		},
+	})
+}
+
+// containsEventType reports whether eventType is one of types.
+func containsEventType(types []string, eventType string) bool {
+	for _, t := range types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+// controllers/exchange_rate_controller.go
+
+package controllers
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"basictrade/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExchangeRateRequest represents the request body for creating or updating an exchange rate.
+type ExchangeRateRequest struct {
+	Currency string  `form:"currency" json:"currency" valid:"required"`
+	Rate     float64 `form:"rate" json:"rate" valid:"required"`
+}
+
+// ExchangeRateUploadRequest represents the multipart request for uploading exchange rates as CSV.
+type ExchangeRateUploadRequest struct {
+	File *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+// GetAllExchangeRates retrieves all stored exchange rates.
+func GetAllExchangeRates(c *gin.Context) {
+	db := utils.GetDB()
+
+	var rates []models.ExchangeRate
+	if err := db.Order("currency").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exchangeRates": rates, "baseCurrency": helpers.EnvBaseCurrency()})
+}
+
+// UpsertExchangeRate creates an exchange rate or replaces the rate of an existing currency.
+func UpsertExchangeRate(c *gin.Context) {
+	db := utils.GetDB()
+	contentType := utils.GetContentType(c)
+
+	var rateReq ExchangeRateRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&rateReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		if err := c.ShouldBind(&rateReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// The currency in the URL takes precedence over the one in the body
+	if currency := c.Param("currency"); currency != "" {
+		rateReq.Currency = currency
+	}
+
+	if _, err := govalidator.ValidateStruct(rateReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := newExchangeRate(rateReq.Currency, rateReq.Rate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := upsertExchangeRates(db, []models.ExchangeRate{rate}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to save exchange rate"})
+		return
+	}
+
+	// Reload the row so the response carries its UUID and timestamps
+	if err := db.Where("currency = ?", rate.Currency).First(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exchangeRate": rate})
+}
+
+// DeleteExchangeRate deletes the exchange rate of a currency.
+func DeleteExchangeRate(c *gin.Context) {
+	db := utils.GetDB()
+
+	currency, err := utils.NormalizeCurrency(c.Param("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existingRate models.ExchangeRate
+	if err := db.Where("currency = ?", currency).First(&existingRate).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Exchange rate not found"})
+		return
+	}
+
+	if err := db.Delete(&existingRate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to delete exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rate deleted successfully"})
+}
+
+// UploadExchangeRates creates or replaces exchange rates from a CSV file of currency,rate rows.
+func UploadExchangeRates(c *gin.Context) {
+	db := utils.GetDB()
+
+	var uploadReq ExchangeRateUploadRequest
+	if err := c.ShouldBind(&uploadReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "A CSV file is required"})
+		return
+	}
+
+	file, err := uploadReq.File.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	rates, rowErrors, err := parseExchangeRateCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Invalid CSV file"})
+		return
+	}
+
+	// Reject the whole file so a partial upload never leaves mixed rates behind
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rows in CSV file", "rows": rowErrors})
+		return
+	}
+
+	if len(rates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file contains no exchange rates"})
+		return
+	}
+
+	if err := upsertExchangeRates(db, rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to save exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rates uploaded successfully", "totalItems": len(rates)})
+}
+
+// newExchangeRate validates a currency and rate pair.
+func newExchangeRate(currency string, rate float64) (models.ExchangeRate, error) {
+	code, err := utils.NormalizeCurrency(currency)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+
+	if code == helpers.EnvBaseCurrency() {
+		return models.ExchangeRate{}, errors.New("The base currency always has a rate of 1")
+	}
+
+	if rate <= 0 {
+		return models.ExchangeRate{}, errors.New("Rate must be greater than zero")
+	}
+
+	return models.ExchangeRate{Currency: code, Rate: rate}, nil
+}
+
+// upsertExchangeRates saves the rates in one transaction, replacing rates of existing currencies.
+func upsertExchangeRates(db *gorm.DB, rates []models.ExchangeRate) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "currency"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+		}).Create(&rates).Error
+	})
+}
+
+// parseExchangeRateCSV reads currency,rate rows, skipping an optional header row.
+func parseExchangeRateCSV(reader io.Reader) ([]models.ExchangeRate, []string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rates []models.ExchangeRate
+	var rowErrors []string
+	seen := make(map[string]int)
+
+	for i, record := range records {
+		row := i + 1
+
+		if i == 0 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "currency") {
+			continue
+		}
+
+		if len(record) < 2 {
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: expected currency and rate columns", row))
+			continue
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: invalid rate %q", row, record[1]))
+			continue
+		}
+
+		rate, err := newExchangeRate(record[0], value)
+		if err != nil {
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: %s", row, err.Error()))
+			continue
+		}
+
+		if previous, ok := seen[rate.Currency]; ok {
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: duplicate currency %s (first seen on row %d)", row, rate.Currency, previous))
+			continue
+		}
+		seen[rate.Currency] = row
+
+		rates = append(rates, rate)
+	}
+
+	return rates, rowErrors, nil
+}
+
+// resolveCurrency reads the optional currency query parameter and writes an error response when it cannot be used.
+func resolveCurrency(c *gin.Context, db *gorm.DB) (*utils.CurrencyConversion, bool) {
+	currency := strings.TrimSpace(c.Query("currency"))
+	if currency == "" {
+		return nil, true
+	}
+
+	conversion, err := utils.GetCurrencyConversion(db, currency)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidCurrency):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, utils.ErrUnknownCurrency):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Exchange rate not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch exchange rate"})
+		}
+		return nil, false
+	}
+
+	return conversion, true
+}
+
+// convertVariantPrices converts the prices of the variants in place.
+func convertVariantPrices(variants []models.Variant, conversion *utils.CurrencyConversion) {
+	if conversion == nil {
+		return
+	}
+	for i := range variants {
+		variants[i].Price = conversion.Convert(variants[i].Price)
+	}
+}
@@ -0,0 +1,277 @@
+// controllers/export_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many rows are held in memory at a time while streaming an export.
+const exportBatchSize = 500
+
+// exportColumn is a column of an export and how to read it from a row.
+type exportColumn struct {
+	Name  string
+	Value func(row interface{}) interface{}
+}
+
+// exportBatch scans up to exportBatchSize rows of an export query and completes them with
+// what the columns need, returning no rows once the query is exhausted.
+type exportBatch func(rows *sql.Rows) ([]interface{}, error)
+
+// variantExportRow is a variant with the name of its product.
+type variantExportRow struct {
+	Variant     models.Variant
+	ProductName string
+}
+
+// productExportColumns are the columns of product exports, in their default order.
+var productExportColumns = []exportColumn{
+	{"uuid", func(row interface{}) interface{} { return row.(*models.Product).UUID }},
+	{"product_name", func(row interface{}) interface{} { return row.(*models.Product).ProductName }},
+	{"description", func(row interface{}) interface{} { return row.(*models.Product).Description }},
+	{"status", func(row interface{}) interface{} { return row.(*models.Product).Status }},
+	{"type", func(row interface{}) interface{} { return row.(*models.Product).Type }},
+	{"image_url", func(row interface{}) interface{} { return row.(*models.Product).ImageURL }},
+	{"tags", func(row interface{}) interface{} {
+		tags := row.(*models.Product).Tags
+		names := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		return strings.Join(names, ",")
+	}},
+	{"published_at", func(row interface{}) interface{} { return row.(*models.Product).PublishedAt }},
+	{"created_at", func(row interface{}) interface{} { return row.(*models.Product).CreatedAt }},
+	{"updated_at", func(row interface{}) interface{} { return row.(*models.Product).UpdatedAt }},
+}
+
+// variantExportColumns are the columns of variant exports, in their default order.
+var variantExportColumns = []exportColumn{
+	{"uuid", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.UUID }},
+	{"product_uuid", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.ProductUUID.String() }},
+	{"product_name", func(row interface{}) interface{} { return row.(*variantExportRow).ProductName }},
+	{"variant_name", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.VariantName }},
+	{"sku", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.SKU }},
+	{"barcode", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.Barcode }},
+	{"quantity", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.Quantity }},
+	{"price", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.Price }},
+	{"attributes", func(row interface{}) interface{} {
+		attributes := row.(*variantExportRow).Variant.Attributes
+		pairs := make([]string, 0, len(attributes))
+		for _, attribute := range attributes {
+			pairs = append(pairs, attribute.Name+":"+attribute.Value)
+		}
+		return strings.Join(pairs, ",")
+	}},
+	{"created_at", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.CreatedAt }},
+	{"updated_at", func(row interface{}) interface{} { return row.(*variantExportRow).Variant.UpdatedAt }},
+}
+
+// ExportProducts streams the admin's products matching the filters of GET /products.
+func ExportProducts(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	query, ok := filterProducts(c, db, db.Model(&models.Product{}).Where("admin_uuid = ?", adminUUID))
+	if !ok {
+		return
+	}
+
+	streamExport(c, query, utils.ProductListSpec, "products", productExportColumns, func(rows *sql.Rows) ([]interface{}, error) {
+		var products []models.Product
+		for len(products) < exportBatchSize && rows.Next() {
+			var product models.Product
+			if err := db.ScanRows(rows, &product); err != nil {
+				return nil, err
+			}
+			products = append(products, product)
+		}
+		if len(products) == 0 {
+			return nil, rows.Err()
+		}
+
+		// Load the tags of the batch
+		uuids := make([]string, len(products))
+		for i, product := range products {
+			uuids[i] = product.UUID
+		}
+		var tagged []models.Product
+		if err := db.Preload("Tags").Select("id", "uuid").Where("uuid IN ?", uuids).Find(&tagged).Error; err != nil {
+			return nil, err
+		}
+		tags := make(map[string][]models.Tag, len(tagged))
+		for _, product := range tagged {
+			tags[product.UUID] = product.Tags
+		}
+
+		batch := make([]interface{}, len(products))
+		for i := range products {
+			products[i].Tags = tags[products[i].UUID]
+			batch[i] = &products[i]
+		}
+		return batch, nil
+	})
+}
+
+// ExportVariants streams the admin's variants matching the filters of GET /products/variants.
+func ExportVariants(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	catalog := db.Model(&models.Product{}).Select("uuid").Where("admin_uuid = ?", adminUUID)
+	query, ok := filterVariants(c, db, db.Model(&models.Variant{}).Where("product_uuid IN (?)", catalog))
+	if !ok {
+		return
+	}
+
+	streamExport(c, query, utils.VariantListSpec, "variants", variantExportColumns, func(rows *sql.Rows) ([]interface{}, error) {
+		var variants []models.Variant
+		for len(variants) < exportBatchSize && rows.Next() {
+			var variant models.Variant
+			if err := db.ScanRows(rows, &variant); err != nil {
+				return nil, err
+			}
+			variants = append(variants, variant)
+		}
+		if len(variants) == 0 {
+			return nil, rows.Err()
+		}
+
+		// Compute bundle availability, and load the attributes and product names of the batch
+		if err := utils.FillBundleAvailability(db, variants); err != nil {
+			return nil, err
+		}
+
+		variantUUIDs := make([]string, len(variants))
+		productUUIDs := make([]string, len(variants))
+		for i, variant := range variants {
+			variantUUIDs[i] = variant.UUID
+			productUUIDs[i] = variant.ProductUUID.String()
+		}
+
+		var attributes []models.VariantAttribute
+		if err := db.Where("variant_uuid IN ?", variantUUIDs).Order("id").Find(&attributes).Error; err != nil {
+			return nil, err
+		}
+		attributesByVariant := make(map[string][]models.VariantAttribute)
+		for _, attribute := range attributes {
+			variantUUID := attribute.VariantUUID.String()
+			attributesByVariant[variantUUID] = append(attributesByVariant[variantUUID], attribute)
+		}
+
+		var products []models.Product
+		if err := db.Select("uuid", "product_name").Where("uuid IN ?", productUUIDs).Find(&products).Error; err != nil {
+			return nil, err
+		}
+		productNames := make(map[string]string, len(products))
+		for _, product := range products {
+			productNames[product.UUID] = product.ProductName
+		}
+
+		batch := make([]interface{}, len(variants))
+		for i, variant := range variants {
+			variant.Attributes = attributesByVariant[variant.UUID]
+			batch[i] = &variantExportRow{Variant: variant, ProductName: productNames[variant.ProductUUID.String()]}
+		}
+		return batch, nil
+	})
+}
+
+// streamExport writes the rows of query in the requested format and columns, reading and
+// writing a batch at a time. Once the first rows are sent the status can no longer change,
+// so later errors are logged and cut the export short.
+func streamExport(c *gin.Context, query *gorm.DB, spec utils.ListSpec, name string, available []exportColumn, nextBatch exportBatch) {
+	format := c.DefaultQuery("format", utils.ExportFormatCSV)
+	contentType, extension, err := utils.ExportContentType(format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	names := make([]string, len(available))
+	columnsByName := make(map[string]exportColumn, len(available))
+	for i, column := range available {
+		names[i] = column.Name
+		columnsByName[column.Name] = column
+	}
+	selected, err := utils.SelectExportColumns(c.Query("columns"), names)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err = utils.ApplySort(query, spec, c.Query("sort"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to export " + name})
+		return
+	}
+	defer rows.Close()
+
+	fileName := name + "-" + time.Now().UTC().Format("20060102-150405") + "." + extension
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	c.Status(http.StatusOK)
+
+	writer, err := utils.NewExportWriter(format, c.Writer, selected)
+	if err != nil {
+		log.Println("Failed to export", name+":", err)
+		return
+	}
+
+	for {
+		batch, err := nextBatch(rows)
+		if err != nil {
+			log.Println("Failed to export", name+":", err)
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, row := range batch {
+			values := make([]interface{}, len(selected))
+			for i, column := range selected {
+				values[i] = columnsByName[column].Value(row)
+			}
+			if err := writer.WriteRow(values); err != nil {
+				log.Println("Failed to export", name+":", err)
+				return
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			log.Println("Failed to export", name+":", err)
+			return
+		}
+		c.Writer.Flush()
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Println("Failed to export", name+":", err)
+	}
+}
@@ -0,0 +1,575 @@
+// controllers/import_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxImportFileSize is the largest import file accepted, in bytes.
+const maxImportFileSize = 10 << 20
+
+// errDryRun rolls back the transaction of a row of a dry run once it was checked.
+var errDryRun = errors.New("dry run")
+
+// importJobPayload is the payload of a job running an import.
+type importJobPayload struct {
+	ImportUUID string `json:"import_uuid"`
+}
+
+// ImportCreateRequest represents the multipart request for importing products and variants.
+type ImportCreateRequest struct {
+	File    *multipart.FileHeader `form:"file" binding:"required"`
+	Mapping string                `form:"mapping"`
+	DryRun  bool                  `form:"dry_run"`
+}
+
+// GetAllImports retrieves the admin's imports, newest first.
+func GetAllImports(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "5"))
+	if page < 1 || pageSize < 1 || pageSize > utils.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidPage.Error()})
+		return
+	}
+
+	query := db.Model(&models.Import{}).Where("admin_uuid = ?", adminUUID)
+
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
+		return
+	}
+
+	var imports []models.Import
+	if err := query.Omit("data").Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&imports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch imports"})
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{"imports": imports, "totalItems": totalItems, "totalPages": totalPages})
+}
+
+// CreateImport stores an uploaded CSV or XLSX file of products and variants and processes it
+// in the background.
+func CreateImport(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var importReq ImportCreateRequest
+	if err := c.ShouldBind(&importReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "A CSV or XLSX file is required"})
+		return
+	}
+	if importReq.File.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import files must be at most 10 MB"})
+		return
+	}
+
+	format, err := utils.ImportFormat(importReq.File.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mapping, err := utils.ParseImportMapping(importReq.Mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := importReq.File.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Failed to read file"})
+		return
+	}
+
+	// Check the headers now so a wrong mapping is reported before anything is queued
+	if _, err := utils.ReadImportRows(data, format, mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newImport := models.Import{
+		FileName:  importReq.File.Filename,
+		Format:    format,
+		Data:      data,
+		Mapping:   importReq.Mapping,
+		DryRun:    importReq.DryRun,
+		Status:    models.ImportStatusPending,
+		AdminUUID: adminUUID,
+	}
+	var importJob models.Job
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newImport).Error; err != nil {
+			return err
+		}
+		importJob, err = utils.EnqueueJob(tx, models.JobRunImport, &adminUUID, importJobPayload{ImportUUID: newImport.UUID}, time.Time{})
+		return err
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to create import"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"import": newImport, "job": importJob})
+}
+
+// GetImportDetail retrieves the progress and counts of an import.
+func GetImportDetail(c *gin.Context) {
+	existingImport := c.MustGet("import").(models.Import)
+
+	c.JSON(http.StatusOK, gin.H{"import": existingImport})
+}
+
+// GetImportErrors downloads the problems found in the rows of an import as CSV, one per line.
+func GetImportErrors(c *gin.Context) {
+	db := utils.GetDB()
+	existingImport := c.MustGet("import").(models.Import)
+
+	var importErrors []models.ImportError
+	if err := db.Where("import_uuid = ?", existingImport.UUID).Order("file_row, id").Find(&importErrors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch import errors"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="import-`+existingImport.UUID+`-errors.csv"`)
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"row", "field", "message"})
+	for _, importError := range importErrors {
+		writer.Write([]string{strconv.Itoa(importError.Row), importError.Field, importError.Message})
+	}
+	writer.Flush()
+}
+
+// RegisterJobs registers the background jobs run by the controllers.
+func RegisterJobs() {
+	utils.RegisterJob(models.JobRunImport, 3, runImportJob)
+}
+
+// runImportJob runs an import as a background job. An import interrupted by a restart, or
+// retried after an error, resumes after the last row it processed.
+func runImportJob(ctx context.Context, db *gorm.DB, job models.Job) error {
+	var payload importJobPayload
+	if err := utils.DecodeJobPayload(job, &payload); err != nil {
+		return err
+	}
+	return RunImport(payload.ImportUUID)
+}
+
+// RunImport creates or updates the products and variants of the rows of an import, each row
+// in its own transaction, and records the problems of the rows that failed. Each row commits
+// with the progress of the import, so an import that is run again resumes after the last row
+// it processed instead of importing rows twice. A dry run checks every row the same way and
+// rolls each one back, saving only the progress and problems.
+func RunImport(importUUID string) error {
+	db := utils.GetDB()
+
+	var existingImport models.Import
+	if err := db.Where("uuid = ?", importUUID).First(&existingImport).Error; err != nil {
+		return err
+	}
+	if existingImport.Status == models.ImportStatusCompleted {
+		return nil
+	}
+
+	if err := db.Model(&existingImport).Update("status", models.ImportStatusProcessing).Error; err != nil {
+		return err
+	}
+
+	mapping, err := utils.ParseImportMapping(existingImport.Mapping)
+	if err != nil {
+		return finishImport(db, &existingImport, err)
+	}
+	rows, err := utils.ReadImportRows(existingImport.Data, existingImport.Format, mapping)
+	if err != nil {
+		return finishImport(db, &existingImport, err)
+	}
+
+	existingImport.TotalRows = len(rows)
+	if existingImport.ProcessedRows > len(rows) {
+		existingImport.ProcessedRows = len(rows)
+	}
+
+	for _, row := range rows[existingImport.ProcessedRows:] {
+		var progress models.Import
+		if existingImport.DryRun {
+			var created bool
+			var rowErrors []models.ImportError
+			err = db.Transaction(func(tx *gorm.DB) error {
+				created, rowErrors = importRow(tx, existingImport.AdminUUID, row)
+				return errDryRun
+			})
+			if errors.Is(err, errDryRun) {
+				progress, err = recordImportRow(db, existingImport, row, created, rowErrors)
+			}
+		} else {
+			err = db.Transaction(func(tx *gorm.DB) error {
+				created, rowErrors := importRow(tx, existingImport.AdminUUID, row)
+				var err error
+				progress, err = recordImportRow(tx, existingImport, row, created, rowErrors)
+				return err
+			})
+		}
+		if err != nil {
+			return finishImport(db, &existingImport, err)
+		}
+		existingImport = progress
+	}
+
+	return finishImport(db, &existingImport, nil)
+}
+
+// recordImportRow saves the problems of a processed row and the progress of the import, and
+// returns the import with its counts updated.
+func recordImportRow(db *gorm.DB, existingImport models.Import, row utils.ImportRow, created bool, rowErrors []models.ImportError) (models.Import, error) {
+	switch {
+	case len(rowErrors) > 0:
+		existingImport.FailedRows++
+		for i := range rowErrors {
+			rowErrors[i].ImportUUID = uuid.MustParse(existingImport.UUID)
+			rowErrors[i].Row = row.Number
+		}
+		if err := db.Create(&rowErrors).Error; err != nil {
+			return existingImport, err
+		}
+	case created:
+		existingImport.CreatedRows++
+	default:
+		existingImport.UpdatedRows++
+	}
+	existingImport.ProcessedRows++
+
+	err := db.Model(&existingImport).Select("total_rows", "processed_rows", "created_rows", "updated_rows", "failed_rows").
+		Updates(&existingImport).Error
+	return existingImport, err
+}
+
+// finishImport saves the counts of an import and marks it completed, or failed with the error
+// that stopped it.
+func finishImport(db *gorm.DB, existingImport *models.Import, importErr error) error {
+	now := time.Now()
+	existingImport.Status = models.ImportStatusCompleted
+	existingImport.Error = ""
+	existingImport.CompletedAt = &now
+	if importErr != nil {
+		existingImport.Status = models.ImportStatusFailed
+		existingImport.Error = importErr.Error()
+	}
+
+	return db.Model(existingImport).Select("status", "error", "total_rows", "processed_rows", "created_rows", "updated_rows", "failed_rows", "completed_at").
+		Updates(existingImport).Error
+}
+
+// importRow creates or updates the variant of an import row and its product, checking the
+// values with the rules of the product and variant requests. The variant is found by
+// variant_uuid, then by sku; the product by product_uuid, then as the variant's product, then
+// by product_name. Empty cells keep the current values. The changes are written to the outbox
+// with the row. It returns whether the variant was created and the problems found.
+func importRow(db *gorm.DB, adminUUID uuid.UUID, row utils.ImportRow) (bool, []models.ImportError) {
+	fields := row.Fields
+
+	// Find the variant being updated, if any
+	var existingVariant models.Variant
+	variantFound := false
+	if variantUUID := fields["variant_uuid"]; variantUUID != "" {
+		if err := utils.CatalogVariants(db, adminUUID).Select("variants.*").Where("variants.uuid = ?", variantUUID).First(&existingVariant).Error; err != nil {
+			return false, importRowError("variant_uuid", err, "Variant not found")
+		}
+		variantFound = true
+	} else if sku := fields["sku"]; sku != "" {
+		err := utils.CatalogVariants(db, adminUUID).Select("variants.*").Where("variants.sku = ?", sku).First(&existingVariant).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, importRowError("sku", err, "")
+		}
+		variantFound = err == nil
+	}
+
+	// Find the product the variant belongs to, if it exists
+	var existingProduct models.Product
+	productFound := false
+	switch {
+	case fields["product_uuid"] != "":
+		if err := db.Where("uuid = ? AND admin_uuid = ?", fields["product_uuid"], adminUUID).First(&existingProduct).Error; err != nil {
+			return false, importRowError("product_uuid", err, "Product not found")
+		}
+		if variantFound && existingVariant.ProductUUID.String() != existingProduct.UUID {
+			return false, []models.ImportError{{Field: "product_uuid", Message: "The variant belongs to another product"}}
+		}
+		productFound = true
+	case variantFound:
+		if err := db.Where("uuid = ?", existingVariant.ProductUUID).First(&existingProduct).Error; err != nil {
+			return false, importRowError("product_uuid", err, "")
+		}
+		productFound = true
+	case fields["product_name"] != "":
+		err := db.Where("admin_uuid = ? AND product_name = ?", adminUUID, fields["product_name"]).Order("id").First(&existingProduct).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, importRowError("product_name", err, "")
+		}
+		productFound = err == nil
+	}
+
+	// Merge the row into the current values and check them as the API would
+	var rowErrors []models.ImportError
+
+	productReq := ProductUpdateRequest{
+		ProductName: existingProduct.ProductName,
+		ImageURL:    existingProduct.ImageURL,
+		Status:      fields["status"],
+		Type:        fields["type"],
+	}
+	if value := fields["product_name"]; value != "" {
+		productReq.ProductName = value
+	}
+	if value := fields["image_url"]; value != "" {
+		productReq.ImageURL = value
+	}
+	if value := fields["description"]; value != "" {
+		productReq.Description = &value
+	}
+	if value := fields["published_at"]; value != "" {
+		publishedAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportError{Field: "published_at", Message: "Must be an RFC 3339 time"})
+		} else {
+			productReq.PublishedAt = &publishedAt
+		}
+	}
+	if _, err := govalidator.ValidateStruct(productReq); err != nil {
+		rowErrors = append(rowErrors, validationErrors(err)...)
+	}
+
+	variantReq := CreateVariantRequest{
+		VariantName: existingVariant.VariantName,
+		Quantity:    &existingVariant.Quantity,
+		Price:       existingVariant.Price,
+	}
+	if value := fields["variant_name"]; value != "" {
+		variantReq.VariantName = value
+	}
+	if value := fields["quantity"]; value != "" {
+		quantity, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportError{Field: "quantity", Message: "Must be a whole number"})
+		} else {
+			rowQuantity := uint(quantity)
+			variantReq.Quantity = &rowQuantity
+		}
+	}
+	if value := fields["price"]; value != "" {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price < 0 {
+			rowErrors = append(rowErrors, models.ImportError{Field: "price", Message: "Must be a number that is not negative"})
+		} else {
+			variantReq.Price = price
+		}
+	}
+	if value := fields["sku"]; value != "" {
+		variantReq.SKU = &value
+	}
+	if value := fields["barcode"]; value != "" {
+		variantReq.Barcode = &value
+	}
+	if err := validateVariantRequest(variantReq); err != nil {
+		rowErrors = append(rowErrors, validationErrors(err)...)
+	}
+
+	if len(rowErrors) > 0 {
+		return false, rowErrors
+	}
+
+	// Apply the values, saving the row as a whole or not at all
+	err := db.Transaction(func(tx *gorm.DB) error {
+		product := existingProduct
+		if !productFound {
+			product = models.Product{Type: models.ProductTypeStandard, AdminUUID: adminUUID}
+		}
+		product.ProductName = productReq.ProductName
+		product.ImageURL = productReq.ImageURL
+		if productReq.Type != "" {
+			if err := utils.CheckBundleType(tx, product, productReq.Type); err != nil {
+				return rowError("type", err)
+			}
+			product.Type = productReq.Type
+		}
+		if productReq.Description != nil {
+			descriptionHTML, err := utils.RenderMarkdown(*productReq.Description)
+			if err != nil {
+				return rowError("description", err)
+			}
+			product.Description = *productReq.Description
+			product.DescriptionHTML = descriptionHTML
+		}
+		if err := utils.ApplyProductStatus(&product, productReq.Status, productReq.PublishedAt, time.Now()); err != nil {
+			return rowError("status", err)
+		}
+
+		productEvent := models.EventProductUpdated
+		if productFound {
+			if err := tx.Omit(clause.Associations).Save(&product).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+			productEvent = models.EventProductCreated
+		}
+		if err := utils.RecordProductEvent(tx, productEvent, product); err != nil {
+			return err
+		}
+
+		// Check the attributes against the product options
+		var options []models.ProductOption
+		if err := tx.Where("product_uuid = ?", product.UUID).Find(&options).Error; err != nil {
+			return err
+		}
+		attributes, err := utils.ValidateVariantAttributes(options, row.Attributes)
+		if err != nil {
+			return rowError("attributes", err)
+		}
+
+		variant := existingVariant
+		variant.VariantName = variantReq.VariantName
+		variant.Quantity = *variantReq.Quantity
+		variant.Price = variantReq.Price
+		variant.ProductUUID = uuid.MustParse(product.UUID)
+		if variantReq.SKU != nil {
+			if variant.SKU, err = utils.NormalizeSKU(*variantReq.SKU); err != nil {
+				return rowError("sku", err)
+			}
+		}
+		if variantReq.Barcode != nil {
+			if variant.Barcode, err = utils.NormalizeGTIN(*variantReq.Barcode); err != nil {
+				return rowError("barcode", err)
+			}
+		}
+		if err := utils.CheckVariantIdentifiers(tx, adminUUID, variant); err != nil {
+			return variantIdentifierRowError(err)
+		}
+
+		if !variantFound {
+			if err := utils.CreateVariantWithAttributes(tx, &variant, attributes); err != nil {
+				return variantIdentifierRowError(err)
+			}
+			return utils.RecordVariantEvent(tx, adminUUID, models.EventVariantCreated, variant)
+		}
+		if err := utils.SaveVariant(tx, &variant); err != nil {
+			return variantIdentifierRowError(err)
+		}
+		if err := utils.RecordStockChange(tx, variant, existingVariant.Quantity); err != nil {
+			return err
+		}
+		if len(row.Attributes) > 0 {
+			if err := utils.ReplaceVariantAttributes(tx, &variant, attributes); err != nil {
+				return err
+			}
+		}
+		return utils.RecordVariantEvent(tx, adminUUID, models.EventVariantUpdated, variant)
+	})
+	if err != nil {
+		var fieldErr *importFieldError
+		if errors.As(err, &fieldErr) {
+			return false, []models.ImportError{{Field: fieldErr.field, Message: fieldErr.err.Error()}}
+		}
+		return false, importRowError("", err, "")
+	}
+
+	return !variantFound, nil
+}
+
+// importFieldError is a problem with one field of an import row, rolling the row back.
+type importFieldError struct {
+	field string
+	err   error
+}
+
+func (e *importFieldError) Error() string {
+	return e.field + ": " + e.err.Error()
+}
+
+// rowError wraps a problem with a field of an import row.
+func rowError(field string, err error) error {
+	return &importFieldError{field: field, err: err}
+}
+
+// variantIdentifierRowError reports a SKU or barcode used by another variant as a problem
+// with that field of an import row.
+func variantIdentifierRowError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrDuplicateSKU):
+		return rowError("sku", err)
+	case errors.Is(err, utils.ErrDuplicateBarcode):
+		return rowError("barcode", err)
+	}
+	return err
+}
+
+// importRowError reports a failed lookup of an import row, using message for a missing record.
+func importRowError(field string, err error, message string) []models.ImportError {
+	if message != "" && errors.Is(err, gorm.ErrRecordNotFound) {
+		return []models.ImportError{{Field: field, Message: message}}
+	}
+	return []models.ImportError{{Field: field, Message: err.Error()}}
+}
+
+// validationErrors splits govalidator errors into one import error per field.
+func validationErrors(err error) []models.ImportError {
+	messages := govalidator.ErrorsByField(err)
+	fields := make([]string, 0, len(messages))
+	for field := range messages {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var importErrors []models.ImportError
+	for _, field := range fields {
+		importErrors = append(importErrors, models.ImportError{Field: field, Message: messages[field]})
+	}
+	if len(importErrors) == 0 {
+		importErrors = append(importErrors, models.ImportError{Message: err.Error()})
+	}
+	return importErrors
+}
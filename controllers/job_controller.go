@@ -0,0 +1,86 @@
+// controllers/job_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAllJobs retrieves the admin's background jobs, newest first, optionally filtered by
+// status and type.
+func GetAllJobs(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	status := strings.TrimSpace(c.Query("status"))
+	jobType := strings.TrimSpace(c.Query("type"))
+
+	if page < 1 || pageSize < 1 || pageSize > utils.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidPage.Error()})
+		return
+	}
+
+	query := db.Model(&models.Job{}).Where("admin_uuid = ?", adminUUID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
+		return
+	}
+
+	var jobs []models.Job
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch jobs"})
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "totalItems": totalItems, "totalPages": totalPages})
+}
+
+// GetJobDetail retrieves the status, attempts and last error of a background job.
+func GetJobDetail(c *gin.Context) {
+	existingJob := c.MustGet("job").(models.Job)
+
+	c.JSON(http.StatusOK, gin.H{"job": existingJob})
+}
+
+// RetryJob queues a failed background job again with a fresh set of attempts.
+func RetryJob(c *gin.Context) {
+	db := utils.GetDB()
+	existingJob := c.MustGet("job").(models.Job)
+
+	if existingJob.Status != models.JobStatusFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed jobs can be retried"})
+		return
+	}
+
+	if err := utils.RetryJob(db, &existingJob); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to retry job"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": existingJob})
+}
@@ -0,0 +1,91 @@
+// controllers/list_query.go
+
+package controllers
+
+import (
+	"basictrade/utils"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// applyListQuery counts the facets of a filtered list query listed in facets, none by
+// default, and parses the requested page. It writes an error response on failure.
+func applyListQuery(c *gin.Context, query *gorm.DB, spec utils.ListSpec) (map[string][]utils.FacetCount, utils.Pagination, bool) {
+	pagination, err := utils.ParsePagination(spec, c.Request.URL.Query(), 5)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, pagination, false
+	}
+
+	var facetNames []string
+	for _, name := range strings.Split(c.Query("facets"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			facetNames = append(facetNames, name)
+		}
+	}
+
+	facets, err := utils.CountFacets(query, spec, facetNames)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidFacet) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, pagination, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to count facets"})
+		return nil, pagination, false
+	}
+
+	return facets, pagination, true
+}
+
+// fetchListPage loads the requested page of query into items, a pointer to a slice of models,
+// and returns the pagination part of the response: the total counts unless count=false, the
+// cursors of the next and previous pages and links to them. It writes an error response on
+// failure.
+func fetchListPage(c *gin.Context, query *gorm.DB, pagination utils.Pagination, items interface{}) (gin.H, bool) {
+	response := gin.H{}
+
+	if pagination.Count {
+		var totalItems int64
+		if err := query.Count(&totalItems).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
+			return nil, false
+		}
+		response["totalItems"] = totalItems
+		response["totalPages"] = int(math.Ceil(float64(totalItems) / float64(pagination.PageSize)))
+	}
+
+	if err := pagination.Apply(query).Find(items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch items"})
+		return nil, false
+	}
+
+	info, err := pagination.Finish(query, items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to build page cursors"})
+		return nil, false
+	}
+
+	response["nextCursor"] = info.NextCursor
+	response["prevCursor"] = info.PrevCursor
+	response["links"] = gin.H{"next": pageLink(c, info.NextCursor), "prev": pageLink(c, info.PrevCursor)}
+
+	return response, true
+}
+
+// pageLink is the URL of the request moved to cursor, or nil without a cursor.
+func pageLink(c *gin.Context, cursor string) interface{} {
+	if cursor == "" {
+		return nil
+	}
+
+	values := c.Request.URL.Query()
+	values.Del("page")
+	values.Set("cursor", cursor)
+
+	return c.Request.URL.Path + "?" + values.Encode()
+}
@@ -0,0 +1,177 @@
+// controllers/order_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OrderLineRequest represents a variant and quantity in an order request.
+type OrderLineRequest struct {
+	VariantUUID string `json:"variant_uuid" valid:"required,uuid"`
+	Quantity    uint   `json:"quantity" valid:"required"`
+}
+
+// OrderCreateRequest represents the request body for creating a new order.
+type OrderCreateRequest struct {
+	Lines []OrderLineRequest `json:"lines" binding:"required,min=1"`
+}
+
+// OrderStatusRequest represents the request body for changing the status of an order.
+type OrderStatusRequest struct {
+	Status string `form:"status" json:"status" valid:"required,in(paid|shipped|cancelled)"`
+}
+
+// GetAllOrders retrieves the admin's orders with pagination and an optional status filter.
+func GetAllOrders(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "5"))
+	status := strings.TrimSpace(c.Query("status"))
+
+	if page < 1 || pageSize < 1 || pageSize > utils.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidPage.Error()})
+		return
+	}
+
+	// Pagination logic
+	offset := (page - 1) * pageSize
+
+	// Build the query
+	query := db.Model(&models.Order{}).Where("admin_uuid = ?", adminUUID)
+
+	// Apply status filter if provided
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// Fetch total count of orders
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
+		return
+	}
+
+	// Fetch orders with pagination
+	var orders []models.Order
+	if err := query.Preload("Lines").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	// Calculate total pages
+	totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{"orders": orders, "totalItems": totalItems, "totalPages": totalPages})
+}
+
+// CreateOrder creates a pending order and decrements the stock of its variants.
+func CreateOrder(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var createReq OrderCreateRequest
+	if err := c.ShouldBindJSON(&createReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := govalidator.ValidateStruct(createReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items := make([]utils.OrderItem, 0, len(createReq.Lines))
+	for _, line := range createReq.Lines {
+		items = append(items, utils.OrderItem{VariantUUID: line.VariantUUID, Quantity: line.Quantity})
+	}
+
+	newOrder, err := utils.PlaceOrder(db, adminUUID, items)
+	if err != nil {
+		respondOrderError(c, err, "Failed to create order")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"order": newOrder})
+}
+
+// GetOrderDetail retrieves the details of an order with its lines.
+func GetOrderDetail(c *gin.Context) {
+	db := utils.GetDB()
+	existingOrder := c.MustGet("order").(models.Order)
+
+	if err := db.Preload("Lines").Where("uuid = ?", existingOrder.UUID).First(&existingOrder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": existingOrder})
+}
+
+// UpdateOrderStatus moves an order through its status lifecycle.
+func UpdateOrderStatus(c *gin.Context) {
+	db := utils.GetDB()
+	existingOrder := c.MustGet("order").(models.Order)
+	contentType := utils.GetContentType(c)
+
+	var statusReq OrderStatusRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&statusReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		if err := c.ShouldBind(&statusReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if _, err := govalidator.ValidateStruct(statusReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedOrder, err := utils.TransitionOrder(db, existingOrder.UUID, statusReq.Status)
+	if err != nil {
+		respondOrderError(c, err, "Failed to update order status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": updatedOrder})
+}
+
+// respondOrderError maps order errors to HTTP responses.
+func respondOrderError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, utils.ErrInsufficientStock), errors.Is(err, utils.ErrInvalidOrderTransition), errors.Is(err, utils.ErrProductUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrVariantNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrVariantNotInCatalog):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "messages": message})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": message})
+	}
+}
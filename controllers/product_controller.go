@@ -5,24 +5,39 @@ package controllers
 import (
 	"basictrade/models"
 	"basictrade/utils"
-	"math"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"mime/multipart"
 
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gin-gonic/gin"
 	jwt5 "github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// ProductCreateRequest represents the request body for creating a new product.
-type ProductCreateRequest struct {
+// ProductUpdateRequest represents the request body for updating a product.
+type ProductUpdateRequest struct {
 	ProductName     string `form:"product_name" json:"product_name" valid:"required"`
 	ImageURL string `form:"image_url" json:"image_url"`
 	Image  *multipart.FileHeader `form:"file"`
+	Description *string    `form:"description" json:"description"`
+	Status      string     `form:"status" json:"status" valid:"in(draft|active|archived)"`
+	Type        string     `form:"type" json:"type" valid:"in(standard|bundle)"`
+	PublishedAt *time.Time `form:"published_at" json:"published_at" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// ProductCreateRequest represents the request body for creating a new product, optionally with
+// its variants.
+type ProductCreateRequest struct {
+	ProductUpdateRequest
+	Variants     []CreateVariantRequest `form:"-" json:"variants"`
+	VariantsJSON string                 `form:"variants" json:"-"`
 }
 
 // ProductDetailResponse represents the response structure for product details.
@@ -31,46 +46,125 @@ type ProductDetailResponse struct {
     UUID       string `json:"uuid"`
     ProductName string `json:"product_name"`
     ImageURL    string `json:"image_url"`
+    Description     string `json:"description"`
+    DescriptionHTML string `json:"description_html"`
+    Status      string `json:"status"`
+    Type        string `json:"type"`
+    PublishedAt *time.Time `json:"published_at"`
+    Variants    []models.Variant `json:"variants"`
+    Categories  []models.Category `json:"categories"`
+    Tags        []models.Tag `json:"tags"`
+    Options     []models.ProductOption `json:"options"`
 }
 
 // GetAllProducts retrieves all products from the database with pagination and search.
 func GetAllProducts(c *gin.Context) {
 	db := utils.GetDB()
 
+	// Resolve the currency variant prices are reported in
+	conversion, ok := resolveCurrency(c, db)
+	if !ok {
+		return
+	}
+
+	// Build the query from the filters
+	query, ok := filterProducts(c, db, db.Model(&models.Product{}).Preload("Variants.Attributes"))
+	if !ok {
+		return
+	}
+
+	// Count facets and parse the requested page
+	facets, pagination, ok := applyListQuery(c, query, utils.ProductListSpec)
+	if !ok {
+		return
+	}
+
+	// Fetch products with pagination
+	var products []models.Product
+	response, ok := fetchListPage(c, query, pagination, &products)
+	if !ok {
+		return
+	}
+
+	// Compute bundle availability and convert variant prices to the requested currency
+	for i := range products {
+		if err := utils.FillBundleAvailability(db, products[i].Variants); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle components"})
+			return
+		}
+		convertVariantPrices(products[i].Variants, conversion)
+	}
+
+	response["products"] = products
+	if facets != nil {
+		response["facets"] = facets
+	}
+	if conversion != nil {
+		response["exchangeRate"] = conversion
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// filterProducts applies the filters of GET /products to query: productName, status,
+// categoryUUID, tags with tagMatch, and filter[field][op]. It writes an error response on failure.
+func filterProducts(c *gin.Context, db *gorm.DB, query *gorm.DB) (*gorm.DB, bool) {
 	// Parse query parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "5"))
 	productName := strings.TrimSpace(c.Query("productName"))
+	categoryUUID := strings.TrimSpace(c.Query("categoryUUID"))
+	tagMatch := c.DefaultQuery("tagMatch", "any")
+	status := strings.TrimSpace(c.Query("status"))
 
-	// Pagination logic
-	offset := (page - 1) * pageSize
-
-	// Build the query
-	query := db.Model(&models.Product{}).Preload("Variants")
+	tags, err := utils.NormalizeTags(utils.SplitTags(c.Query("tags")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if tagMatch != "any" && tagMatch != "all" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tagMatch must be any or all"})
+		return nil, false
+	}
+	if status != "" && !govalidator.IsIn(status, models.ProductStatusDraft, models.ProductStatusActive, models.ProductStatusArchived) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidProductStatus.Error()})
+		return nil, false
+	}
 
 	// Apply search filter if name is provided
 	if productName!= "" {
-		query = query.Where("product_name LIKE ?", "%"+productName+"%")
+		query = query.Where("product_name LIKE ?", utils.ContainsPattern(productName))
 	}
 
-	// Fetch total count of products
-    var totalItems int64
-    if err := query.Count(&totalItems).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
-        return
-    }
+	// Apply status filter if status is provided
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
 
-	// Fetch products with pagination
-	var products []models.Product
-	if err := query.Offset(offset).Limit(pageSize).Find(&products).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
-		return
+	// Apply category filter, including the products of its descendants
+	if categoryUUID != "" {
+		var category models.Category
+		if err := db.Where("uuid = ?", categoryUUID).First(&category).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Category not found"})
+			return nil, false
+		}
+
+		categoryProducts := db.Table("product_categories").Select("product_uuid").
+			Where("category_uuid IN (?)", utils.CategoryDescendants(db, category))
+		query = query.Where("uuid IN (?)", categoryProducts)
+	}
+
+	// Apply tag filter, matching any or all of the tags
+	if len(tags) > 0 {
+		query = query.Where("uuid IN (?)", utils.TaggedProducts(db, tags, tagMatch == "all"))
 	}
 
-	// Calculate total pages
-    totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+	// Apply filter[field][op] parameters
+	query, err = utils.ApplyFilters(query, utils.ProductListSpec, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
 
-	c.JSON(http.StatusOK, gin.H{"products": products, "totalItems": totalItems, "totalPages": totalPages})
+	return query, true
 }
 
 // CreateProduct creates a new product.
@@ -96,22 +190,19 @@ func CreateProduct(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		// Multipart requests send the variants as a JSON array
+		if createReq.VariantsJSON != "" {
+			if err := json.Unmarshal([]byte(createReq.VariantsJSON), &createReq.Variants); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Invalid variants"})
+				return
+			}
+		}
 	}
 	if _, err := govalidator.ValidateStruct(createReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Generate a unique filename using UUID
-	fileName := utils.RemoveExtension(createReq.Image.Filename)
-
-	// Upload the file to Cloudinary
-	imageURL, err := utils.UploadFile(createReq.Image, fileName)
-	if err != nil {
-	   c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message": "Failed to upload file!"})
-	   return
-	}
-
 	// Extract admin UUID from claims
 	adminUUIDStr := adminData["adminUUID"].(string)
 
@@ -122,19 +213,151 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	// Check the variants before anything is uploaded or saved
+	variants, attributes, ok := newProductVariants(c, createReq.Variants)
+	if !ok {
+		return
+	}
+
+	// Read the file when one is given, it is uploaded to Cloudinary in the background
+	var image []byte
+	if createReq.Image != nil {
+		image, err = utils.ReadImageFile(createReq.Image)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Failed to read file!"})
+			return
+		}
+	}
+
 	// Use adminUUID when creating a new product
 	newProduct := models.Product{
 		ProductName: createReq.ProductName,
-		ImageURL:    imageURL,
+		ImageURL:    createReq.ImageURL,
+		Type:        models.ProductTypeStandard,
 		AdminUUID:   adminUUID,  // Use the extracted admin UUID
 	}
+	if createReq.Type != "" {
+		newProduct.Type = createReq.Type
+	}
+
+	// Set the description, status and publish time
+	if !applyProductContent(c, &newProduct, createReq.ProductUpdateRequest) {
+		return
+	}
 
-	if err := db.Debug().Create(&newProduct).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create product", "messages": err.Error()})
+	// Save the product with its variants and their attributes, the events reporting them and
+	// the upload of its image
+	var imageJob *models.Job
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newProduct).Error; err != nil {
+			return err
+		}
+		if image != nil {
+			job, err := utils.EnqueueProductImageUpload(tx, newProduct, utils.RemoveExtension(createReq.Image.Filename), image)
+			if err != nil {
+				return err
+			}
+			imageJob = &job
+		}
+		for i := range variants {
+			// Check the identifiers in the transaction, so they are checked against the catalog as it is saved
+			if err := utils.CheckVariantIdentifiers(tx, adminUUID, variants[i]); err != nil {
+				return fmt.Errorf("variants[%d]: %w", i, err)
+			}
+			variants[i].ProductUUID = uuid.MustParse(newProduct.UUID)
+			if err := utils.CreateVariantWithAttributes(tx, &variants[i], attributes[i]); err != nil {
+				return err
+			}
+			if err := utils.RecordVariantEvent(tx, adminUUID, models.EventVariantCreated, variants[i]); err != nil {
+				return err
+			}
+		}
+		newProduct.Variants = variants
+		return utils.RecordProductEvent(tx, models.EventProductCreated, newProduct)
+	}); err != nil {
+		if errors.Is(err, utils.ErrDuplicateSKU) || errors.Is(err, utils.ErrDuplicateBarcode) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to create product"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"product": newProduct})
+	response := gin.H{"product": newProduct}
+	if imageJob != nil {
+		response["imageJob"] = imageJob
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// newProductVariants checks the variants given with a new product with the rules of
+// CreateVariant, and that no two of them share a SKU or barcode; whether the catalog already
+// uses them is checked when they are saved. It returns the variants to create with their
+// attributes, and writes an error response on failure.
+func newProductVariants(c *gin.Context, variantReqs []CreateVariantRequest) ([]models.Variant, []map[string]string, bool) {
+	variants := make([]models.Variant, 0, len(variantReqs))
+	attributes := make([]map[string]string, 0, len(variantReqs))
+	skus := map[string]bool{}
+	barcodes := map[string]bool{}
+
+	for i, variantReq := range variantReqs {
+		if err := validateVariantRequest(variantReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variants[%d]: %s", i, err.Error())})
+			return nil, nil, false
+		}
+		if variantReq.Price < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variants[%d]: Price must not be negative", i)})
+			return nil, nil, false
+		}
+
+		// A new product has no options yet, so only empty attributes are valid
+		variantAttributes, err := utils.ValidateVariantAttributes(nil, variantReq.Attributes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variants[%d]: %s", i, err.Error())})
+			return nil, nil, false
+		}
+
+		variant := models.Variant{
+			VariantName: variantReq.VariantName,
+			Quantity:    *variantReq.Quantity,
+			Price:       variantReq.Price,
+		}
+		if variantReq.SKU != nil {
+			if variant.SKU, err = utils.NormalizeSKU(*variantReq.SKU); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variants[%d]: %s", i, err.Error())})
+				return nil, nil, false
+			}
+		}
+		if variantReq.Barcode != nil {
+			if variant.Barcode, err = utils.NormalizeGTIN(*variantReq.Barcode); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variants[%d]: %s", i, err.Error())})
+				return nil, nil, false
+			}
+		}
+
+		// Check the variant against the ones before it
+		if variant.SKU != "" {
+			if skus[variant.SKU] {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("variants[%d]: %s: %s", i, utils.ErrDuplicateSKU.Error(), variant.SKU)})
+				return nil, nil, false
+			}
+			skus[variant.SKU] = true
+		}
+		if variant.Barcode != "" {
+			// Leading zeros are ignored, as in the catalog
+			barcode := strings.TrimLeft(variant.Barcode, "0")
+			if barcodes[barcode] {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("variants[%d]: %s: %s", i, utils.ErrDuplicateBarcode.Error(), variant.Barcode)})
+				return nil, nil, false
+			}
+			barcodes[barcode] = true
+		}
+
+		variants = append(variants, variant)
+		attributes = append(attributes, variantAttributes)
+	}
+
+	return variants, attributes, true
 }
 
 // UpdateProduct updates the details of a product.
@@ -175,6 +398,11 @@ func UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Variants are bound only to refuse them, they are changed through the variant endpoints
+	if updateReq.Variants != nil || updateReq.VariantsJSON != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Variants cannot be updated with the product", "messages": "Use the variant endpoints"})
+		return
+	}
 
 	// Extract admin UUID from claims
 	adminUUIDStr := adminData["adminUUID"].(string)
@@ -199,20 +427,14 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	// Check if the user uploaded a file
+	// Check if the user uploaded a file, it is uploaded to Cloudinary in the background
+    var image []byte
     if updateReq.Image != nil {
-        // Generate a unique filename using UUID
-        fileName := utils.RemoveExtension(updateReq.Image.Filename)
-
-        // Upload the file to Cloudinary
-        imageURL, err := utils.UploadFile(updateReq.Image, fileName)
+        image, err = utils.ReadImageFile(updateReq.Image)
         if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to upload file!"})
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Failed to read file!"})
             return
         }
-
-        // Update product details
-        existingProduct.ImageURL = imageURL
     } else if updateReq.ImageURL != "" {
         // Update product details with the provided image URL
         existingProduct.ImageURL = updateReq.ImageURL
@@ -220,14 +442,41 @@ func UpdateProduct(c *gin.Context) {
 
     // Update other product details
     existingProduct.ProductName = updateReq.ProductName
+    if updateReq.Type != "" {
+        if err := utils.CheckBundleType(db, existingProduct, updateReq.Type); err != nil {
+            respondBundleError(c, err, "Failed to update product")
+            return
+        }
+        existingProduct.Type = updateReq.Type
+    }
+    if !applyProductContent(c, &existingProduct, updateReq.ProductUpdateRequest) {
+        return
+    }
 
-	// Save the updated product details
-	if err := db.Save(&existingProduct).Error; err != nil {
+	// Save the updated product details with the event reporting them and the upload of the image
+	var imageJob *models.Job
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&existingProduct).Error; err != nil {
+			return err
+		}
+		if image != nil {
+			job, err := utils.EnqueueProductImageUpload(tx, existingProduct, utils.RemoveExtension(updateReq.Image.Filename), image)
+			if err != nil {
+				return err
+			}
+			imageJob = &job
+		}
+		return utils.RecordProductEvent(tx, models.EventProductUpdated, existingProduct)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to update product"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"product": existingProduct})
+	response := gin.H{"product": existingProduct}
+	if imageJob != nil {
+		response["imageJob"] = imageJob
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // DeleteProduct deletes a product.
@@ -261,9 +510,9 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	// Check if the product exists
+	// Check if the product exists, with its variants
 	var existingProduct models.Product
-	if err := db.Where("uuid = ?", productUUID).First(&existingProduct).Error; err != nil {
+	if err := db.Preload("Variants").Where("uuid = ?", productUUID).First(&existingProduct).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Product not found"})
 		return
 	}
@@ -280,8 +529,29 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	// Delete the product
-	if err := db.Delete(&existingProduct).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Unassign the product from its categories
+		if err := tx.Model(&existingProduct).Association("Categories").Clear(); err != nil {
+			return err
+		}
+
+		// Remove the product's tags
+		if err := tx.Model(&existingProduct).Association("Tags").Clear(); err != nil {
+			return err
+		}
+
+		// Delete the product's option definitions
+		if err := tx.Where("product_uuid = ?", existingProduct.UUID).Delete(&models.ProductOption{}).Error; err != nil {
+			return err
+		}
+
+		// Delete the product with the event reporting it
+		if err := tx.Delete(&existingProduct).Error; err != nil {
+			return err
+		}
+		return utils.RecordProductEvent(tx, models.EventProductDeleted, existingProduct)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to delete product",})
 		return
 	}
@@ -303,9 +573,17 @@ func GetProductDetail(c *gin.Context) {
         return
     }
 
+    // Resolve the currency variant prices are reported in
+    conversion, ok := resolveCurrency(c, db)
+    if !ok {
+        return
+    }
+
     // Fetch product details from the database
     var product models.Product
-    if err := db.Where("uuid = ?", productUUID).First(&product).Error; err != nil {
+    if err := db.Preload("Variants.Attributes").Preload("Categories").Preload("Tags").Preload("Options", func(tx *gorm.DB) *gorm.DB {
+        return tx.Order("position")
+    }).Where("uuid = ?", productUUID).First(&product).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": err.Error(),"messages": "Product not found"})
         return
     }
@@ -316,7 +594,49 @@ func GetProductDetail(c *gin.Context) {
         UUID:       productUUIDStr,
         ProductName: product.ProductName,
         ImageURL:    product.ImageURL,
+        Description:     product.Description,
+        DescriptionHTML: product.DescriptionHTML,
+        Status:      product.Status,
+        Type:        product.Type,
+        PublishedAt: product.PublishedAt,
+        Variants:    product.Variants,
+        Categories:  product.Categories,
+        Tags:        product.Tags,
+        Options:     product.Options,
+    }
+
+    // Compute bundle availability and convert variant prices to the requested currency
+    if err := utils.FillBundleAvailability(db, response.Variants); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch bundle components"})
+        return
+    }
+    convertVariantPrices(response.Variants, conversion)
+
+    result := gin.H{"product": response}
+    if conversion != nil {
+        result["exchangeRate"] = conversion
     }
 
-    c.JSON(http.StatusOK, gin.H{"product": response})
+    c.JSON(http.StatusOK, result)
+}
+
+// applyProductContent renders the description and sets the status and publish time of the
+// request on the product, writing an error response on failure.
+func applyProductContent(c *gin.Context, product *models.Product, productReq ProductUpdateRequest) bool {
+	if productReq.Description != nil {
+		descriptionHTML, err := utils.RenderMarkdown(*productReq.Description)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Failed to render description"})
+			return false
+		}
+		product.Description = *productReq.Description
+		product.DescriptionHTML = descriptionHTML
+	}
+
+	if err := utils.ApplyProductStatus(product, productReq.Status, productReq.PublishedAt, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	return true
 }
@@ -0,0 +1,223 @@
+// controllers/product_option_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductOptionRequest represents an option and its values in a product options request.
+type ProductOptionRequest struct {
+	Name   string   `json:"name" valid:"required"`
+	Values []string `json:"values" binding:"required,min=1"`
+}
+
+// ProductOptionsRequest represents the request body for defining the options of a product.
+type ProductOptionsRequest struct {
+	Options []ProductOptionRequest `json:"options" binding:"required,dive"`
+}
+
+// GenerateVariantsRequest represents the request body for generating the variants of a product.
+type GenerateVariantsRequest struct {
+	Quantity uint    `form:"quantity" json:"quantity"`
+	Price    float64 `form:"price" json:"price"`
+}
+
+// GetProductOptions retrieves the option definitions of a product.
+func GetProductOptions(c *gin.Context) {
+	db := utils.GetDB()
+
+	productUUID, err := uuid.Parse(c.Param("productUUID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product UUID format"})
+		return
+	}
+
+	options := []models.ProductOption{}
+	if err := db.Where("product_uuid = ?", productUUID).Order("position").Find(&options).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch options"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"options": options})
+}
+
+// SetProductOptions replaces the option definitions of a product, dropping the attributes of
+// its variants that name a removed option or value.
+func SetProductOptions(c *gin.Context) {
+	db := utils.GetDB()
+	existingProduct := c.MustGet("product").(models.Product)
+
+	var optionsReq ProductOptionsRequest
+	if err := c.ShouldBindJSON(&optionsReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := govalidator.ValidateStruct(optionsReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productUUID := uuid.MustParse(existingProduct.UUID)
+	options := make([]models.ProductOption, 0, len(optionsReq.Options))
+	seen := make(map[string]bool, len(optionsReq.Options))
+	for i, optionReq := range optionsReq.Options {
+		name := strings.TrimSpace(optionReq.Name)
+		values := utils.NormalizeOptionValues(optionReq.Values)
+		if name == "" || len(values) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidOption.Error()})
+			return
+		}
+		if seen[strings.ToLower(name)] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate option " + name})
+			return
+		}
+		seen[strings.ToLower(name)] = true
+
+		options = append(options, models.ProductOption{
+			ProductUUID: productUUID,
+			Name:        name,
+			Values:      values,
+			Position:    i,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_uuid = ?", productUUID).Delete(&models.ProductOption{}).Error; err != nil {
+			return err
+		}
+		if len(options) > 0 {
+			if err := tx.Create(&options).Error; err != nil {
+				return err
+			}
+		}
+		return utils.PruneVariantAttributes(tx, existingProduct.AdminUUID, productUUID, options)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to save options"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"options": options})
+}
+
+// GenerateVariants creates a variant for every combination of the product's option values
+// that does not have one yet.
+func GenerateVariants(c *gin.Context) {
+	db := utils.GetDB()
+	existingProduct := c.MustGet("product").(models.Product)
+	contentType := utils.GetContentType(c)
+
+	var generateReq GenerateVariantsRequest
+	if c.Request.ContentLength != 0 {
+		var err error
+		if contentType == appJSON {
+			err = c.ShouldBindJSON(&generateReq)
+		} else {
+			err = c.ShouldBind(&generateReq)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if generateReq.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must not be negative"})
+		return
+	}
+
+	productUUID := uuid.MustParse(existingProduct.UUID)
+	createdVariants := []models.Variant{}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var options []models.ProductOption
+		if err := tx.Where("product_uuid = ?", productUUID).Order("position").Find(&options).Error; err != nil {
+			return err
+		}
+		if len(options) == 0 {
+			return utils.ErrInvalidOption
+		}
+
+		combinations, err := utils.VariantCombinations(options)
+		if err != nil {
+			return err
+		}
+
+		// Skip the combinations that already have a variant
+		var existingVariants []models.Variant
+		if err := tx.Preload("Attributes").Where("product_uuid = ?", productUUID).Find(&existingVariants).Error; err != nil {
+			return err
+		}
+		existingKeys := make(map[string]bool, len(existingVariants))
+		for _, variant := range existingVariants {
+			attributes := make(map[string]string, len(variant.Attributes))
+			for _, attribute := range variant.Attributes {
+				attributes[attribute.Name] = attribute.Value
+			}
+			existingKeys[utils.VariantAttributeKey(attributes)] = true
+		}
+
+		for _, combination := range combinations {
+			if existingKeys[utils.VariantAttributeKey(combination)] {
+				continue
+			}
+
+			newVariant := models.Variant{
+				VariantName: utils.VariantCombinationName(options, combination),
+				Quantity:    generateReq.Quantity,
+				Price:       generateReq.Price,
+				ProductUUID: productUUID,
+			}
+			if err := utils.CreateVariantWithAttributes(tx, &newVariant, combination); err != nil {
+				return err
+			}
+			if err := utils.RecordVariantEvent(tx, existingProduct.AdminUUID, models.EventVariantCreated, newVariant); err != nil {
+				return err
+			}
+			createdVariants = append(createdVariants, newVariant)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidOption) || errors.Is(err, utils.ErrTooManyCombinations) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Define the product options first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to generate variants"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"variants": createdVariants, "totalItems": len(createdVariants)})
+}
+
+// validateVariantAttributes checks the requested attributes against the options of the product,
+// writing an error response on failure.
+func validateVariantAttributes(c *gin.Context, db *gorm.DB, productUUID uuid.UUID, attributes map[string]string) (map[string]string, bool) {
+	if len(attributes) == 0 {
+		return attributes, true
+	}
+
+	var options []models.ProductOption
+	if err := db.Where("product_uuid = ?", productUUID).Order("position").Find(&options).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch options"})
+		return nil, false
+	}
+
+	validated, err := utils.ValidateVariantAttributes(options, attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return validated, true
+}
@@ -0,0 +1,322 @@
+// controllers/promotion_controller.go
+
+package controllers
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PromotionRequest represents the request body for creating or updating a promotion.
+type PromotionRequest struct {
+	Name         string     `form:"name" json:"name" valid:"required"`
+	Type         string     `form:"type" json:"type" valid:"required,in(percentage|fixed_amount|buy_x_get_y)"`
+	Value        float64    `form:"value" json:"value"`
+	BuyQuantity  uint       `form:"buy_quantity" json:"buy_quantity"`
+	GetQuantity  uint       `form:"get_quantity" json:"get_quantity"`
+	TargetType   string     `form:"target_type" json:"target_type" valid:"required,in(product|variant|category)"`
+	TargetUUID   string     `form:"target_uuid" json:"target_uuid" valid:"required,uuid"`
+	StartsAt     *time.Time `form:"starts_at" json:"starts_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	EndsAt       *time.Time `form:"ends_at" json:"ends_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	UsageLimit   uint       `form:"usage_limit" json:"usage_limit"`
+	MinimumSpend float64    `form:"minimum_spend" json:"minimum_spend"`
+	Stackable    bool       `form:"stackable" json:"stackable"`
+	Priority     int        `form:"priority" json:"priority"`
+}
+
+// PromotionEvaluateItem represents a variant and quantity to evaluate promotions against.
+type PromotionEvaluateItem struct {
+	VariantUUID string `json:"variant_uuid" valid:"required,uuid"`
+	Quantity    uint   `json:"quantity" valid:"required"`
+}
+
+// PromotionEvaluateRequest represents the request body for evaluating promotions.
+type PromotionEvaluateRequest struct {
+	Items []PromotionEvaluateItem `json:"items" binding:"required,min=1"`
+}
+
+// GetAllPromotions retrieves all promotions of the admin.
+func GetAllPromotions(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var promotions []models.Promotion
+	if err := db.Where("admin_uuid = ?", adminUUID).Order("priority DESC, id").Find(&promotions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch promotions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promotions": promotions})
+}
+
+// CreatePromotion creates a new promotion.
+func CreatePromotion(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	promotionReq, ok := bindPromotionRequest(c)
+	if !ok {
+		return
+	}
+
+	newPromotion := models.Promotion{AdminUUID: adminUUID}
+	applyPromotionRequest(&newPromotion, promotionReq)
+
+	if !validatePromotionTarget(c, db, adminUUID, newPromotion) {
+		return
+	}
+
+	if err := db.Create(&newPromotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to create promotion"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"promotion": newPromotion})
+}
+
+// GetPromotionDetail retrieves the details of a promotion.
+func GetPromotionDetail(c *gin.Context) {
+	existingPromotion := c.MustGet("promotion").(models.Promotion)
+
+	c.JSON(http.StatusOK, gin.H{"promotion": existingPromotion})
+}
+
+// UpdatePromotion updates the details of a promotion.
+func UpdatePromotion(c *gin.Context) {
+	db := utils.GetDB()
+	existingPromotion := c.MustGet("promotion").(models.Promotion)
+
+	promotionReq, ok := bindPromotionRequest(c)
+	if !ok {
+		return
+	}
+
+	applyPromotionRequest(&existingPromotion, promotionReq)
+
+	if !validatePromotionTarget(c, db, existingPromotion.AdminUUID, existingPromotion) {
+		return
+	}
+
+	if err := db.Save(&existingPromotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to update promotion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promotion": existingPromotion})
+}
+
+// DeletePromotion deletes a promotion.
+func DeletePromotion(c *gin.Context) {
+	db := utils.GetDB()
+	existingPromotion := c.MustGet("promotion").(models.Promotion)
+
+	if err := db.Delete(&existingPromotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to delete promotion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted successfully"})
+}
+
+// EvaluatePromotions returns the line-level discounts the admin's promotions give on a list of variants.
+func EvaluatePromotions(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var evaluateReq PromotionEvaluateRequest
+	if err := c.ShouldBindJSON(&evaluateReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := govalidator.ValidateStruct(evaluateReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lines, status, err := loadPromotionLines(db, adminUUID, evaluateReq.Items)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	var promotions []models.Promotion
+	if err := db.Where("admin_uuid = ?", adminUUID).Find(&promotions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch promotions"})
+		return
+	}
+
+	evaluation := utils.EvaluatePromotions(promotions, lines, time.Now(), helpers.EnvBaseCurrency())
+
+	c.JSON(http.StatusOK, gin.H{"evaluation": evaluation})
+}
+
+// bindPromotionRequest parses and validates a promotion request, writing an error response on failure.
+func bindPromotionRequest(c *gin.Context) (PromotionRequest, bool) {
+	contentType := utils.GetContentType(c)
+
+	var promotionReq PromotionRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&promotionReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return promotionReq, false
+		}
+	} else {
+		if err := c.ShouldBind(&promotionReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return promotionReq, false
+		}
+	}
+	if _, err := govalidator.ValidateStruct(promotionReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return promotionReq, false
+	}
+
+	// Buy X get Y gives the Y items away for free unless a percentage is set
+	if promotionReq.Type == models.PromotionTypeBuyXGetY && promotionReq.Value == 0 {
+		promotionReq.Value = 100
+	}
+
+	return promotionReq, true
+}
+
+// applyPromotionRequest copies the request fields onto the promotion.
+func applyPromotionRequest(promotion *models.Promotion, promotionReq PromotionRequest) {
+	promotion.Name = promotionReq.Name
+	promotion.Type = promotionReq.Type
+	promotion.Value = promotionReq.Value
+	promotion.BuyQuantity = promotionReq.BuyQuantity
+	promotion.GetQuantity = promotionReq.GetQuantity
+	promotion.TargetType = promotionReq.TargetType
+	promotion.TargetUUID = promotionReq.TargetUUID
+	promotion.StartsAt = promotionReq.StartsAt
+	promotion.EndsAt = promotionReq.EndsAt
+	promotion.UsageLimit = promotionReq.UsageLimit
+	promotion.MinimumSpend = promotionReq.MinimumSpend
+	promotion.Stackable = promotionReq.Stackable
+	promotion.Priority = promotionReq.Priority
+}
+
+// validatePromotionTarget checks the promotion values and that its target belongs to the admin.
+func validatePromotionTarget(c *gin.Context, db *gorm.DB, adminUUID uuid.UUID, promotion models.Promotion) bool {
+	if err := utils.ValidatePromotion(promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	var productUUID string
+	switch promotion.TargetType {
+	case models.PromotionTargetProduct:
+		productUUID = promotion.TargetUUID
+	case models.PromotionTargetVariant:
+		var existingVariant models.Variant
+		if err := db.Where("uuid = ?", promotion.TargetUUID).First(&existingVariant).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Variant not found"})
+			return false
+		}
+		productUUID = existingVariant.ProductUUID.String()
+	case models.PromotionTargetCategory:
+		if _, err := utils.FindCatalogCategory(db, adminUUID, promotion.TargetUUID); err != nil {
+			respondCategoryError(c, err, "Invalid promotion target")
+			return false
+		}
+		return true
+	default:
+		return true
+	}
+
+	var existingProduct models.Product
+	if err := db.Where("uuid = ?", productUUID).First(&existingProduct).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Product not found"})
+		return false
+	}
+
+	if existingProduct.AdminUUID != adminUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to create a promotion for this target."})
+		return false
+	}
+
+	return true
+}
+
+// loadPromotionLines builds promotion lines from the items, checking that every variant belongs to the admin.
+func loadPromotionLines(db *gorm.DB, adminUUID uuid.UUID, items []PromotionEvaluateItem) ([]utils.PromotionLine, int, error) {
+	variantUUIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		variantUUIDs = append(variantUUIDs, item.VariantUUID)
+	}
+
+	var variants []models.Variant
+	if err := db.Where("uuid IN ?", variantUUIDs).Find(&variants).Error; err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	variantsByUUID := make(map[string]models.Variant, len(variants))
+	productUUIDs := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		variantsByUUID[variant.UUID] = variant
+		productUUIDs = append(productUUIDs, variant.ProductUUID.String())
+	}
+
+	var products []models.Product
+	if err := db.Where("uuid IN ?", productUUIDs).Find(&products).Error; err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	productsByUUID := make(map[string]models.Product, len(products))
+	for _, product := range products {
+		productsByUUID[product.UUID] = product
+	}
+
+	// Category promotions also apply to the products of descendant categories
+	categoryUUIDs, err := utils.ProductCategoryUUIDs(db, productUUIDs)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	lines := make([]utils.PromotionLine, 0, len(items))
+	for _, item := range items {
+		variant, ok := variantsByUUID[item.VariantUUID]
+		if !ok {
+			return nil, http.StatusNotFound, errors.New("Variant not found: " + item.VariantUUID)
+		}
+
+		product, ok := productsByUUID[variant.ProductUUID.String()]
+		if !ok || product.AdminUUID != adminUUID {
+			return nil, http.StatusForbidden, errors.New("You don't have permission to use variant " + item.VariantUUID)
+		}
+
+		lines = append(lines, utils.PromotionLine{
+			VariantUUID:   variant.UUID,
+			ProductUUID:   product.UUID,
+			CategoryUUIDs: categoryUUIDs[product.UUID],
+			Quantity:      item.Quantity,
+			UnitPrice:     variant.Price,
+		})
+	}
+
+	return lines, http.StatusOK, nil
+}
@@ -0,0 +1,242 @@
+// controllers/purchase_order_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PurchaseOrderLineRequest represents a variant, quantity and unit cost in a purchase order request.
+type PurchaseOrderLineRequest struct {
+	VariantUUID string  `json:"variant_uuid" valid:"required,uuid"`
+	Quantity    uint    `json:"quantity" valid:"required"`
+	UnitCost    float64 `json:"unit_cost"`
+}
+
+// PurchaseOrderCreateRequest represents the request body for creating a new purchase order.
+type PurchaseOrderCreateRequest struct {
+	SupplierUUID string                     `json:"supplier_uuid" valid:"required,uuid"`
+	Note         string                     `json:"note"`
+	Lines        []PurchaseOrderLineRequest `json:"lines" binding:"required,min=1"`
+}
+
+// PurchaseOrderStatusRequest represents the request body for changing the status of a purchase order.
+type PurchaseOrderStatusRequest struct {
+	Status string `form:"status" json:"status" valid:"required,in(ordered|cancelled)"`
+}
+
+// PurchaseOrderReceiveLineRequest represents a variant and quantity received on a purchase order.
+type PurchaseOrderReceiveLineRequest struct {
+	VariantUUID string `json:"variant_uuid" valid:"required,uuid"`
+	Quantity    uint   `json:"quantity" valid:"required"`
+}
+
+// PurchaseOrderReceiveRequest represents the request body for receiving goods on a purchase order.
+type PurchaseOrderReceiveRequest struct {
+	Lines []PurchaseOrderReceiveLineRequest `json:"lines" binding:"required,min=1"`
+}
+
+// GetAllPurchaseOrders retrieves the admin's purchase orders with pagination and optional status and supplier filters.
+func GetAllPurchaseOrders(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "5"))
+	status := strings.TrimSpace(c.Query("status"))
+	supplierUUID := strings.TrimSpace(c.Query("supplierUUID"))
+
+	if page < 1 || pageSize < 1 || pageSize > utils.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidPage.Error()})
+		return
+	}
+
+	// Pagination logic
+	offset := (page - 1) * pageSize
+
+	// Build the query
+	query := db.Model(&models.PurchaseOrder{}).Where("admin_uuid = ?", adminUUID)
+
+	// Apply filters if provided
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if supplierUUID != "" {
+		query = query.Where("supplier_uuid = ?", supplierUUID)
+	}
+
+	// Fetch total count of purchase orders
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
+		return
+	}
+
+	// Fetch purchase orders with pagination
+	var purchaseOrders []models.PurchaseOrder
+	if err := query.Preload("Lines").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&purchaseOrders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase orders"})
+		return
+	}
+
+	// Calculate total pages
+	totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{"purchaseOrders": purchaseOrders, "totalItems": totalItems, "totalPages": totalPages})
+}
+
+// CreatePurchaseOrder creates a draft purchase order from a supplier.
+func CreatePurchaseOrder(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var createReq PurchaseOrderCreateRequest
+	if err := c.ShouldBindJSON(&createReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := govalidator.ValidateStruct(createReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if the supplier belongs to the admin
+	var existingSupplier models.Supplier
+	if err := db.Where("uuid = ?", createReq.SupplierUUID).First(&existingSupplier).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Supplier not found"})
+		return
+	}
+	if existingSupplier.AdminUUID != adminUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to order from this supplier."})
+		return
+	}
+
+	items := make([]utils.PurchaseOrderItem, 0, len(createReq.Lines))
+	for _, line := range createReq.Lines {
+		if line.UnitCost < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unit cost must not be negative"})
+			return
+		}
+		items = append(items, utils.PurchaseOrderItem{VariantUUID: line.VariantUUID, Quantity: line.Quantity, UnitCost: line.UnitCost})
+	}
+
+	newPurchaseOrder, err := utils.CreatePurchaseOrder(db, adminUUID, uuid.MustParse(existingSupplier.UUID), createReq.Note, items)
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Failed to create purchase order")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"purchaseOrder": newPurchaseOrder})
+}
+
+// GetPurchaseOrderDetail retrieves the details of a purchase order with its lines.
+func GetPurchaseOrderDetail(c *gin.Context) {
+	db := utils.GetDB()
+	existingPurchaseOrder := c.MustGet("purchaseOrder").(models.PurchaseOrder)
+
+	if err := db.Preload("Lines").Where("uuid = ?", existingPurchaseOrder.UUID).First(&existingPurchaseOrder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch purchase order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purchaseOrder": existingPurchaseOrder})
+}
+
+// UpdatePurchaseOrderStatus places a draft purchase order with the supplier or cancels it.
+func UpdatePurchaseOrderStatus(c *gin.Context) {
+	db := utils.GetDB()
+	existingPurchaseOrder := c.MustGet("purchaseOrder").(models.PurchaseOrder)
+	contentType := utils.GetContentType(c)
+
+	var statusReq PurchaseOrderStatusRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&statusReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		if err := c.ShouldBind(&statusReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if _, err := govalidator.ValidateStruct(statusReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedPurchaseOrder, err := utils.TransitionPurchaseOrder(db, existingPurchaseOrder.UUID, statusReq.Status)
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Failed to update purchase order status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purchaseOrder": updatedPurchaseOrder})
+}
+
+// ReceivePurchaseOrder records goods received on a purchase order and adds them to stock.
+func ReceivePurchaseOrder(c *gin.Context) {
+	db := utils.GetDB()
+	existingPurchaseOrder := c.MustGet("purchaseOrder").(models.PurchaseOrder)
+
+	var receiveReq PurchaseOrderReceiveRequest
+	if err := c.ShouldBindJSON(&receiveReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := govalidator.ValidateStruct(receiveReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items := make([]utils.PurchaseOrderItem, 0, len(receiveReq.Lines))
+	for _, line := range receiveReq.Lines {
+		items = append(items, utils.PurchaseOrderItem{VariantUUID: line.VariantUUID, Quantity: line.Quantity})
+	}
+
+	updatedPurchaseOrder, err := utils.ReceivePurchaseOrder(db, existingPurchaseOrder.UUID, items)
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Failed to receive purchase order")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purchaseOrder": updatedPurchaseOrder})
+}
+
+// respondPurchaseOrderError maps purchase order errors to HTTP responses.
+func respondPurchaseOrderError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidPurchaseOrderTransition), errors.Is(err, utils.ErrPurchaseOrderNotReceivable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrReceiveQuantity), errors.Is(err, utils.ErrPurchaseOrderLineNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrVariantNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrVariantNotInCatalog):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "messages": message})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": message})
+	}
+}
@@ -0,0 +1,183 @@
+// controllers/return_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReturnCreateRequest represents the request body for requesting a return of an order line.
+type ReturnCreateRequest struct {
+	OrderLineUUID string `form:"order_line_uuid" json:"order_line_uuid" valid:"required,uuid"`
+	Quantity      uint   `form:"quantity" json:"quantity" valid:"required"`
+	Reason        string `form:"reason" json:"reason"`
+}
+
+// ReturnRefundRequest represents the request body for refunding a return.
+type ReturnRefundRequest struct {
+	Amount *float64 `form:"amount" json:"amount"`
+}
+
+// GetAllReturns retrieves the admin's returns with pagination and an optional status filter.
+func GetAllReturns(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "5"))
+	status := strings.TrimSpace(c.Query("status"))
+
+	if page < 1 || pageSize < 1 || pageSize > utils.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidPage.Error()})
+		return
+	}
+
+	// Pagination logic
+	offset := (page - 1) * pageSize
+
+	// Build the query
+	query := db.Model(&models.Return{}).Where("admin_uuid = ?", adminUUID)
+
+	// Apply status filter if provided
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// Fetch total count of returns
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
+		return
+	}
+
+	// Fetch returns with pagination
+	var returns []models.Return
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch returns"})
+		return
+	}
+
+	// Calculate total pages
+	totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns, "totalItems": totalItems, "totalPages": totalPages})
+}
+
+// CreateReturn requests a return of units of an order line.
+func CreateReturn(c *gin.Context) {
+	db := utils.GetDB()
+	existingOrder := c.MustGet("order").(models.Order)
+	contentType := utils.GetContentType(c)
+
+	var createReq ReturnCreateRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&createReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		if err := c.ShouldBind(&createReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if _, err := govalidator.ValidateStruct(createReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newReturn, err := utils.RequestReturn(db, existingOrder.UUID, createReq.OrderLineUUID, createReq.Quantity, createReq.Reason)
+	if err != nil {
+		respondReturnError(c, err, "Failed to request return")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"return": newReturn})
+}
+
+// GetReturnDetail retrieves the details of a return.
+func GetReturnDetail(c *gin.Context) {
+	existingReturn := c.MustGet("return").(models.Return)
+
+	c.JSON(http.StatusOK, gin.H{"return": existingReturn})
+}
+
+// ApproveReturn approves a requested return.
+func ApproveReturn(c *gin.Context) {
+	transitionReturn(c, models.ReturnStatusApproved, nil)
+}
+
+// RejectReturn rejects a requested or approved return.
+func RejectReturn(c *gin.Context) {
+	transitionReturn(c, models.ReturnStatusRejected, nil)
+}
+
+// ReceiveReturn records the returned goods as received and puts them back in stock.
+func ReceiveReturn(c *gin.Context) {
+	transitionReturn(c, models.ReturnStatusReceived, nil)
+}
+
+// RefundReturn records the refund of a received return, for its full value unless an amount is given.
+func RefundReturn(c *gin.Context) {
+	contentType := utils.GetContentType(c)
+
+	var refundReq ReturnRefundRequest
+	if c.Request.ContentLength != 0 {
+		var err error
+		if contentType == appJSON {
+			err = c.ShouldBindJSON(&refundReq)
+		} else {
+			err = c.ShouldBind(&refundReq)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	transitionReturn(c, models.ReturnStatusRefunded, refundReq.Amount)
+}
+
+// transitionReturn moves the return in the context to a new status and writes the response.
+func transitionReturn(c *gin.Context, status string, refundAmount *float64) {
+	db := utils.GetDB()
+	existingReturn := c.MustGet("return").(models.Return)
+
+	updatedReturn, err := utils.TransitionReturn(db, existingReturn.UUID, status, refundAmount)
+	if err != nil {
+		respondReturnError(c, err, "Failed to update return status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"return": updatedReturn})
+}
+
+// respondReturnError maps return errors to HTTP responses.
+func respondReturnError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, utils.ErrOrderNotReturnable), errors.Is(err, utils.ErrInvalidReturnTransition), errors.Is(err, utils.ErrOrderCancelled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrReturnQuantity), errors.Is(err, utils.ErrRefundAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": message})
+	case errors.Is(err, utils.ErrOrderLineNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": message})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": message})
+	}
+}
@@ -0,0 +1,143 @@
+// controllers/search_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/search"
+	"basictrade/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchResult is a product or variant matching a search, with its highlighted fields.
+type SearchResult struct {
+	search.Hit
+	Product *models.Product `json:"product,omitempty"`
+	Variant *models.Variant `json:"variant,omitempty"`
+}
+
+// Search returns the admin's products and variants matching q, ranked by relevance.
+func Search(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	// Parse query parameters
+	q := strings.TrimSpace(c.Query("q"))
+	docType := c.Query("type")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	if docType != "" && docType != search.TypeProduct && docType != search.TypeVariant {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be product or variant"})
+		return
+	}
+	if page < 1 || pageSize < 1 || pageSize > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be at least 1 and pageSize between 1 and 100"})
+		return
+	}
+
+	index := search.Default()
+	if index == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Search is not available"})
+		return
+	}
+
+	result, err := index.Search(search.Query{
+		Text:      q,
+		AdminUUID: adminUUID.String(),
+		Type:      docType,
+		From:      (page - 1) * pageSize,
+		Size:      pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to search"})
+		return
+	}
+
+	// Load the matching rows, keeping the ranking of the index
+	var productUUIDs, variantUUIDs []string
+	for _, hit := range result.Hits {
+		if hit.Type == search.TypeProduct {
+			productUUIDs = append(productUUIDs, hit.UUID)
+		} else {
+			variantUUIDs = append(variantUUIDs, hit.UUID)
+		}
+	}
+
+	var products []models.Product
+	if len(productUUIDs) > 0 {
+		if err := db.Where("uuid IN ?", productUUIDs).Find(&products).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch products"})
+			return
+		}
+	}
+	var variants []models.Variant
+	if len(variantUUIDs) > 0 {
+		if err := db.Preload("Attributes").Where("uuid IN ?", variantUUIDs).Find(&variants).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch variants"})
+			return
+		}
+		if err := utils.FillBundleAvailability(db, variants); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch bundle components"})
+			return
+		}
+	}
+
+	productsByUUID := make(map[string]*models.Product, len(products))
+	for i := range products {
+		productsByUUID[products[i].UUID] = &products[i]
+	}
+	variantsByUUID := make(map[string]*models.Variant, len(variants))
+	for i := range variants {
+		variantsByUUID[variants[i].UUID] = &variants[i]
+	}
+
+	results := make([]SearchResult, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		// Skip documents whose row was deleted but not yet removed from the index
+		searchResult := SearchResult{Hit: hit, Product: productsByUUID[hit.UUID], Variant: variantsByUUID[hit.UUID]}
+		if searchResult.Product == nil && searchResult.Variant == nil {
+			continue
+		}
+		results = append(results, searchResult)
+	}
+
+	totalPages := int(math.Ceil(float64(result.Total) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{"results": results, "totalItems": result.Total, "totalPages": totalPages})
+}
+
+// ReindexSearch queues a job rebuilding the search documents of the admin's catalog from the
+// database.
+func ReindexSearch(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	job, err := utils.EnqueueJob(db, models.JobReindexSearch, &adminUUID, nil, time.Time{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to queue reindex"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Catalog reindex queued", "job": job})
+}
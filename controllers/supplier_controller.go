@@ -0,0 +1,155 @@
+// controllers/supplier_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"net/http"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// SupplierRequest represents the request body for creating or updating a supplier.
+type SupplierRequest struct {
+	Name    string `form:"name" json:"name" valid:"required"`
+	Email   string `form:"email" json:"email" valid:"email"`
+	Phone   string `form:"phone" json:"phone"`
+	Address string `form:"address" json:"address"`
+}
+
+// GetAllSuppliers retrieves the admin's suppliers with an optional name search.
+func GetAllSuppliers(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+	name := strings.TrimSpace(c.Query("name"))
+
+	query := db.Where("admin_uuid = ?", adminUUID)
+	if name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+
+	var suppliers []models.Supplier
+	if err := query.Order("name").Find(&suppliers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch suppliers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suppliers": suppliers})
+}
+
+// CreateSupplier creates a new supplier.
+func CreateSupplier(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	supplierReq, ok := bindSupplierRequest(c)
+	if !ok {
+		return
+	}
+
+	newSupplier := models.Supplier{
+		Name:      supplierReq.Name,
+		Email:     supplierReq.Email,
+		Phone:     supplierReq.Phone,
+		Address:   supplierReq.Address,
+		AdminUUID: adminUUID,
+	}
+
+	if err := db.Create(&newSupplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to create supplier"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"supplier": newSupplier})
+}
+
+// GetSupplierDetail retrieves the details of a supplier.
+func GetSupplierDetail(c *gin.Context) {
+	existingSupplier := c.MustGet("supplier").(models.Supplier)
+
+	c.JSON(http.StatusOK, gin.H{"supplier": existingSupplier})
+}
+
+// UpdateSupplier updates the details of a supplier.
+func UpdateSupplier(c *gin.Context) {
+	db := utils.GetDB()
+	existingSupplier := c.MustGet("supplier").(models.Supplier)
+
+	supplierReq, ok := bindSupplierRequest(c)
+	if !ok {
+		return
+	}
+
+	existingSupplier.Name = supplierReq.Name
+	existingSupplier.Email = supplierReq.Email
+	existingSupplier.Phone = supplierReq.Phone
+	existingSupplier.Address = supplierReq.Address
+
+	if err := db.Save(&existingSupplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to update supplier"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"supplier": existingSupplier})
+}
+
+// DeleteSupplier deletes a supplier that has no purchase orders.
+func DeleteSupplier(c *gin.Context) {
+	db := utils.GetDB()
+	existingSupplier := c.MustGet("supplier").(models.Supplier)
+
+	// Keep the purchase order history intact
+	var purchaseOrders int64
+	if err := db.Model(&models.PurchaseOrder{}).Where("supplier_uuid = ?", existingSupplier.UUID).Count(&purchaseOrders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch purchase orders"})
+		return
+	}
+	if purchaseOrders > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete supplier with purchase orders"})
+		return
+	}
+
+	if err := db.Delete(&existingSupplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to delete supplier"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier deleted successfully"})
+}
+
+// bindSupplierRequest parses and validates a supplier request, writing an error response on failure.
+func bindSupplierRequest(c *gin.Context) (SupplierRequest, bool) {
+	contentType := utils.GetContentType(c)
+
+	var supplierReq SupplierRequest
+	if contentType == appJSON {
+		if err := c.ShouldBindJSON(&supplierReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return supplierReq, false
+		}
+	} else {
+		if err := c.ShouldBind(&supplierReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return supplierReq, false
+		}
+	}
+	if _, err := govalidator.ValidateStruct(supplierReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return supplierReq, false
+	}
+
+	return supplierReq, true
+}
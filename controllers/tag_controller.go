@@ -0,0 +1,121 @@
+// controllers/tag_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ProductTagsRequest represents the request body for tagging a product.
+type ProductTagsRequest struct {
+	Tags []string `form:"tags" json:"tags" binding:"required,min=1"`
+}
+
+// TagUsageResponse represents a tag with the number of products carrying it.
+type TagUsageResponse struct {
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	ProductCount int64  `json:"product_count"`
+}
+
+// GetAllTags retrieves the admin's tags with the number of products carrying each one.
+func GetAllTags(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	tags := []TagUsageResponse{}
+	if err := db.Model(&models.Tag{}).
+		Select("tags.uuid, tags.name, COUNT(product_tags.product_uuid) AS product_count").
+		Joins("LEFT JOIN product_tags ON product_tags.tag_uuid = tags.uuid").
+		Where("tags.admin_uuid = ?", adminUUID).
+		Group("tags.uuid, tags.name").
+		Order("product_count DESC, tags.name").
+		Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// AddProductTags tags a product, creating the tags that do not exist yet.
+func AddProductTags(c *gin.Context) {
+	db := utils.GetDB()
+	existingProduct := c.MustGet("product").(models.Product)
+
+	var tagsReq ProductTagsRequest
+	if err := c.ShouldBind(&tagsReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	names, err := utils.NormalizeTags(tagsReq.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		tags, err := utils.FindOrCreateTags(tx, existingProduct.AdminUUID, names)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&existingProduct).Association("Tags").Append(tags); err != nil {
+			return err
+		}
+		return utils.RecordProductEvent(tx, models.EventProductUpdated, existingProduct)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to tag product"})
+		return
+	}
+
+	respondProductTags(c, db, existingProduct)
+}
+
+// RemoveProductTag removes a tag from a product.
+func RemoveProductTag(c *gin.Context) {
+	db := utils.GetDB()
+	existingProduct := c.MustGet("product").(models.Product)
+	name := strings.ToLower(strings.TrimSpace(c.Param("tag")))
+
+	var tag models.Tag
+	if err := db.Where("admin_uuid = ? AND name = ?", existingProduct.AdminUUID, name).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Tag not found"})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existingProduct).Association("Tags").Delete(&tag); err != nil {
+			return err
+		}
+		return utils.RecordProductEvent(tx, models.EventProductUpdated, existingProduct)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to remove tag"})
+		return
+	}
+
+	respondProductTags(c, db, existingProduct)
+}
+
+// respondProductTags writes the tags currently on the product.
+func respondProductTags(c *gin.Context, db *gorm.DB, product models.Product) {
+	tags := []models.Tag{}
+	if err := db.Model(&product).Association("Tags").Find(&tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
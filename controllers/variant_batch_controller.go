@@ -0,0 +1,375 @@
+// controllers/variant_batch_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxBatchOperations is the largest number of operations a batch request may hold.
+const maxBatchOperations = 500
+
+// Batch modes: atomic applies every operation or none, best_effort applies those that succeed.
+const (
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+)
+
+// Batch operations.
+const (
+	batchOpCreate = "create"
+	batchOpUpdate = "update"
+	batchOpDelete = "delete"
+)
+
+// BatchVariantFields holds the fields of a variant in a batch operation. Fields left out of an
+// update keep their current values.
+type BatchVariantFields struct {
+	ProductUUID *string           `json:"product_uuid"`
+	VariantName *string           `json:"variant_name"`
+	Quantity    *uint             `json:"quantity"`
+	Price       *float64          `json:"price"`
+	SKU         *string           `json:"sku"`
+	Barcode     *string           `json:"barcode"`
+	Attributes  map[string]string `json:"attributes"`
+}
+
+// BatchVariantOperation represents one create, update or delete of a variant.
+type BatchVariantOperation struct {
+	Op          string             `json:"op" valid:"required,in(create|update|delete)"`
+	VariantUUID string             `json:"variant_uuid" valid:"uuid"`
+	Variant     BatchVariantFields `json:"variant"`
+}
+
+// BatchVariantsRequest represents the request body for applying many variant operations at once.
+type BatchVariantsRequest struct {
+	Mode       string                  `json:"mode" valid:"in(atomic|best_effort)"`
+	Operations []BatchVariantOperation `json:"operations" binding:"required"`
+}
+
+// BatchVariantResult is the outcome of one operation of a batch request.
+type BatchVariantResult struct {
+	Index   int             `json:"index"`
+	Op      string          `json:"op"`
+	Status  int             `json:"status"`
+	Variant *models.Variant `json:"variant,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// batchError is the failure of a batch operation with the HTTP status it would have answered.
+type batchError struct {
+	status int
+	err    error
+}
+
+func (e *batchError) Error() string {
+	return e.err.Error()
+}
+
+// BatchVariants creates, updates and deletes many variants of the admin's catalog in one
+// request, atomically or applying each operation on its own, and reports the outcome of each.
+func BatchVariants(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var batchReq BatchVariantsRequest
+	if err := c.ShouldBindJSON(&batchReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := govalidator.ValidateStruct(batchReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(batchReq.Operations) == 0 || len(batchReq.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A batch must hold between 1 and 500 operations"})
+		return
+	}
+	if batchReq.Mode == "" {
+		batchReq.Mode = batchModeAtomic
+	}
+
+	// Load the variants and products the operations refer to, checking ownership once
+	variants, products, err := loadBatchRecords(db, adminUUID, batchReq.Operations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch variants"})
+		return
+	}
+
+	results := make([]BatchVariantResult, len(batchReq.Operations))
+	failed := 0
+
+	applyOperations := func(db *gorm.DB) {
+		for i, operation := range batchReq.Operations {
+			result := BatchVariantResult{Index: i, Op: operation.Op}
+
+			var variant models.Variant
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				variant, err = applyBatchOperation(tx, adminUUID, operation, variants, products)
+				return err
+			})
+			if err != nil {
+				result.Status, result.Error = batchErrorStatus(err), err.Error()
+				failed++
+				results[i] = result
+				continue
+			}
+
+			result.Status = http.StatusOK
+			switch operation.Op {
+			case batchOpCreate:
+				result.Status = http.StatusCreated
+				result.Variant = &variant
+			case batchOpUpdate:
+				result.Variant = &variant
+				variants[variant.UUID] = variant
+			case batchOpDelete:
+				delete(variants, variant.UUID)
+			}
+			results[i] = result
+		}
+	}
+
+	if batchReq.Mode == batchModeAtomic {
+		// Each operation runs in a savepoint so every failure is reported before rolling back
+		err := db.Transaction(func(tx *gorm.DB) error {
+			applyOperations(tx)
+			if failed > 0 {
+				return errors.New("batch failed")
+			}
+			return nil
+		})
+		if failed > 0 {
+			// The operations that succeeded were rolled back with the rest
+			for i := range results {
+				if results[i].Error == "" {
+					results[i].Status = http.StatusFailedDependency
+					results[i].Variant = nil
+					results[i].Error = "Not applied because another operation failed"
+				}
+			}
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No operation was applied because some of them failed", "results": results})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to apply operations"})
+			return
+		}
+	} else {
+		applyOperations(db)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results, "succeeded": len(results) - failed, "failed": failed})
+}
+
+// loadBatchRecords loads the admin's variants updated or deleted by the operations and the
+// admin's products variants are created in, by UUID. Records of other catalogs are left out.
+func loadBatchRecords(db *gorm.DB, adminUUID uuid.UUID, operations []BatchVariantOperation) (map[string]models.Variant, map[string]models.Product, error) {
+	var variantUUIDs, productUUIDs []string
+	for _, operation := range operations {
+		if operation.VariantUUID != "" {
+			variantUUIDs = append(variantUUIDs, operation.VariantUUID)
+		}
+		if operation.Variant.ProductUUID != nil {
+			productUUIDs = append(productUUIDs, *operation.Variant.ProductUUID)
+		}
+	}
+
+	variants := map[string]models.Variant{}
+	if len(variantUUIDs) > 0 {
+		var found []models.Variant
+		if err := utils.CatalogVariants(db, adminUUID).Select("variants.*").Preload("Attributes").
+			Where("variants.uuid IN ?", variantUUIDs).Find(&found).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, variant := range found {
+			variants[variant.UUID] = variant
+		}
+	}
+
+	products := map[string]models.Product{}
+	if len(productUUIDs) > 0 {
+		var found []models.Product
+		if err := db.Preload("Options").Where("uuid IN ? AND admin_uuid = ?", productUUIDs, adminUUID).Find(&found).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, product := range found {
+			products[product.UUID] = product
+		}
+	}
+
+	return variants, products, nil
+}
+
+// applyBatchOperation applies one operation of a batch request with the rules of the variant
+// endpoints, returning the variant created, updated or deleted.
+func applyBatchOperation(tx *gorm.DB, adminUUID uuid.UUID, operation BatchVariantOperation, variants map[string]models.Variant, products map[string]models.Product) (models.Variant, error) {
+	if operation.Op == batchOpCreate {
+		if operation.Variant.ProductUUID == nil {
+			return models.Variant{}, &batchError{http.StatusBadRequest, errors.New("product_uuid is required")}
+		}
+		product, ok := products[*operation.Variant.ProductUUID]
+		if !ok {
+			return models.Variant{}, &batchError{http.StatusNotFound, errors.New("Product not found")}
+		}
+
+		variant := models.Variant{ProductUUID: uuid.MustParse(product.UUID)}
+		if err := setBatchVariantFields(tx, adminUUID, &variant, operation.Variant); err != nil {
+			return models.Variant{}, err
+		}
+		attributes, err := utils.ValidateVariantAttributes(product.Options, operation.Variant.Attributes)
+		if err != nil {
+			return models.Variant{}, &batchError{http.StatusBadRequest, err}
+		}
+		if err := utils.CreateVariantWithAttributes(tx, &variant, attributes); err != nil {
+			return models.Variant{}, err
+		}
+		return variant, utils.RecordVariantEvent(tx, adminUUID, models.EventVariantCreated, variant)
+	}
+
+	if operation.VariantUUID == "" {
+		return models.Variant{}, &batchError{http.StatusBadRequest, errors.New("variant_uuid is required")}
+	}
+	if _, ok := variants[operation.VariantUUID]; !ok {
+		return models.Variant{}, &batchError{http.StatusNotFound, errors.New("Variant not found")}
+	}
+
+	// Read the variant again under lock, so stock sold since the batch was loaded is not overwritten
+	var variant models.Variant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Attributes").
+		Where("uuid = ?", operation.VariantUUID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Variant{}, &batchError{http.StatusNotFound, errors.New("Variant not found")}
+		}
+		return models.Variant{}, err
+	}
+
+	if operation.Op == batchOpDelete {
+		if err := utils.CheckComponentInUse(tx, variant.UUID); err != nil {
+			if errors.Is(err, utils.ErrComponentInUse) {
+				return models.Variant{}, &batchError{http.StatusBadRequest, err}
+			}
+			return models.Variant{}, err
+		}
+		if err := tx.Where("variant_uuid = ?", variant.UUID).Delete(&models.VariantAttribute{}).Error; err != nil {
+			return models.Variant{}, err
+		}
+		if err := tx.Where("bundle_variant_uuid = ?", variant.UUID).Delete(&models.BundleComponent{}).Error; err != nil {
+			return models.Variant{}, err
+		}
+		if err := tx.Delete(&variant).Error; err != nil {
+			return models.Variant{}, err
+		}
+		return variant, utils.RecordVariantEvent(tx, adminUUID, models.EventVariantDeleted, variant)
+	}
+
+	if operation.Variant.ProductUUID != nil && *operation.Variant.ProductUUID != variant.ProductUUID.String() {
+		return models.Variant{}, &batchError{http.StatusBadRequest, errors.New("A variant cannot be moved to another product")}
+	}
+
+	var options []models.ProductOption
+	if err := tx.Where("product_uuid = ?", variant.ProductUUID).Find(&options).Error; err != nil {
+		return models.Variant{}, err
+	}
+	previousQuantity := variant.Quantity
+	if err := setBatchVariantFields(tx, adminUUID, &variant, operation.Variant); err != nil {
+		return models.Variant{}, err
+	}
+	if err := utils.SaveVariant(tx, &variant); err != nil {
+		return models.Variant{}, err
+	}
+	if err := utils.RecordStockChange(tx, variant, previousQuantity); err != nil {
+		return models.Variant{}, err
+	}
+	if operation.Variant.Attributes != nil {
+		attributes, err := utils.ValidateVariantAttributes(options, operation.Variant.Attributes)
+		if err != nil {
+			return models.Variant{}, &batchError{http.StatusBadRequest, err}
+		}
+		if err := utils.ReplaceVariantAttributes(tx, &variant, attributes); err != nil {
+			return models.Variant{}, err
+		}
+	}
+	return variant, utils.RecordVariantEvent(tx, adminUUID, models.EventVariantUpdated, variant)
+}
+
+// setBatchVariantFields sets the fields given in a batch operation on the variant and checks
+// the result with the rules of CreateVariantRequest, and that the SKU and barcode are valid
+// and unused in the admin's catalog.
+func setBatchVariantFields(tx *gorm.DB, adminUUID uuid.UUID, variant *models.Variant, fields BatchVariantFields) error {
+	variantReq := CreateVariantRequest{
+		ProductUUID: variant.ProductUUID.String(),
+		VariantName: variant.VariantName,
+		Quantity:    fields.Quantity,
+		Price:       variant.Price,
+		SKU:         fields.SKU,
+		Barcode:     fields.Barcode,
+	}
+	if fields.VariantName != nil {
+		variantReq.VariantName = *fields.VariantName
+	}
+	if variantReq.Quantity == nil && variant.ID != 0 {
+		// Updates keep the current quantity
+		variantReq.Quantity = &variant.Quantity
+	}
+	if fields.Price != nil {
+		variantReq.Price = *fields.Price
+	}
+	if err := validateVariantRequest(variantReq); err != nil {
+		return &batchError{http.StatusBadRequest, err}
+	}
+	if variantReq.Price < 0 {
+		return &batchError{http.StatusBadRequest, errors.New("Price must not be negative")}
+	}
+
+	variant.VariantName = variantReq.VariantName
+	variant.Quantity = *variantReq.Quantity
+	variant.Price = variantReq.Price
+
+	var err error
+	if fields.SKU != nil {
+		if variant.SKU, err = utils.NormalizeSKU(*fields.SKU); err != nil {
+			return &batchError{http.StatusBadRequest, err}
+		}
+	}
+	if fields.Barcode != nil {
+		if variant.Barcode, err = utils.NormalizeGTIN(*fields.Barcode); err != nil {
+			return &batchError{http.StatusBadRequest, err}
+		}
+	}
+	if err := utils.CheckVariantIdentifiers(tx, adminUUID, *variant); err != nil {
+		if errors.Is(err, utils.ErrDuplicateSKU) || errors.Is(err, utils.ErrDuplicateBarcode) {
+			return &batchError{http.StatusConflict, err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// batchErrorStatus returns the HTTP status of a failed batch operation.
+func batchErrorStatus(err error) int {
+	var opErr *batchError
+	if errors.As(err, &opErr) {
+		return opErr.status
+	}
+	if errors.Is(err, utils.ErrDuplicateSKU) || errors.Is(err, utils.ErrDuplicateBarcode) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -5,9 +5,8 @@ package controllers
 import (
 	"basictrade/models"
 	"basictrade/utils"
-	"math"
+	"errors"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/asaskevich/govalidator"
@@ -15,52 +14,106 @@ import (
 	jwt5 "github.com/golang-jwt/jwt/v5"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // CreateVariantRequest represents the request body for creating a new variant.
 type CreateVariantRequest struct {
 	ProductUUID  string `form:"product_uuid" json:"product_uuid"`
     VariantName string `form:"variant_name" json:"variant_name" valid:"required"`
-    Quantity    uint   `form:"quantity" json:"quantity" valid:"required"`
+    Quantity    *uint  `form:"quantity" json:"quantity" binding:"required"`
+    Price       float64 `form:"price" json:"price"`
+    SKU         *string `form:"sku" json:"sku"`
+    Barcode     *string `form:"barcode" json:"barcode"`
+    Attributes  map[string]string `form:"-" json:"attributes"`
+}
+
+// errQuantityRequired is returned when a variant request leaves out the quantity.
+var errQuantityRequired = errors.New("quantity: required")
+
+// validateVariantRequest checks a variant request with its validation rules, and that it gives
+// a quantity, which may be 0.
+func validateVariantRequest(req CreateVariantRequest) error {
+	if _, err := govalidator.ValidateStruct(req); err != nil {
+		return err
+	}
+	if req.Quantity == nil {
+		return errQuantityRequired
+	}
+	return nil
 }
 
 func GetAllVariants(c *gin.Context) {
 	db := utils.GetDB()
 
-    // Parse query parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "5"))
-	variantName := strings.TrimSpace(c.Query("variantName"))
-
-	// Pagination logic
-	offset := (page - 1) * pageSize
+	// Resolve the currency variant prices are reported in
+	conversion, ok := resolveCurrency(c, db)
+	if !ok {
+		return
+	}
 
-	// Build the query
-	query := db.Model(&models.Variant{})
+	// Build the query from the filters
+	query, ok := filterVariants(c, db, db.Model(&models.Variant{}).Preload("Attributes"))
+	if !ok {
+		return
+	}
 
-	// Apply search filter if name is provided
-	if variantName!= "" {
-		query = query.Where("variant_name LIKE ?", "%"+variantName+"%")
+	// Count facets and parse the requested page
+	facets, pagination, ok := applyListQuery(c, query, utils.VariantListSpec)
+	if !ok {
+		return
 	}
 
-    // Fetch total count of products
-    var totalItems int64
-    if err := query.Count(&totalItems).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
-        return 
+	// Fetch variants with pagination
+    var variants []models.Variant
+    response, ok := fetchListPage(c, query, pagination, &variants)
+    if !ok {
+        return
+    }
+
+    // Compute bundle availability and convert variant prices to the requested currency
+    if err := utils.FillBundleAvailability(db, variants); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle components"})
+        return
     }
+    convertVariantPrices(variants, conversion)
 
-	// Fetch products with pagination
-    var variants []models.Variant
-    if err := query.Offset(offset).Limit(pageSize).Find(&variants).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
-		return
+    response["variants"] = variants
+    if facets != nil {
+        response["facets"] = facets
+    }
+    if conversion != nil {
+        response["exchangeRate"] = conversion
+    }
+
+    c.JSON(http.StatusOK, response)
+}
+
+// filterVariants applies the filters of GET /products/variants to query: variantName,
+// attr[name] and filter[field][op]. It writes an error response on failure.
+func filterVariants(c *gin.Context, db *gorm.DB, query *gorm.DB) (*gorm.DB, bool) {
+	// Parse query parameters
+	variantName := strings.TrimSpace(c.Query("variantName"))
+	attributes := c.QueryMap("attr")
+
+	// Apply search filter if name is provided
+	if variantName != "" {
+		query = query.Where("variant_name LIKE ?", utils.ContainsPattern(variantName))
 	}
 
-    // Calculate total pages
-    totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+	// Apply attribute filters, e.g. attr[Color]=Red&attr[Size]=XL
+	for name, value := range attributes {
+		query = query.Where("uuid IN (?)", utils.VariantsWithAttribute(db, name, value))
+	}
 
-    c.JSON(http.StatusOK, gin.H{"variants": variants, "totalItems": totalItems, "totalPages": totalPages})
+	// Apply filter[field][op] parameters
+	query, err := utils.ApplyFilters(query, utils.VariantListSpec, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return query, true
 }
 
 // CreateVariant creates a new variant for a specific product.
@@ -80,10 +133,14 @@ func CreateVariant(c *gin.Context) {
 			return
 		}
 	}
-	if _, err := govalidator.ValidateStruct(createReq); err != nil {
+	if err := validateVariantRequest(createReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if createReq.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must not be negative"})
+		return
+	}
 
 	// Convert product UUID string to uuid.UUID
     productUUID, err := uuid.Parse(createReq.ProductUUID)
@@ -122,15 +179,36 @@ func CreateVariant(c *gin.Context) {
          return
      }
 
+    // Check the attributes against the product options
+    attributes, ok := validateVariantAttributes(c, db, productUUID, createReq.Attributes)
+    if !ok {
+        return
+    }
+
     // Create a new variant
     newVariant := models.Variant{
         VariantName: createReq.VariantName,
-        Quantity:    createReq.Quantity,
+        Quantity:    *createReq.Quantity,
+        Price:       createReq.Price,
         ProductUUID:   productUUID,
     }
 
-    // Save the new variant to the database
-    if err := db.Create(&newVariant).Error; err != nil {
+    // Check the SKU and barcode are valid and unused in the catalog
+    if !applyVariantIdentifiers(c, db, adminUUID, &newVariant, createReq.SKU, createReq.Barcode) {
+        return
+    }
+
+    // Save the new variant and its attributes to the database with the event reporting them
+    if err := db.Transaction(func(tx *gorm.DB) error {
+        if err := utils.CreateVariantWithAttributes(tx, &newVariant, attributes); err != nil {
+            return err
+        }
+        return utils.RecordVariantEvent(tx, adminUUID, models.EventVariantCreated, newVariant)
+    }); err != nil {
+        if errors.Is(err, utils.ErrDuplicateSKU) || errors.Is(err, utils.ErrDuplicateBarcode) {
+            c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to create variant"})
         return
     }
@@ -166,10 +244,14 @@ func UpdateVariant(c *gin.Context) {
 			return
 		}
 	}
-	if _, err := govalidator.ValidateStruct(updateReq); err != nil {
+	if err := validateVariantRequest(updateReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if updateReq.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must not be negative"})
+		return
+	}
 
     // Check if the variant exists
     var existingVariant models.Variant
@@ -208,12 +290,45 @@ func UpdateVariant(c *gin.Context) {
         return
     }
 
+    // Check the attributes against the product options
+    attributes, ok := validateVariantAttributes(c, db, existingVariant.ProductUUID, updateReq.Attributes)
+    if !ok {
+        return
+    }
+
     // Update variant details
+    previousQuantity := existingVariant.Quantity
     existingVariant.VariantName = updateReq.VariantName
-    existingVariant.Quantity = updateReq.Quantity
+    existingVariant.Quantity = *updateReq.Quantity
+    existingVariant.Price = updateReq.Price
+
+    // Check the SKU and barcode are valid and unused in the catalog
+    if !applyVariantIdentifiers(c, db, adminUUID, &existingVariant, updateReq.SKU, updateReq.Barcode) {
+        return
+    }
 
-    // Save the updated variant details
-    if err := db.Save(&existingVariant).Error; err != nil {
+    // Save the updated variant details, replacing the attributes when they are given, with the
+    // events reporting them
+    if err := db.Transaction(func(tx *gorm.DB) error {
+        if err := utils.SaveVariant(tx, &existingVariant); err != nil {
+            return err
+        }
+        if err := utils.RecordStockChange(tx, existingVariant, previousQuantity); err != nil {
+            return err
+        }
+        if updateReq.Attributes == nil {
+            if err := tx.Where("variant_uuid = ?", existingVariant.UUID).Find(&existingVariant.Attributes).Error; err != nil {
+                return err
+            }
+        } else if err := utils.ReplaceVariantAttributes(tx, &existingVariant, attributes); err != nil {
+            return err
+        }
+        return utils.RecordVariantEvent(tx, adminUUID, models.EventVariantUpdated, existingVariant)
+    }); err != nil {
+        if errors.Is(err, utils.ErrDuplicateSKU) || errors.Is(err, utils.ErrDuplicateBarcode) {
+            c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to update variant"})
         return
     }
@@ -272,8 +387,25 @@ func DeleteVariant(c *gin.Context) {
         return
     }
 
-    // Delete the variant
-    if err := db.Delete(&existingVariant).Error; err != nil {
+    // Check the variant is not part of a bundle
+    if err := utils.CheckComponentInUse(db, existingVariant.UUID); err != nil {
+        respondBundleError(c, err, "Please remove the variant from its bundles first")
+        return
+    }
+
+    // Delete the variant with its attributes and components, with the event reporting it
+    if err := db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Where("variant_uuid = ?", existingVariant.UUID).Delete(&models.VariantAttribute{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Where("bundle_variant_uuid = ?", existingVariant.UUID).Delete(&models.BundleComponent{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Delete(&existingVariant).Error; err != nil {
+            return err
+        }
+        return utils.RecordVariantEvent(tx, adminUUID, models.EventVariantDeleted, existingVariant)
+    }); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to delete variant"})
         return
     }
@@ -297,7 +429,7 @@ func GetVariantDetail(c *gin.Context) {
 
     // Check if the variant exists
     var existingVariant models.Variant
-    if err := db.Where("uuid = ?", variantUUID).First(&existingVariant).Error; err != nil {
+    if err := db.Preload("Attributes").Where("uuid = ?", variantUUID).First(&existingVariant).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": "Variant not found"})
         return
     }
@@ -332,5 +464,13 @@ func GetVariantDetail(c *gin.Context) {
         return
     }
 
+    // Compute bundle availability
+    variants := []models.Variant{existingVariant}
+    if err := utils.FillBundleAvailability(db, variants); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch bundle components"})
+        return
+    }
+    existingVariant = variants[0]
+
     c.JSON(http.StatusOK, gin.H{"variant": existingVariant})
 }
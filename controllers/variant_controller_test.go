@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/asaskevich/govalidator"
+)
+
+func TestValidateVariantRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"quantity given", `{"variant_name": "Large", "quantity": 5}`, false},
+		{"quantity of zero", `{"variant_name": "Large", "quantity": 0}`, false},
+		{"quantity left out", `{"variant_name": "Large"}`, true},
+		{"name left out", `{"quantity": 5}`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var req CreateVariantRequest
+			if err := json.Unmarshal([]byte(test.body), &req); err != nil {
+				t.Fatal(err)
+			}
+
+			err := validateVariantRequest(req)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("validateVariantRequest() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateVariantRequestReportsMissingQuantity(t *testing.T) {
+	var req CreateVariantRequest
+	if err := json.Unmarshal([]byte(`{"variant_name": "Large"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateVariantRequest(req); !errors.Is(err, errQuantityRequired) {
+		t.Fatalf("validateVariantRequest() error = %v, want %v", err, errQuantityRequired)
+	}
+}
+
+func TestProductVariantsValidateWithoutQuantity(t *testing.T) {
+	// The product's own rules leave the quantity of its variants to validateVariantRequest
+	req := ProductCreateRequest{
+		ProductUpdateRequest: ProductUpdateRequest{ProductName: "Shirt"},
+		Variants:             []CreateVariantRequest{{VariantName: "Large"}},
+	}
+	if _, err := govalidator.ValidateStruct(req); err != nil {
+		t.Fatalf("ValidateStruct() error = %v", err)
+	}
+	if err := validateVariantRequest(req.Variants[0]); !errors.Is(err, errQuantityRequired) {
+		t.Fatalf("validateVariantRequest() error = %v, want %v", err, errQuantityRequired)
+	}
+}
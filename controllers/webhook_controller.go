@@ -0,0 +1,237 @@
+// controllers/webhook_controller.go
+
+package controllers
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WebhookRequest represents the request body for creating or updating a webhook subscription.
+type WebhookRequest struct {
+	URL    string   `json:"url" valid:"required,url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret" valid:"length(16|128)"`
+	Active *bool    `json:"active"`
+}
+
+// GetAllWebhooks retrieves the admin's webhook subscriptions.
+func GetAllWebhooks(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	var webhooks []models.WebhookSubscription
+	if err := db.Where("admin_uuid = ?", adminUUID).Order("created_at").Find(&webhooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+// CreateWebhook subscribes a URL to catalog events. The secret deliveries are signed with is
+// generated unless one is given, and is only returned here.
+func CreateWebhook(c *gin.Context) {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		return
+	}
+
+	db := utils.GetDB()
+
+	webhookReq, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	secret := webhookReq.Secret
+	if secret == "" {
+		if secret, err = utils.NewWebhookSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to generate secret"})
+			return
+		}
+	}
+
+	newWebhook := models.WebhookSubscription{
+		URL:       webhookReq.URL,
+		Secret:    secret,
+		Events:    webhookReq.Events,
+		Active:    webhookReq.Active == nil || *webhookReq.Active,
+		AdminUUID: adminUUID,
+	}
+
+	if err := db.Create(&newWebhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"webhook": newWebhook, "secret": secret})
+}
+
+// GetWebhookDetail retrieves the details of a webhook subscription.
+func GetWebhookDetail(c *gin.Context) {
+	existingWebhook := c.MustGet("webhook").(models.WebhookSubscription)
+
+	c.JSON(http.StatusOK, gin.H{"webhook": existingWebhook})
+}
+
+// UpdateWebhook updates the URL, events and status of a webhook subscription, and replaces
+// its secret when one is given.
+func UpdateWebhook(c *gin.Context) {
+	db := utils.GetDB()
+	existingWebhook := c.MustGet("webhook").(models.WebhookSubscription)
+
+	webhookReq, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	existingWebhook.URL = webhookReq.URL
+	existingWebhook.Events = webhookReq.Events
+	if webhookReq.Active != nil {
+		existingWebhook.Active = *webhookReq.Active
+	}
+	if webhookReq.Secret != "" {
+		existingWebhook.Secret = webhookReq.Secret
+	}
+
+	if err := db.Save(&existingWebhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": existingWebhook})
+}
+
+// DeleteWebhook deletes a webhook subscription. Its delivery logs are kept, and its pending
+// deliveries fail on their next attempt.
+func DeleteWebhook(c *gin.Context) {
+	db := utils.GetDB()
+	existingWebhook := c.MustGet("webhook").(models.WebhookSubscription)
+
+	if err := db.Delete(&existingWebhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries retrieves the deliveries of a webhook subscription, newest first,
+// optionally filtered by status and event type.
+func GetWebhookDeliveries(c *gin.Context) {
+	db := utils.GetDB()
+	existingWebhook := c.MustGet("webhook").(models.WebhookSubscription)
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	status := strings.TrimSpace(c.Query("status"))
+	eventType := strings.TrimSpace(c.Query("event"))
+
+	if page < 1 || pageSize < 1 || pageSize > utils.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": utils.ErrInvalidPage.Error()})
+		return
+	}
+
+	query := db.Model(&models.WebhookDelivery{}).Where("subscription_uuid = ?", existingWebhook.UUID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total items"})
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch deliveries"})
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(totalItems) / float64(pageSize)))
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "totalItems": totalItems, "totalPages": totalPages})
+}
+
+// GetWebhookDeliveryDetail retrieves a delivery with the log of its attempts.
+func GetWebhookDeliveryDetail(c *gin.Context) {
+	db := utils.GetDB()
+	existingDelivery := c.MustGet("webhookDelivery").(models.WebhookDelivery)
+
+	if err := db.Where("delivery_uuid = ?", existingDelivery.UUID).Order("attempt").Find(&existingDelivery.Logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch delivery logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delivery": existingDelivery})
+}
+
+// ReplayWebhookDelivery queues the event of a delivery again, whatever its status.
+func ReplayWebhookDelivery(c *gin.Context) {
+	db := utils.GetDB()
+	existingDelivery := c.MustGet("webhookDelivery").(models.WebhookDelivery)
+
+	// Only replay to subscriptions that still exist
+	var subscription models.WebhookSubscription
+	if err := db.Where("uuid = ?", existingDelivery.SubscriptionUUID).First(&subscription).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The delivery's webhook was deleted"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch webhook"})
+		return
+	}
+
+	replay, err := utils.ReplayWebhookDelivery(db, existingDelivery)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to replay delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"delivery": replay})
+}
+
+// bindWebhookRequest parses and validates a webhook request, writing an error response on failure.
+func bindWebhookRequest(c *gin.Context) (WebhookRequest, bool) {
+	var webhookReq WebhookRequest
+	if err := c.ShouldBindJSON(&webhookReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return webhookReq, false
+	}
+	webhookReq.URL = strings.TrimSpace(webhookReq.URL)
+	if _, err := govalidator.ValidateStruct(webhookReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return webhookReq, false
+	}
+	if err := utils.ValidateWebhookURL(c.Request.Context(), webhookReq.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return webhookReq, false
+	}
+	if err := utils.ValidateEventTypes(webhookReq.Events); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return webhookReq, false
+	}
+
+	return webhookReq, true
+}
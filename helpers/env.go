@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// getEnv returns the value of the environment variable or the fallback when it is unset.
+func getEnv(key, fallback string) string {
+	// The .env file is optional here, StartDB already requires it at boot
+	_ = godotenv.Load()
+
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// EnvBaseCurrency returns the ISO 4217 code variant prices are stored in.
+func EnvBaseCurrency() string {
+	return strings.ToUpper(getEnv("BASE_CURRENCY", "USD"))
+}
+
+// EnvCartIdleTimeout returns how long a cart lives without activity before it expires.
+func EnvCartIdleTimeout() time.Duration {
+	timeout, err := time.ParseDuration(getEnv("CART_IDLE_TIMEOUT", "72h"))
+	if err != nil || timeout <= 0 {
+		log.Println("Invalid CART_IDLE_TIMEOUT, using 72h")
+		return 72 * time.Hour
+	}
+	return timeout
+}
+
+// EnvSearchIndexPath returns the directory of the search index, or an empty string to keep
+// the index in memory.
+func EnvSearchIndexPath() string {
+	return getEnv("SEARCH_INDEX_PATH", "")
+}
+
+// EnvJobWorkers returns how many background jobs are run at the same time.
+func EnvJobWorkers() int {
+	workers, err := strconv.Atoi(getEnv("JOB_WORKERS", "4"))
+	if err != nil || workers < 1 {
+		log.Println("Invalid JOB_WORKERS, using 4")
+		return 4
+	}
+	return workers
+}
+
+// EnvIdempotencyKeyTTL returns how long the response to a request with an Idempotency-Key is
+// replayed for.
+func EnvIdempotencyKeyTTL() time.Duration {
+	ttl, err := time.ParseDuration(getEnv("IDEMPOTENCY_KEY_TTL", "24h"))
+	if err != nil || ttl <= 0 {
+		log.Println("Invalid IDEMPOTENCY_KEY_TTL, using 24h")
+		return 24 * time.Hour
+	}
+	return ttl
+}
@@ -3,16 +3,39 @@
 package main
 
 import (
+	"basictrade/controllers"
+	"basictrade/helpers"
 	"basictrade/routes"
 	database "basictrade/utils"
+	"context"
+	"errors"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout bounds how long requests and running jobs have to finish on shutdown.
+const shutdownTimeout = 30 * time.Second
 
 func main() {
 	// Start the database connection
 	database.StartDB()
 
+	// Open the search index and rebuild it in the background
+	database.StartSearch()
+
+	// Run the background jobs, including the scheduled cart purge and product publishing
+	controllers.RegisterJobs()
+	jobs := database.StartJobs(helpers.EnvJobWorkers())
+
+	// Publish the domain events of the outbox to their subscribers, and send queued webhook
+	// deliveries, until the jobs shut down
+	database.StartEventBus(jobs)
+	jobs.Go(func(stop <-chan struct{}) { database.DeliverWebhooksEvery(5*time.Second, stop) })
+
 	// Get the port from the environment variable or use a default value
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -21,5 +44,30 @@ func main() {
 
 	// Start the application on the specified port
 	r := routes.StartApp()
-	r.Run(":" + port)
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	server.RegisterOnShutdown(database.CloseEventStreams)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("error starting server: ", err)
+		}
+	}()
+
+	// Wait for a signal, then finish the requests and the running jobs
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Failed to finish the requests:", err)
+	}
+	if err := jobs.Shutdown(shutdownCtx); err != nil {
+		log.Println("Failed to finish the running jobs and background loops:", err)
+	}
 }
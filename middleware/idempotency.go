@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"basictrade/utils"
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt5 "github.com/golang-jwt/jwt/v5"
+)
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key accepted.
+const maxIdempotencyKeyLength = 255
+
+// idempotencyExcludedPrefixes are the paths whose responses are never stored, as they carry
+// credentials such as the token returned by a login.
+var idempotencyExcludedPrefixes = []string{"/auth/"}
+
+// idempotencyAdminUUID returns the adminUUID claim of the request's token when it is valid.
+// The middleware runs before the routes' own authentication, so it verifies the token itself.
+func idempotencyAdminUUID(c *gin.Context) string {
+	claims, err := utils.VerifyToken(c)
+	if err != nil {
+		return ""
+	}
+	adminData, _ := claims.(jwt5.MapClaims)
+	adminUUID, _ := adminData["adminUUID"].(string)
+	return adminUUID
+}
+
+// responseRecorder keeps a copy of the response body written by a handler.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// IdempotencyMiddleware makes POST requests sent with an Idempotency-Key header safe to
+// retry. The response to the first request with a key is stored, and replayed to the repeats
+// of the request from the same admin until the key expires. Reusing a key for a
+// different request answers 422, and repeating a request still being processed answers 409.
+// Server errors are not stored, so the request can be retried. A request whose response
+// could not be stored keeps its key reserved until utils.IdempotencyLockTimeout, rather than
+// letting a retry run it again at once. The auth routes are not covered.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	ttl := helpers.EnvIdempotencyKeyTTL()
+
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(utils.IdempotencyKeyHeader))
+		if c.Request.Method != http.MethodPost || key == "" || idempotencyExcluded(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		// Read the body to fingerprint the request, and put it back for the handler
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "messages": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		db := utils.GetDB()
+		now := time.Now()
+		fingerprint := utils.RequestFingerprint(c.Request, body)
+
+		record, reserved, err := utils.ReserveIdempotencyKey(db, models.IdempotencyKey{
+			Key:         key,
+			Scope:       utils.IdempotencyScope(idempotencyAdminUUID(c)),
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: fingerprint,
+			ExpiresAt:   now.Add(ttl),
+		}, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to check Idempotency-Key"})
+			c.Abort()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.RequestHash != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case !record.Completed:
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// Forget the key when the request fails or panics, so it can be retried
+		completed := false
+		defer func() {
+			if !completed {
+				if err := utils.ReleaseIdempotencyKey(db, record); err != nil {
+					log.Println("Failed to release Idempotency-Key", key+":", err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		// The request had its effects, so keep the key reserved even if the response is not stored
+		completed = true
+		if err := utils.CompleteIdempotencyKey(db, &record, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			log.Println("Failed to store the response of Idempotency-Key", key+":", err)
+		}
+	}
+}
+
+// idempotencyExcluded reports whether the responses of a path are never stored.
+func idempotencyExcluded(path string) bool {
+	for _, prefix := range idempotencyExcludedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"basictrade/models"
 	"basictrade/utils"
 	"net/http"
+	"strings"
 
 	jwt5 "github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -110,7 +111,7 @@ func ValidateVariantAuthorization() gin.HandlerFunc {
         }
 
         var existingProduct models.Product
-        if err := db.Model(&existingVariant).Association("Product").Find(&existingProduct); err != nil {
+        if err := db.Where("uuid = ?", existingVariant.ProductUUID).First(&existingProduct).Error; err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get associated product"})
             c.Abort()
             return
@@ -122,7 +123,198 @@ func ValidateVariantAuthorization() gin.HandlerFunc {
             return
         }
 
+        c.Set("variant", existingVariant)
+
         // Continue with the next middleware or the main handler
         c.Next()
     }
 }
+
+// validateAdminResource loads the record identified by the URL parameter into record and
+// checks that owner, called after loading, matches the authenticated admin.
+func validateAdminResource(c *gin.Context, param string, name string, record interface{}, owner func() uuid.UUID) bool {
+	adminUUID, err := utils.GetAdminUUID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "message": err.Error()})
+		c.Abort()
+		return false
+	}
+
+	// Extract the record UUID from the request URL
+	recordUUID, err := uuid.Parse(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + strings.ToLower(name) + " UUID format"})
+		c.Abort()
+		return false
+	}
+
+	db := utils.GetDB()
+	if err := db.Where("uuid = ?", recordUUID).First(record).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "messages": name + " not found"})
+		c.Abort()
+		return false
+	}
+
+	if owner() != adminUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to perform this operation"})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// ValidatePromotionAuthorization checks that the promotion in the URL belongs to the admin.
+func ValidatePromotionAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingPromotion models.Promotion
+		if !validateAdminResource(c, "promotionUUID", "Promotion", &existingPromotion, func() uuid.UUID { return existingPromotion.AdminUUID }) {
+			return
+		}
+
+		// Set the promotion in the context for later use
+		c.Set("promotion", existingPromotion)
+
+		c.Next()
+	}
+}
+
+// ValidateOrderAuthorization checks that the order in the URL belongs to the admin.
+func ValidateOrderAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingOrder models.Order
+		if !validateAdminResource(c, "orderUUID", "Order", &existingOrder, func() uuid.UUID { return existingOrder.AdminUUID }) {
+			return
+		}
+
+		// Set the order in the context for later use
+		c.Set("order", existingOrder)
+
+		c.Next()
+	}
+}
+
+// ValidateReturnAuthorization checks that the return in the URL belongs to the admin.
+func ValidateReturnAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingReturn models.Return
+		if !validateAdminResource(c, "returnUUID", "Return", &existingReturn, func() uuid.UUID { return existingReturn.AdminUUID }) {
+			return
+		}
+
+		// Set the return in the context for later use
+		c.Set("return", existingReturn)
+
+		c.Next()
+	}
+}
+
+// ValidateSupplierAuthorization checks that the supplier in the URL belongs to the admin.
+func ValidateSupplierAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingSupplier models.Supplier
+		if !validateAdminResource(c, "supplierUUID", "Supplier", &existingSupplier, func() uuid.UUID { return existingSupplier.AdminUUID }) {
+			return
+		}
+
+		// Set the supplier in the context for later use
+		c.Set("supplier", existingSupplier)
+
+		c.Next()
+	}
+}
+
+// ValidatePurchaseOrderAuthorization checks that the purchase order in the URL belongs to the admin.
+func ValidatePurchaseOrderAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingPurchaseOrder models.PurchaseOrder
+		if !validateAdminResource(c, "purchaseOrderUUID", "Purchase order", &existingPurchaseOrder, func() uuid.UUID { return existingPurchaseOrder.AdminUUID }) {
+			return
+		}
+
+		// Set the purchase order in the context for later use
+		c.Set("purchaseOrder", existingPurchaseOrder)
+
+		c.Next()
+	}
+}
+
+// ValidateCategoryAuthorization checks that the category in the URL belongs to the admin.
+func ValidateCategoryAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingCategory models.Category
+		if !validateAdminResource(c, "categoryUUID", "Category", &existingCategory, func() uuid.UUID { return existingCategory.AdminUUID }) {
+			return
+		}
+
+		// Set the category in the context for later use
+		c.Set("category", existingCategory)
+
+		c.Next()
+	}
+}
+
+// ValidateImportAuthorization checks that the import in the URL belongs to the admin.
+func ValidateImportAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingImport models.Import
+		if !validateAdminResource(c, "importUUID", "Import", &existingImport, func() uuid.UUID { return existingImport.AdminUUID }) {
+			return
+		}
+
+		// Set the import in the context for later use
+		c.Set("import", existingImport)
+
+		c.Next()
+	}
+}
+
+// ValidateWebhookAuthorization checks that the webhook subscription in the URL belongs to the admin.
+func ValidateWebhookAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingWebhook models.WebhookSubscription
+		if !validateAdminResource(c, "webhookUUID", "Webhook", &existingWebhook, func() uuid.UUID { return existingWebhook.AdminUUID }) {
+			return
+		}
+
+		// Set the webhook in the context for later use
+		c.Set("webhook", existingWebhook)
+
+		c.Next()
+	}
+}
+
+// ValidateWebhookDeliveryAuthorization checks that the webhook delivery in the URL belongs to the admin.
+func ValidateWebhookDeliveryAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingDelivery models.WebhookDelivery
+		if !validateAdminResource(c, "deliveryUUID", "Webhook delivery", &existingDelivery, func() uuid.UUID { return existingDelivery.AdminUUID }) {
+			return
+		}
+
+		// Set the delivery in the context for later use
+		c.Set("webhookDelivery", existingDelivery)
+
+		c.Next()
+	}
+}
+
+// ValidateJobAuthorization checks that the background job in the URL belongs to the admin.
+func ValidateJobAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var existingJob models.Job
+		if !validateAdminResource(c, "jobUUID", "Job", &existingJob, func() uuid.UUID {
+			if existingJob.AdminUUID == nil {
+				return uuid.Nil
+			}
+			return *existingJob.AdminUUID
+		}) {
+			return
+		}
+
+		// Set the job in the context for later use
+		c.Set("job", existingJob)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"basictrade/models"
+	"basictrade/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	jwt5 "github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// RequireRole lets through only admins with the role. The role is read from the database on
+// each request, so a change applies without signing in again.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Access claims from the context
+		adminData, exists := c.MustGet("adminData").(jwt5.MapClaims)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		adminUUID, _ := adminData["adminUUID"].(string)
+
+		var admin models.Admin
+		if err := utils.GetDB().Select("role").Where("uuid = ?", adminUUID).First(&admin).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "messages": "Failed to fetch admin"})
+			}
+			c.Abort()
+			return
+		}
+
+		if admin.Role != role {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to perform this operation"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
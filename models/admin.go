@@ -9,6 +9,13 @@ import (
 	"gorm.io/gorm"
 )
 
+// Admin roles. Every admin manages their own catalog; superadmins also manage the settings
+// shared by every catalog, such as exchange rates.
+const (
+	AdminRoleAdmin      = "admin"
+	AdminRoleSuperadmin = "superadmin"
+)
+
 // Admin represents the admin model.
 type Admin struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
@@ -16,6 +23,7 @@ type Admin struct {
 	Name      string `gorm:"not null" json:"name"`
 	Email     string `gorm:"unique;not null" json:"email"`
 	Password  string `gorm:"not null" json:"password"`
+	Role      string `gorm:"type:varchar(20);not null;default:admin" json:"role"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
     Products []Product `gorm:"foreignKey:AdminUUID;references:UUID"`
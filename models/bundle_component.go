@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BundleComponent is a variant, and the quantity of it, contained in one unit of a bundle
+// variant. Bundle variants have no stock of their own: selling one takes its components
+// out of stock.
+type BundleComponent struct {
+	ID                   uint      `gorm:"primaryKey" json:"-"`
+	BundleVariantUUID    uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_bundle_component" json:"-"`
+	ComponentVariantUUID uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_bundle_component;index" json:"variant_uuid"`
+	Quantity             uint      `gorm:"not null" json:"quantity"`
+	CreatedAt            time.Time `json:"-"`
+	UpdatedAt            time.Time `json:"-"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cart represents a storefront shopping cart, identified by its token.
+//
+// A cart holds variants of a single admin's catalog, which is fixed when the first line
+// is added. OrderUUID is set once the cart has been checked out.
+type Cart struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UUID          string     `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Token         string     `gorm:"type:varchar(64);unique;not null" json:"token"`
+	CustomerEmail string     `json:"customer_email,omitempty"`
+	AdminUUID     *uuid.UUID `gorm:"type:varchar(36);index" json:"admin_uuid,omitempty"`
+	OrderUUID     *uuid.UUID `gorm:"type:varchar(36)" json:"order_uuid,omitempty"`
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt     time.Time  `json:"created_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at,omitempty"`
+	Lines         []CartLine `gorm:"foreignKey:CartUUID;references:UUID" json:"lines"`
+}
+
+// BeforeCreate generates a UUID for the cart before creating a record.
+func (cart *Cart) BeforeCreate(tx *gorm.DB) error {
+	cart.UUID = uuid.New().String()
+	return nil
+}
+
+// CartLine represents the quantity of a variant in a cart.
+type CartLine struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UUID        string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	CartUUID    uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_cart_variant" json:"cart_uuid"`
+	VariantUUID uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_cart_variant" json:"variant_uuid"`
+	Quantity    uint      `gorm:"not null" json:"quantity"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the cart line before creating a record.
+func (line *CartLine) BeforeCreate(tx *gorm.DB) error {
+	line.UUID = uuid.New().String()
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxCategoryDepth is the deepest a category can be nested, bounded by the size of Path.
+const MaxCategoryDepth = 20
+
+// Category represents a node in an admin's category tree.
+//
+// Path is the materialized path of the category: the UUIDs of its ancestors and itself,
+// each followed by a slash, e.g. "/<root uuid>/<child uuid>/". The descendants of a
+// category are the categories whose path starts with its path.
+type Category struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UUID       string     `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Name       string     `gorm:"not null" json:"name"`
+	ParentUUID *uuid.UUID `gorm:"type:varchar(36);index" json:"parent_uuid"`
+	Path       string     `gorm:"type:varchar(760);not null;index" json:"path"`
+	Depth      uint       `gorm:"not null;default:0" json:"depth"`
+	AdminUUID  uuid.UUID  `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt  time.Time  `json:"created_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the category before creating a record.
+func (category *Category) BeforeCreate(tx *gorm.DB) error {
+	category.UUID = uuid.New().String()
+	return nil
+}
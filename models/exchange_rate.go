@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExchangeRate stores how many units of Currency equal one unit of the base currency.
+type ExchangeRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UUID      string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Currency  string    `gorm:"type:varchar(3);unique;not null" json:"currency"`
+	Rate      float64   `gorm:"type:decimal(18,8);not null" json:"rate"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the exchange rate before creating a record.
+func (rate *ExchangeRate) BeforeCreate(tx *gorm.DB) error {
+	rate.UUID = uuid.New().String()
+	return nil
+}
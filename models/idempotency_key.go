@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// IdempotencyKey records a POST request sent with an Idempotency-Key header and the response
+// it got, replayed when the request is sent again with the same key before ExpiresAt. Keys are
+// scoped to the admin the request is signed in as. A key whose response is not stored yet is still
+// being processed.
+type IdempotencyKey struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Key          string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_scope_key" json:"key"`
+	Scope        string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_idempotency_scope_key" json:"-"`
+	Method       string    `gorm:"type:varchar(8);not null" json:"method"`
+	Path         string    `gorm:"type:varchar(255);not null" json:"path"`
+	RequestHash  string    `gorm:"type:varchar(64);not null" json:"-"`
+	Completed    bool      `gorm:"not null;default:false" json:"completed"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `gorm:"type:varchar(255)" json:"-"`
+	ResponseBody []byte    `gorm:"type:longblob" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Import statuses.
+const (
+	ImportStatusPending    = "pending"
+	ImportStatusProcessing = "processing"
+	ImportStatusCompleted  = "completed"
+	ImportStatusFailed     = "failed"
+)
+
+// Import represents an uploaded CSV or XLSX file of products and variants, processed in the
+// background. ProcessedRows counts the rows already handled, so a resumed import skips them.
+type Import struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	UUID          string        `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	FileName      string        `gorm:"type:varchar(255);not null" json:"file_name"`
+	Format        string        `gorm:"type:varchar(8);not null" json:"format"`
+	Data          []byte        `gorm:"type:longblob" json:"-"`
+	Mapping       string        `gorm:"type:text" json:"-"`
+	DryRun        bool          `gorm:"not null;default:false" json:"dry_run"`
+	Status        string        `gorm:"type:varchar(16);not null;index" json:"status"`
+	TotalRows     int           `gorm:"not null;default:0" json:"total_rows"`
+	ProcessedRows int           `gorm:"not null;default:0" json:"processed_rows"`
+	CreatedRows   int           `gorm:"not null;default:0" json:"created_rows"`
+	UpdatedRows   int           `gorm:"not null;default:0" json:"updated_rows"`
+	FailedRows    int           `gorm:"not null;default:0" json:"failed_rows"`
+	Error         string        `gorm:"type:text" json:"error,omitempty"`
+	AdminUUID     uuid.UUID     `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CompletedAt   *time.Time    `json:"completed_at"`
+	CreatedAt     time.Time     `json:"created_at,omitempty"`
+	UpdatedAt     time.Time     `json:"updated_at,omitempty"`
+	Errors        []ImportError `gorm:"foreignKey:ImportUUID;references:UUID" json:"-"`
+}
+
+// BeforeCreate generates a UUID for the import before creating a record.
+func (importFile *Import) BeforeCreate(tx *gorm.DB) error {
+	importFile.UUID = uuid.New().String()
+	return nil
+}
+
+// ImportError represents a problem with one field of one row of an import.
+type ImportError struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ImportUUID uuid.UUID `gorm:"type:varchar(36);not null;index" json:"import_uuid"`
+	Row        int       `gorm:"column:file_row;not null" json:"row"`
+	Field      string    `gorm:"type:varchar(64)" json:"field"`
+	Message    string    `gorm:"type:text;not null" json:"message"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Job types.
+const (
+	JobUploadProductImage = "product.upload_image"
+	JobRunImport          = "import.run"
+	JobReindexSearch      = "search.reindex"
+	JobPurgeExpiredCarts  = "carts.purge_expired"
+	JobPublishDueProducts = "products.publish_due"
+	JobPurgeIdempotency   = "idempotency_keys.purge_expired"
+)
+
+// Job statuses.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// JobStatuses lists every job status.
+var JobStatuses = []string{JobStatusQueued, JobStatusRunning, JobStatusSucceeded, JobStatusFailed}
+
+// Job represents a unit of background work in the persistent queue, run by a worker once
+// RunAt has passed and retried with backoff until it succeeds or runs out of attempts.
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UUID        string     `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Type        string     `gorm:"type:varchar(64);not null;index" json:"type"`
+	Payload     string     `gorm:"type:longtext;not null" json:"-"`
+	Status      string     `gorm:"type:varchar(16);not null;index:idx_job_due" json:"status"`
+	RunAt       time.Time  `gorm:"not null;index:idx_job_due" json:"run_at"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null" json:"max_attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	LockedBy    string     `gorm:"type:varchar(64)" json:"-"`
+	LockedAt    *time.Time `json:"-"`
+	UniqueKey   *string    `gorm:"type:varchar(191);unique" json:"-"`
+	AdminUUID   *uuid.UUID `gorm:"type:varchar(36);index" json:"admin_uuid"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the job before creating a record.
+func (job *Job) BeforeCreate(tx *gorm.DB) error {
+	job.UUID = uuid.New().String()
+	return nil
+}
+
+// JobBlob holds binary data a job needs, such as an uploaded image, so that the job payload
+// only carries a reference to it.
+type JobBlob struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UUID      string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Data      []byte    `gorm:"type:longblob;not null" json:"-"`
+	CreatedAt time.Time `gorm:"index" json:"created_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the blob before creating a record.
+func (blob *JobBlob) BeforeCreate(tx *gorm.DB) error {
+	blob.UUID = uuid.New().String()
+	return nil
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Order statuses.
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusCancelled = "cancelled"
+)
+
+// Order represents a sale of variants from an admin's catalog.
+type Order struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	UUID      string      `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Status    string      `gorm:"type:varchar(20);not null;index" json:"status"`
+	Discount  float64     `gorm:"type:decimal(12,2);not null;default:0" json:"discount"`
+	Total     float64     `gorm:"type:decimal(12,2);not null;default:0" json:"total"`
+	AdminUUID uuid.UUID   `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt time.Time   `json:"created_at,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at,omitempty"`
+	Lines     []OrderLine `gorm:"foreignKey:OrderUUID;references:UUID" json:"lines"`
+}
+
+// BeforeCreate generates a UUID for the order before creating a record.
+func (order *Order) BeforeCreate(tx *gorm.DB) error {
+	order.UUID = uuid.New().String()
+	return nil
+}
+
+// OrderLine represents the quantity of a variant sold in an order, priced at the time of sale.
+// Total is what is left of the units' price after the Discount of the promotions applied.
+// A bundle line keeps the Components the bundle was made of when sold.
+type OrderLine struct {
+	ID          uint                 `gorm:"primaryKey" json:"id"`
+	UUID        string               `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	OrderUUID   uuid.UUID            `gorm:"type:varchar(36);not null;index" json:"order_uuid"`
+	VariantUUID uuid.UUID            `gorm:"type:varchar(36);not null;index" json:"variant_uuid"`
+	VariantName string               `gorm:"not null" json:"variant_name"`
+	Quantity    uint                 `gorm:"not null" json:"quantity"`
+	UnitPrice   float64              `gorm:"type:decimal(12,2);not null;default:0" json:"unit_price"`
+	Discount    float64              `gorm:"type:decimal(12,2);not null;default:0" json:"discount"`
+	Total       float64              `gorm:"type:decimal(12,2);not null;default:0" json:"total"`
+	Components  []OrderLineComponent `gorm:"type:text;serializer:json" json:"components,omitempty"`
+	CreatedAt   time.Time            `json:"created_at,omitempty"`
+	UpdatedAt   time.Time            `json:"updated_at,omitempty"`
+}
+
+// OrderLineComponent is a component variant of a bundle sold in an order line and its
+// quantity per bundle.
+type OrderLineComponent struct {
+	VariantUUID uuid.UUID `json:"variant_uuid"`
+	Quantity    uint      `json:"quantity"`
+}
+
+// BeforeCreate generates a UUID for the order line before creating a record.
+func (line *OrderLine) BeforeCreate(tx *gorm.DB) error {
+	line.UUID = uuid.New().String()
+	return nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Domain event types.
+const (
+	EventProductCreated      = "product.created"
+	EventProductUpdated      = "product.updated"
+	EventProductDeleted      = "product.deleted"
+	EventVariantCreated      = "variant.created"
+	EventVariantUpdated      = "variant.updated"
+	EventVariantDeleted      = "variant.deleted"
+	EventVariantStockChanged = "variant.stock_changed"
+)
+
+// EventTypes lists every domain event type.
+var EventTypes = []string{
+	EventProductCreated,
+	EventProductUpdated,
+	EventProductDeleted,
+	EventVariantCreated,
+	EventVariantUpdated,
+	EventVariantDeleted,
+	EventVariantStockChanged,
+}
+
+// OutboxEvent represents a domain event written in the same transaction as the change it
+// reports, and published to the in-process subscribers once that transaction commits. The
+// ID orders the events as they were recorded; DispatchSeq orders them as they were first
+// dispatched, which is the order event streams resume in. PendingSubscribers lists, comma
+// separated, the subscribers that still have to handle a dispatched event.
+type OutboxEvent struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	UUID               string     `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Type               string     `gorm:"type:varchar(64);not null" json:"type"`
+	AdminUUID          uuid.UUID  `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	ProductUUID        string     `gorm:"type:varchar(36);not null;index" json:"product_uuid"`
+	VariantUUID        string     `gorm:"type:varchar(36)" json:"variant_uuid,omitempty"`
+	Payload            string     `gorm:"type:mediumtext;not null" json:"payload"`
+	Attempts           int        `gorm:"not null;default:0" json:"attempts"`
+	LastError          string     `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt      *time.Time `json:"next_attempt_at,omitempty"`
+	DispatchSeq        *uint64    `gorm:"uniqueIndex" json:"dispatch_seq,omitempty"`
+	PendingSubscribers string     `gorm:"type:varchar(255)" json:"pending_subscribers,omitempty"`
+	DispatchedAt       *time.Time `gorm:"index" json:"dispatched_at"`
+	CreatedAt          time.Time  `json:"created_at,omitempty"`
+}
+
+// OutboxSequence is the counter the dispatch sequence of outbox events is taken from. Its
+// single row is locked while sequences are assigned, so they become visible in order.
+type OutboxSequence struct {
+	ID    uint   `gorm:"primaryKey"`
+	Value uint64 `gorm:"not null;default:0"`
+}
+
+// BeforeCreate generates a UUID for the outbox event before creating a record.
+func (event *OutboxEvent) BeforeCreate(tx *gorm.DB) error {
+	event.UUID = uuid.New().String()
+	return nil
+}
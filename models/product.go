@@ -6,15 +6,36 @@ import (
 	"gorm.io/gorm"
 )
 
+// Product statuses. Only active products can be added to carts.
+const (
+	ProductStatusDraft    = "draft"
+	ProductStatusActive   = "active"
+	ProductStatusArchived = "archived"
+)
+
+// Product types. The variants of a bundle product are made of variants of standard products.
+const (
+	ProductTypeStandard = "standard"
+	ProductTypeBundle   = "bundle"
+)
+
 type Product struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
 	UUID      string `gorm:"type:varchar(36);unique;not null" json:"uuid"`
 	ProductName     string `gorm:"not null" json:"product_name"`
 	ImageURL string `json:"image_url"`
+	Description     string `gorm:"type:text" json:"description"`
+	DescriptionHTML string `gorm:"type:text" json:"description_html"`
+	Status      string     `gorm:"type:varchar(16);not null;default:active;index" json:"status"`
+	Type        string     `gorm:"type:varchar(16);not null;default:standard" json:"type"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at"`
 	AdminUUID  uuid.UUID `gorm:"type:varchar(36);not null" json:"admin_uuid"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 	Variants  []Variant `gorm:"foreignKey:ProductUUID;references:UUID"`
+	Categories []Category `gorm:"many2many:product_categories;foreignKey:UUID;joinForeignKey:ProductUUID;references:UUID;joinReferences:CategoryUUID" json:"categories,omitempty"`
+	Tags       []Tag      `gorm:"many2many:product_tags;foreignKey:UUID;joinForeignKey:ProductUUID;references:UUID;joinReferences:TagUUID" json:"tags,omitempty"`
+	Options    []ProductOption `gorm:"foreignKey:ProductUUID;references:UUID" json:"options,omitempty"`
 }
 
 // BeforeCreate generates a UUID for the admin before creating a record.
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductOption defines an attribute the variants of a product vary by, such as Size with
+// the values S, M and L. Position orders the options in generated variant names.
+type ProductOption struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UUID        string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	ProductUUID uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_product_option" json:"product_uuid"`
+	Name        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_product_option" json:"name"`
+	Values      []string  `gorm:"type:text;serializer:json;not null" json:"values"`
+	Position    int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the product option before creating a record.
+func (option *ProductOption) BeforeCreate(tx *gorm.DB) error {
+	option.UUID = uuid.New().String()
+	return nil
+}
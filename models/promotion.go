@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Promotion types.
+const (
+	PromotionTypePercentage  = "percentage"
+	PromotionTypeFixedAmount = "fixed_amount"
+	PromotionTypeBuyXGetY    = "buy_x_get_y"
+)
+
+// Promotion target types.
+const (
+	PromotionTargetProduct  = "product"
+	PromotionTargetVariant  = "variant"
+	PromotionTargetCategory = "category"
+)
+
+// Promotion represents a discount rule on the products, variants or categories of an admin.
+//
+// Value is a percentage (0-100) for percentage promotions, an amount off each unit for
+// fixed_amount promotions, and the percentage off the GetQuantity items for buy_x_get_y.
+// MinimumSpend is the subtotal the targeted lines of an order must reach for the promotion to
+// apply, 0 for none.
+type Promotion struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UUID         string     `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Name         string     `gorm:"not null" json:"name"`
+	Type         string     `gorm:"type:varchar(20);not null" json:"type"`
+	Value        float64    `gorm:"type:decimal(12,2);not null;default:0" json:"value"`
+	BuyQuantity  uint       `gorm:"not null;default:0" json:"buy_quantity"`
+	GetQuantity  uint       `gorm:"not null;default:0" json:"get_quantity"`
+	TargetType   string     `gorm:"type:varchar(20);not null" json:"target_type"`
+	TargetUUID   string     `gorm:"type:varchar(36);not null;index" json:"target_uuid"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	UsageLimit   uint       `gorm:"not null;default:0" json:"usage_limit"`
+	UsageCount   uint       `gorm:"not null;default:0" json:"usage_count"`
+	MinimumSpend float64    `gorm:"type:decimal(12,2);not null;default:0" json:"minimum_spend"`
+	Stackable    bool       `gorm:"not null;default:false" json:"stackable"`
+	Priority     int        `gorm:"not null;default:0" json:"priority"`
+	AdminUUID    uuid.UUID  `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt    time.Time  `json:"created_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the promotion before creating a record.
+func (promotion *Promotion) BeforeCreate(tx *gorm.DB) error {
+	promotion.UUID = uuid.New().String()
+	return nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Purchase order statuses.
+const (
+	PurchaseOrderStatusDraft             = "draft"
+	PurchaseOrderStatusOrdered           = "ordered"
+	PurchaseOrderStatusPartiallyReceived = "partially_received"
+	PurchaseOrderStatusReceived          = "received"
+	PurchaseOrderStatusCancelled         = "cancelled"
+)
+
+// PurchaseOrder represents variants ordered from a supplier to restock them.
+type PurchaseOrder struct {
+	ID           uint                `gorm:"primaryKey" json:"id"`
+	UUID         string              `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	SupplierUUID uuid.UUID           `gorm:"type:varchar(36);not null;index" json:"supplier_uuid"`
+	Status       string              `gorm:"type:varchar(20);not null;index" json:"status"`
+	Note         string              `json:"note"`
+	AdminUUID    uuid.UUID           `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt    time.Time           `json:"created_at,omitempty"`
+	UpdatedAt    time.Time           `json:"updated_at,omitempty"`
+	Lines        []PurchaseOrderLine `gorm:"foreignKey:PurchaseOrderUUID;references:UUID" json:"lines"`
+}
+
+// BeforeCreate generates a UUID for the purchase order before creating a record.
+func (purchaseOrder *PurchaseOrder) BeforeCreate(tx *gorm.DB) error {
+	purchaseOrder.UUID = uuid.New().String()
+	return nil
+}
+
+// PurchaseOrderLine represents the ordered and received quantities of a variant in a purchase order.
+type PurchaseOrderLine struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	UUID              string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	PurchaseOrderUUID uuid.UUID `gorm:"type:varchar(36);not null;index" json:"purchase_order_uuid"`
+	VariantUUID       uuid.UUID `gorm:"type:varchar(36);not null;index" json:"variant_uuid"`
+	OrderedQuantity   uint      `gorm:"not null" json:"ordered_quantity"`
+	ReceivedQuantity  uint      `gorm:"not null;default:0" json:"received_quantity"`
+	UnitCost          float64   `gorm:"type:decimal(12,2);not null;default:0" json:"unit_cost"`
+	CreatedAt         time.Time `json:"created_at,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the purchase order line before creating a record.
+func (line *PurchaseOrderLine) BeforeCreate(tx *gorm.DB) error {
+	line.UUID = uuid.New().String()
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Return statuses.
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusReceived  = "received"
+	ReturnStatusRefunded  = "refunded"
+	ReturnStatusRejected  = "rejected"
+)
+
+// Return represents goods of an order line coming back (an RMA).
+type Return struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UUID          string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	OrderUUID     uuid.UUID `gorm:"type:varchar(36);not null;index" json:"order_uuid"`
+	OrderLineUUID uuid.UUID `gorm:"type:varchar(36);not null;index" json:"order_line_uuid"`
+	VariantUUID   uuid.UUID `gorm:"type:varchar(36);not null" json:"variant_uuid"`
+	Quantity      uint      `gorm:"not null" json:"quantity"`
+	Reason        string    `json:"reason"`
+	Status        string    `gorm:"type:varchar(20);not null;index" json:"status"`
+	RefundAmount  float64   `gorm:"type:decimal(12,2);not null;default:0" json:"refund_amount"`
+	AdminUUID     uuid.UUID `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the return before creating a record.
+func (ret *Return) BeforeCreate(tx *gorm.DB) error {
+	ret.UUID = uuid.New().String()
+	return nil
+}
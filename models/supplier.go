@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Supplier represents a company an admin restocks variants from.
+type Supplier struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UUID      string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Name      string    `gorm:"not null" json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Address   string    `json:"address"`
+	AdminUUID uuid.UUID `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the supplier before creating a record.
+func (supplier *Supplier) BeforeCreate(tx *gorm.DB) error {
+	supplier.UUID = uuid.New().String()
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tag represents a free-form label an admin puts on products. Names are stored lower-cased.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UUID      string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	Name      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_admin_tag" json:"name"`
+	AdminUUID uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_admin_tag" json:"admin_uuid"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the tag before creating a record.
+func (tag *Tag) BeforeCreate(tx *gorm.DB) error {
+	tag.UUID = uuid.New().String()
+	return nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,9 +13,36 @@ type Variant struct {
 	UUID      string `gorm:"type:varchar(36);unique;not null" json:"uuid"`
 	VariantName string `gorm:"not null" json:"variant_name"`
 	Quantity    uint    `gorm:"not null" json:"quantity"`
+	Price       float64 `gorm:"type:decimal(12,2);not null;default:0" json:"price"`
+	SKU         string  `gorm:"type:varchar(64);index" json:"sku"`
+	Barcode     string  `gorm:"type:varchar(14);index" json:"barcode"`
 	ProductUUID  uuid.UUID `gorm:"type:varchar(36);not null" json:"product_uuid"`
+	// AdminUUID is the owner of the variant's product, so the keys below are unique per catalog
+	AdminUUID   uuid.UUID `gorm:"type:varchar(36);uniqueIndex:idx_variants_admin_sku,priority:1;uniqueIndex:idx_variants_admin_barcode,priority:1" json:"-"`
+	// SKUKey is the SKU, or NULL without one
+	SKUKey      *string `gorm:"column:sku_key;type:varchar(64);uniqueIndex:idx_variants_admin_sku,priority:2" json:"-"`
+	// BarcodeKey is the barcode without leading zeros, so a UPC-A code and the same product's
+	// EAN-13 or GTIN-14 code share it, or NULL without a barcode
+	BarcodeKey  *string `gorm:"type:varchar(14);uniqueIndex:idx_variants_admin_barcode,priority:2" json:"-"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Attributes []VariantAttribute `gorm:"foreignKey:VariantUUID;references:UUID" json:"attributes,omitempty"`
+	Components []BundleComponent  `gorm:"foreignKey:BundleVariantUUID;references:UUID" json:"components,omitempty"`
+}
+
+// BeforeSave derives the keys the SKU and barcode are unique on.
+func (variant *Variant) BeforeSave(tx *gorm.DB) error {
+	variant.SKUKey = nil
+	if variant.SKU != "" {
+		sku := variant.SKU
+		variant.SKUKey = &sku
+	}
+
+	variant.BarcodeKey = nil
+	if barcode := strings.TrimLeft(variant.Barcode, "0"); barcode != "" {
+		variant.BarcodeKey = &barcode
+	}
+	return nil
 }
 
 func (variant *Variant) BeforeCreate(tx *gorm.DB) error {
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VariantAttribute holds the value a variant has for one of its product's options.
+type VariantAttribute struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	VariantUUID uuid.UUID `gorm:"type:varchar(36);not null;uniqueIndex:idx_variant_attribute" json:"-"`
+	Name        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_variant_attribute;index:idx_attribute_value" json:"name"`
+	Value       string    `gorm:"type:varchar(100);not null;index:idx_attribute_value" json:"value"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+}
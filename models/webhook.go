@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription represents a URL an admin's catalog events are posted to, signed with
+// the subscription's secret.
+type WebhookSubscription struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UUID      string    `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	URL       string    `gorm:"type:varchar(2048);not null" json:"url"`
+	Secret    string    `gorm:"type:varchar(128);not null" json:"-"`
+	Events    []string  `gorm:"type:text;serializer:json;not null" json:"events"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	AdminUUID uuid.UUID `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the webhook subscription before creating a record.
+func (subscription *WebhookSubscription) BeforeCreate(tx *gorm.DB) error {
+	subscription.UUID = uuid.New().String()
+	return nil
+}
+
+// WebhookDelivery represents an event queued for a webhook subscription, retried with
+// exponential backoff until it succeeds or runs out of attempts.
+type WebhookDelivery struct {
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	UUID             string           `gorm:"type:varchar(36);unique;not null" json:"uuid"`
+	SubscriptionUUID uuid.UUID        `gorm:"type:varchar(36);not null;index" json:"subscription_uuid"`
+	EventID          string           `gorm:"type:varchar(36);not null;index" json:"event_id"`
+	EventType        string           `gorm:"type:varchar(64);not null" json:"event_type"`
+	Payload          string           `gorm:"type:mediumtext;not null" json:"payload"`
+	Status           string           `gorm:"type:varchar(16);not null;index:idx_webhook_delivery_due" json:"status"`
+	Attempts         int              `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt    time.Time        `gorm:"index:idx_webhook_delivery_due" json:"next_attempt_at"`
+	DeliveredAt      *time.Time       `json:"delivered_at"`
+	ReplayOf         *uuid.UUID       `gorm:"type:varchar(36)" json:"replay_of"`
+	AdminUUID        uuid.UUID        `gorm:"type:varchar(36);not null;index" json:"admin_uuid"`
+	CreatedAt        time.Time        `json:"created_at,omitempty"`
+	UpdatedAt        time.Time        `json:"updated_at,omitempty"`
+	Logs             []WebhookAttempt `gorm:"foreignKey:DeliveryUUID;references:UUID" json:"logs,omitempty"`
+}
+
+// BeforeCreate generates a UUID for the webhook delivery before creating a record.
+func (delivery *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	delivery.UUID = uuid.New().String()
+	return nil
+}
+
+// WebhookAttempt logs one attempt at a webhook delivery and how the receiver answered.
+type WebhookAttempt struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DeliveryUUID uuid.UUID `gorm:"type:varchar(36);not null;index" json:"delivery_uuid"`
+	Attempt      int       `gorm:"not null" json:"attempt"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody string    `gorm:"type:text" json:"response_body"`
+	Error        string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+}
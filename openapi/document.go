@@ -0,0 +1,353 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the version of the API described by the document.
+const Version = "1.0.0"
+
+// ErrRouteDrift is returned when the routes of the router and the documented endpoints differ.
+var ErrRouteDrift = errors.New("routes and OpenAPI endpoints differ")
+
+// Document is an OpenAPI 3 document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Components holds the schemas and security schemes the operations refer to.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes how requests are authenticated.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation describes an endpoint.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a path, query or header parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request in each content type it is accepted in.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body in one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Endpoint documents a route of the router. The path parameters and the operation ID are
+// taken from the route itself.
+type Endpoint struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	// Public endpoints do not require a bearer token
+	Public bool
+	Query  []Parameter
+	// Request is the zero value of the request body; Form also accepts it as a form from its
+	// form tags, and FormOnly only as a form
+	Request  interface{}
+	Form     bool
+	FormOnly bool
+	// Status is the status of a successful response, 200 by default, and Response the zero
+	// value of its body, an Object or a Binary
+	Status   int
+	Response interface{}
+}
+
+var (
+	documentMutex sync.RWMutex
+	document      []byte
+)
+
+// Generate builds the OpenAPI document of the routes from Endpoints and keeps it to be served.
+// When a route is not documented or an endpoint has no route, the document is still served
+// and the differences are returned as an ErrRouteDrift error.
+func Generate(routes gin.RoutesInfo) error {
+	data, err := json.MarshalIndent(Build(routes), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	documentMutex.Lock()
+	document = data
+	documentMutex.Unlock()
+
+	return CheckRoutes(routes)
+}
+
+// CheckRoutes reports the routes without an endpoint in Endpoints and the endpoints without a
+// route, so that the document cannot drift from the router.
+func CheckRoutes(routes gin.RoutesInfo) error {
+	routed := map[string]bool{}
+	for _, route := range routes {
+		routed[route.Method+" "+route.Path] = true
+	}
+
+	documented := map[string]bool{}
+	var problems []string
+	for _, endpoint := range Endpoints {
+		key := endpoint.Method + " " + endpoint.Path
+		if documented[key] {
+			problems = append(problems, "documented twice: "+key)
+		}
+		documented[key] = true
+		if !routed[key] {
+			problems = append(problems, "no route for documented endpoint: "+key)
+		}
+	}
+	for key := range routed {
+		if !documented[key] {
+			problems = append(problems, "undocumented route: "+key)
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w:\n%s", ErrRouteDrift, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// Build describes the routes with their endpoints in Endpoints.
+func Build(routes gin.RoutesInfo) Document {
+	builder := &schemaBuilder{components: map[string]*Schema{
+		"Error": {Type: "object", Properties: map[string]*Schema{
+			"error":    {Type: "string"},
+			"message":  {Type: "string"},
+			"messages": {Type: "string"},
+		}},
+	}}
+
+	handlers := map[string]string{}
+	for _, route := range routes {
+		handlers[route.Method+" "+route.Path] = route.Handler
+	}
+
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "BasicTrade API",
+			Description: "Products, variants, stock, orders and the events of an admin's catalog.",
+			Version:     Version,
+		},
+		Paths: map[string]map[string]*Operation{},
+	}
+
+	operationIDs := map[string]bool{}
+	for _, endpoint := range Endpoints {
+		path, parameters := pathParameters(endpoint.Path)
+		operation := builder.operation(endpoint, handlers[endpoint.Method+" "+endpoint.Path], parameters)
+
+		// The same handler may serve several routes, and operation IDs must be unique
+		if operationIDs[operation.OperationID] {
+			operation.OperationID += endpoint.Method[:1] + strings.ToLower(endpoint.Method[1:])
+		}
+		operationIDs[operation.OperationID] = true
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(endpoint.Method)] = operation
+	}
+
+	doc.Components = Components{
+		Schemas: builder.components,
+		SecuritySchemes: map[string]SecurityScheme{
+			"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+	}
+	return doc
+}
+
+// operation describes an endpoint routed to the named handler.
+func (b *schemaBuilder) operation(endpoint Endpoint, handler string, parameters []Parameter) *Operation {
+	operation := &Operation{
+		OperationID: handler[strings.LastIndex(handler, ".")+1:],
+		Summary:     endpoint.Summary,
+		Tags:        []string{endpoint.Tag},
+		Parameters:  append(parameters, endpoint.Query...),
+		Responses:   map[string]Response{},
+	}
+
+	// The auth routes are not covered, as their responses carry credentials
+	if endpoint.Method == http.MethodPost && !strings.HasPrefix(endpoint.Path, "/auth/") {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "Replays the stored response when the request is retried with the same key.",
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	if endpoint.Request != nil {
+		operation.RequestBody = b.requestBody(endpoint)
+		operation.Responses["400"] = errorResponse("Invalid request")
+	}
+
+	status := endpoint.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	operation.Responses[strconv.Itoa(status)] = b.response(endpoint.Response)
+
+	if !endpoint.Public {
+		operation.Security = []map[string][]string{{"bearerAuth": {}}}
+		operation.Responses["401"] = errorResponse("Missing or invalid token")
+	}
+	if len(parameters) > 0 {
+		operation.Responses["404"] = errorResponse("Not found")
+	}
+	return operation
+}
+
+// requestBody describes the body of an endpoint in the content types it accepts.
+func (b *schemaBuilder) requestBody(endpoint Endpoint) *RequestBody {
+	body := &RequestBody{Required: true, Content: map[string]MediaType{}}
+	requestType := reflect.TypeOf(endpoint.Request)
+
+	if !endpoint.FormOnly {
+		body.Content["application/json"] = MediaType{Schema: b.typeSchema(requestType, "json")}
+	}
+	if endpoint.Form || endpoint.FormOnly {
+		form := b.typeSchema(requestType, "form")
+		body.Content["multipart/form-data"] = MediaType{Schema: form}
+		if !hasFile(form) {
+			body.Content["application/x-www-form-urlencoded"] = MediaType{Schema: form}
+		}
+	}
+	return body
+}
+
+// hasFile reports whether a form has a file field.
+func hasFile(form *Schema) bool {
+	for _, property := range form.Properties {
+		if property.Format == "binary" {
+			return true
+		}
+	}
+	return false
+}
+
+// response describes a successful response body.
+func (b *schemaBuilder) response(body interface{}) Response {
+	if binary, ok := body.(Binary); ok {
+		return Response{
+			Description: binary.Description,
+			Content:     map[string]MediaType{binary.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}}},
+		}
+	}
+	return Response{
+		Description: "Successful response",
+		Content:     map[string]MediaType{"application/json": {Schema: b.valueSchema(body)}},
+	}
+}
+
+// errorResponse describes an error response.
+func errorResponse(description string) Response {
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}}},
+	}
+}
+
+// pathParameters converts a gin path to an OpenAPI path and lists its parameters.
+func pathParameters(path string) (string, []Parameter) {
+	segments := strings.Split(path, "/")
+	var parameters []Parameter
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			segments[i] = "{" + name + "}"
+			parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+// ServeDocument serves the OpenAPI document.
+func ServeDocument(c *gin.Context) {
+	documentMutex.RLock()
+	data := document
+	documentMutex.RUnlock()
+
+	if data == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The API document is not generated"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// swaggerUI is the page rendering the OpenAPI document with Swagger UI.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>BasicTrade API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// ServeUI serves a Swagger UI page for the OpenAPI document.
+func ServeUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
@@ -0,0 +1,82 @@
+package openapi_test
+
+import (
+	"basictrade/openapi"
+	"basictrade/routes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+	m.Run()
+}
+
+func TestEndpointsMatchRoutes(t *testing.T) {
+	if err := openapi.CheckRoutes(routes.StartApp().Routes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckRoutesReportsDrift(t *testing.T) {
+	routesInfo := routes.StartApp().Routes()
+	for i, route := range routesInfo {
+		if route.Method == http.MethodGet && route.Path == "/tags" {
+			routesInfo = append(routesInfo[:i:i], routesInfo[i+1:]...)
+			break
+		}
+	}
+	routesInfo = append(routesInfo, gin.RouteInfo{Method: http.MethodGet, Path: "/undocumented"})
+
+	err := openapi.CheckRoutes(routesInfo)
+	if err == nil {
+		t.Fatal("expected drift to be reported")
+	}
+	for _, want := range []string{"no route for documented endpoint: GET /tags", "undocumented route: GET /undocumented"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestServeDocument(t *testing.T) {
+	router := routes.StartApp()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json answered %d", recorder.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(recorder.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi is %q", doc.OpenAPI)
+	}
+
+	operation := doc.Paths["/products/{productUUID}"]["get"]
+	if operation == nil {
+		t.Fatal("GET /products/{productUUID} is not documented")
+	}
+	if operation.OperationID != "GetProductDetail" {
+		t.Errorf("operationId is %q", operation.OperationID)
+	}
+	for _, name := range []string{"AdminRequest", "ProductCreateRequest", "CreateVariantRequest", "ProductDetailResponse"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("schema %s is missing", name)
+		}
+	}
+	if required := doc.Components.Schemas["AdminRequest"].Required; len(required) == 0 {
+		t.Error("AdminRequest has no required fields")
+	}
+}
@@ -0,0 +1,283 @@
+package openapi
+
+import (
+	"basictrade/controllers"
+	"basictrade/models"
+	"basictrade/utils"
+	"net/http"
+)
+
+// query describes an optional string query parameter.
+func query(name string, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// intQuery describes an optional integer query parameter.
+func intQuery(name string, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: "integer"}}
+}
+
+var (
+	// pageQuery are the parameters of the lists paginated by page number.
+	pageQuery = []Parameter{
+		intQuery("page", "Page number, from 1."),
+		intQuery("pageSize", "Number of items per page."),
+	}
+
+	// listQuery are the parameters of the product and variant lists, which also accept
+	// filters on their fields.
+	listQuery = []Parameter{
+		intQuery("page", "Page number, from 1."),
+		intQuery("pageSize", "Number of items per page."),
+		query("cursor", "Cursor of the page to fetch, instead of page."),
+		query("count", "Set to false to skip counting the total items."),
+		query("sort", "Comma separated fields to sort by, descending when prefixed with -."),
+		query("facets", "Comma separated facets to count; none are counted without it."),
+		query("currency", "ISO 4217 code to report variant prices in."),
+	}
+
+	// currencyQuery converts the variant prices of a response.
+	currencyQuery = []Parameter{query("currency", "ISO 4217 code to report variant prices in.")}
+
+	// exportQuery are the parameters of the exports, which also accept the filters of the lists.
+	exportQuery = []Parameter{
+		query("format", "csv, ndjson or xlsx."),
+		query("columns", "Comma separated columns to export, in order."),
+		query("sort", "Comma separated fields to sort by, descending when prefixed with -."),
+	}
+)
+
+// deleted is the response of the endpoints deleting a record.
+var deleted = Object{"message": ""}
+
+// listPage is the response of the product and variant lists.
+func listPage(key string, items interface{}) Object {
+	return Object{
+		key:            items,
+		"totalItems":   int64(0),
+		"totalPages":   0,
+		"nextCursor":   "",
+		"prevCursor":   "",
+		"links":        Object{"next": "", "prev": ""},
+		"facets":       map[string][]utils.FacetCount{},
+		"exchangeRate": utils.CurrencyConversion{},
+	}
+}
+
+// page is the response of the lists paginated by page number.
+func page(key string, items interface{}) Object {
+	return Object{key: items, "totalItems": int64(0), "totalPages": 0}
+}
+
+// Endpoints documents every route of routes.StartApp. It is kept by hand next to the routes;
+// the openapi tests fail when a route is added without its endpoint, or an endpoint is left
+// without its route.
+var Endpoints = []Endpoint{
+	// Auth
+	{Method: http.MethodPost, Path: "/auth/register", Tag: "Auth", Summary: "Register an admin.", Public: true,
+		Request: controllers.AdminRequest{}, Form: true, Response: Object{"success": true, "data": models.Admin{}, "message": ""}},
+	{Method: http.MethodPost, Path: "/auth/login", Tag: "Auth", Summary: "Log in as an admin.", Public: true,
+		Request: controllers.AdminRequest{}, Form: true, Response: Object{"token": ""}},
+
+	// Products
+	{Method: http.MethodGet, Path: "/products", Tag: "Products", Summary: "Get all products.",
+		Query: append(listQuery, query("productName", "Part of the product name."), query("categoryUUID", "Category, including its descendants."),
+			query("tags", "Comma separated tags."), query("tagMatch", "any or all of the tags."), query("status", "draft, active or archived.")),
+		Response: listPage("products", []models.Product{})},
+	{Method: http.MethodPost, Path: "/products", Tag: "Products", Summary: "Create a product, optionally with its variants.",
+		Request: controllers.ProductCreateRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"product": models.Product{}, "imageJob": models.Job{}}},
+	{Method: http.MethodPut, Path: "/products/:productUUID", Tag: "Products", Summary: "Update product details.",
+		Request: controllers.ProductUpdateRequest{}, Form: true, Response: Object{"product": models.Product{}, "imageJob": models.Job{}}},
+	{Method: http.MethodDelete, Path: "/products/:productUUID", Tag: "Products", Summary: "Delete a product.", Response: deleted},
+	{Method: http.MethodGet, Path: "/products/:productUUID", Tag: "Products", Summary: "Get product details.",
+		Query: currencyQuery, Response: Object{"product": controllers.ProductDetailResponse{}, "exchangeRate": utils.CurrencyConversion{}}},
+	{Method: http.MethodPost, Path: "/products/:productUUID/categories", Tag: "Categories", Summary: "Assign categories to a product.",
+		Request: controllers.ProductCategoriesRequest{}, Form: true, Response: Object{"categories": []models.Category{}}},
+	{Method: http.MethodDelete, Path: "/products/:productUUID/categories/:categoryUUID", Tag: "Categories", Summary: "Unassign a category from a product.",
+		Response: Object{"categories": []models.Category{}}},
+	{Method: http.MethodPost, Path: "/products/:productUUID/tags", Tag: "Tags", Summary: "Tag a product, creating new tags as needed.",
+		Request: controllers.ProductTagsRequest{}, Form: true, Response: Object{"tags": []models.Tag{}}},
+	{Method: http.MethodDelete, Path: "/products/:productUUID/tags/:tag", Tag: "Tags", Summary: "Remove a tag from a product.",
+		Response: Object{"tags": []models.Tag{}}},
+	{Method: http.MethodGet, Path: "/products/:productUUID/options", Tag: "Options", Summary: "Get the option definitions of a product.",
+		Response: Object{"options": []models.ProductOption{}}},
+	{Method: http.MethodPut, Path: "/products/:productUUID/options", Tag: "Options", Summary: "Replace the option definitions of a product.",
+		Request: controllers.ProductOptionsRequest{}, Response: Object{"options": []models.ProductOption{}}},
+	{Method: http.MethodPost, Path: "/products/:productUUID/variants/generate", Tag: "Options", Summary: "Create a variant for every missing combination of option values.",
+		Request: controllers.GenerateVariantsRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"variants": []models.Variant{}, "totalItems": 0}},
+
+	// Variants
+	{Method: http.MethodGet, Path: "/products/variants", Tag: "Variants", Summary: "Get all variants.",
+		Query:    append(listQuery, query("variantName", "Part of the variant name."), query("attr[<option>]", "Value of an option.")),
+		Response: listPage("variants", []models.Variant{})},
+	{Method: http.MethodGet, Path: "/products/variants/lookup", Tag: "Variants", Summary: "Find a variant by SKU or barcode.",
+		Query: []Parameter{query("sku", "SKU of the variant."), query("barcode", "Barcode of the variant.")}, Response: Object{"variant": models.Variant{}}},
+	{Method: http.MethodPost, Path: "/products/variants", Tag: "Variants", Summary: "Create a variant.",
+		Request: controllers.CreateVariantRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"variant": models.Variant{}}},
+	{Method: http.MethodPost, Path: "/products/variants/batch", Tag: "Variants", Summary: "Create, update and delete many variants in one request.",
+		Request: controllers.BatchVariantsRequest{}, Response: Object{"results": []controllers.BatchVariantResult{}, "succeeded": 0, "failed": 0}},
+	{Method: http.MethodPut, Path: "/products/variants/:variantUUID", Tag: "Variants", Summary: "Update variant details.",
+		Request: controllers.CreateVariantRequest{}, Form: true, Response: Object{"variant": models.Variant{}}},
+	{Method: http.MethodDelete, Path: "/products/variants/:variantUUID", Tag: "Variants", Summary: "Delete a variant.", Response: deleted},
+	{Method: http.MethodGet, Path: "/products/variants/:variantUUID", Tag: "Variants", Summary: "Get variant details.",
+		Query: currencyQuery, Response: Object{"variant": models.Variant{}}},
+	{Method: http.MethodGet, Path: "/products/variants/:variantUUID/barcode", Tag: "Variants", Summary: "Render the variant's barcode as a PNG or SVG label.",
+		Query:    []Parameter{query("format", "png or svg."), intQuery("width", "Width in pixels."), intQuery("height", "Height in pixels."), query("symbology", "Barcode symbology.")},
+		Response: Binary{ContentType: "image/png", Description: "The barcode label, as image/svg+xml when format is svg"}},
+	{Method: http.MethodGet, Path: "/products/variants/:variantUUID/components", Tag: "Bundles", Summary: "Get the components of a bundle variant and how many bundles are available.",
+		Response: Object{"components": []models.BundleComponent{}, "variants": []models.Variant{}, "available": uint(0)}},
+	{Method: http.MethodPut, Path: "/products/variants/:variantUUID/components", Tag: "Bundles", Summary: "Replace the components of a bundle variant.",
+		Request: controllers.BundleComponentsRequest{}, Response: Object{"components": []models.BundleComponent{}, "variants": []models.Variant{}, "available": uint(0)}},
+
+	// Search
+	{Method: http.MethodGet, Path: "/search", Tag: "Search", Summary: "Search products and variants, ranked by relevance.",
+		Query:    append([]Parameter{query("q", "Words to search for."), query("type", "product or variant.")}, pageQuery...),
+		Response: page("results", []controllers.SearchResult{})},
+	{Method: http.MethodPost, Path: "/search/reindex", Tag: "Search", Summary: "Queue a rebuild of the search index of your catalog.",
+		Status: http.StatusAccepted, Response: Object{"message": "", "job": models.Job{}}},
+
+	// Exchange rates
+	{Method: http.MethodGet, Path: "/exchange-rates", Tag: "Exchange rates", Summary: "Get all exchange rates.",
+		Response: Object{"exchangeRates": []models.ExchangeRate{}, "baseCurrency": ""}},
+	{Method: http.MethodPost, Path: "/exchange-rates", Tag: "Exchange rates", Summary: "Create or replace an exchange rate, for superadmins.",
+		Request: controllers.ExchangeRateRequest{}, Form: true, Response: Object{"exchangeRate": models.ExchangeRate{}}},
+	{Method: http.MethodPost, Path: "/exchange-rates/upload", Tag: "Exchange rates", Summary: "Create or replace exchange rates from a CSV file of currency,rate rows, for superadmins.",
+		Request: controllers.ExchangeRateUploadRequest{}, FormOnly: true, Response: Object{"message": "", "totalItems": 0}},
+	{Method: http.MethodPut, Path: "/exchange-rates/:currency", Tag: "Exchange rates", Summary: "Create or replace the exchange rate of a currency, for superadmins.",
+		Request: controllers.ExchangeRateRequest{}, Form: true, Response: Object{"exchangeRate": models.ExchangeRate{}}},
+	{Method: http.MethodDelete, Path: "/exchange-rates/:currency", Tag: "Exchange rates", Summary: "Delete an exchange rate, for superadmins.", Response: deleted},
+
+	// Categories
+	{Method: http.MethodGet, Path: "/categories", Tag: "Categories", Summary: "Get all categories, nested when tree is true.",
+		Query: []Parameter{query("tree", "Set to true to nest the categories under their parents.")}, Response: Object{"categories": []models.Category{}}},
+	{Method: http.MethodPost, Path: "/categories", Tag: "Categories", Summary: "Create a category, under parent_uuid when given.",
+		Request: controllers.CategoryRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"category": models.Category{}}},
+	{Method: http.MethodGet, Path: "/categories/:categoryUUID", Tag: "Categories", Summary: "Get category details with its ancestors and children.",
+		Response: Object{"category": models.Category{}, "ancestors": []models.Category{}, "children": []models.Category{}}},
+	{Method: http.MethodPut, Path: "/categories/:categoryUUID", Tag: "Categories", Summary: "Rename a category or move it under another parent.",
+		Request: controllers.CategoryRequest{}, Form: true, Response: Object{"category": models.Category{}}},
+	{Method: http.MethodDelete, Path: "/categories/:categoryUUID", Tag: "Categories", Summary: "Delete a category without children.", Response: deleted},
+
+	// Tags
+	{Method: http.MethodGet, Path: "/tags", Tag: "Tags", Summary: "Get all tags with the number of products carrying each one.",
+		Response: Object{"tags": []controllers.TagUsageResponse{}}},
+
+	// Promotions
+	{Method: http.MethodGet, Path: "/promotions", Tag: "Promotions", Summary: "Get all promotions.", Response: Object{"promotions": []models.Promotion{}}},
+	{Method: http.MethodPost, Path: "/promotions", Tag: "Promotions", Summary: "Create a promotion.",
+		Request: controllers.PromotionRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"promotion": models.Promotion{}}},
+	{Method: http.MethodPost, Path: "/promotions/evaluate", Tag: "Promotions", Summary: "Get the line-level discounts for a list of variants and quantities.",
+		Request: controllers.PromotionEvaluateRequest{}, Response: Object{"evaluation": utils.PromotionEvaluation{}}},
+	{Method: http.MethodGet, Path: "/promotions/:promotionUUID", Tag: "Promotions", Summary: "Get promotion details.", Response: Object{"promotion": models.Promotion{}}},
+	{Method: http.MethodPut, Path: "/promotions/:promotionUUID", Tag: "Promotions", Summary: "Update a promotion.",
+		Request: controllers.PromotionRequest{}, Form: true, Response: Object{"promotion": models.Promotion{}}},
+	{Method: http.MethodDelete, Path: "/promotions/:promotionUUID", Tag: "Promotions", Summary: "Delete a promotion.", Response: deleted},
+
+	// Orders
+	{Method: http.MethodGet, Path: "/orders", Tag: "Orders", Summary: "Get all orders.",
+		Query: append(pageQuery, query("status", "pending, paid, shipped or cancelled.")), Response: page("orders", []models.Order{})},
+	{Method: http.MethodPost, Path: "/orders", Tag: "Orders", Summary: "Create an order from a list of variants and quantities, decrementing stock.",
+		Request: controllers.OrderCreateRequest{}, Status: http.StatusCreated, Response: Object{"order": models.Order{}}},
+	{Method: http.MethodGet, Path: "/orders/:orderUUID", Tag: "Orders", Summary: "Get order details.", Response: Object{"order": models.Order{}}},
+	{Method: http.MethodPut, Path: "/orders/:orderUUID/status", Tag: "Orders", Summary: "Move an order to paid, shipped or cancelled.",
+		Request: controllers.OrderStatusRequest{}, Form: true, Response: Object{"order": models.Order{}}},
+	{Method: http.MethodPost, Path: "/orders/:orderUUID/returns", Tag: "Returns", Summary: "Request a return of units of an order line.",
+		Request: controllers.ReturnCreateRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"return": models.Return{}}},
+
+	// Returns
+	{Method: http.MethodGet, Path: "/returns", Tag: "Returns", Summary: "Get all returns.",
+		Query: append(pageQuery, query("status", "Status of the returns.")), Response: page("returns", []models.Return{})},
+	{Method: http.MethodGet, Path: "/returns/:returnUUID", Tag: "Returns", Summary: "Get return details.", Response: Object{"return": models.Return{}}},
+	{Method: http.MethodPut, Path: "/returns/:returnUUID/approve", Tag: "Returns", Summary: "Approve a requested return.", Response: Object{"return": models.Return{}}},
+	{Method: http.MethodPut, Path: "/returns/:returnUUID/reject", Tag: "Returns", Summary: "Reject a requested or approved return.", Response: Object{"return": models.Return{}}},
+	{Method: http.MethodPut, Path: "/returns/:returnUUID/receive", Tag: "Returns", Summary: "Receive an approved return and put its units back in stock.", Response: Object{"return": models.Return{}}},
+	{Method: http.MethodPut, Path: "/returns/:returnUUID/refund", Tag: "Returns", Summary: "Refund a received return, for its full value unless an amount is given.",
+		Request: controllers.ReturnRefundRequest{}, Form: true, Response: Object{"return": models.Return{}}},
+
+	// Suppliers
+	{Method: http.MethodGet, Path: "/suppliers", Tag: "Suppliers", Summary: "Get all suppliers.",
+		Query: []Parameter{query("name", "Part of the supplier name.")}, Response: Object{"suppliers": []models.Supplier{}}},
+	{Method: http.MethodPost, Path: "/suppliers", Tag: "Suppliers", Summary: "Create a supplier.",
+		Request: controllers.SupplierRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"supplier": models.Supplier{}}},
+	{Method: http.MethodGet, Path: "/suppliers/:supplierUUID", Tag: "Suppliers", Summary: "Get supplier details.", Response: Object{"supplier": models.Supplier{}}},
+	{Method: http.MethodPut, Path: "/suppliers/:supplierUUID", Tag: "Suppliers", Summary: "Update supplier details.",
+		Request: controllers.SupplierRequest{}, Form: true, Response: Object{"supplier": models.Supplier{}}},
+	{Method: http.MethodDelete, Path: "/suppliers/:supplierUUID", Tag: "Suppliers", Summary: "Delete a supplier without purchase orders.", Response: deleted},
+
+	// Purchase orders
+	{Method: http.MethodGet, Path: "/purchase-orders", Tag: "Purchase orders", Summary: "Get all purchase orders.",
+		Query:    append(pageQuery, query("status", "Status of the purchase orders."), query("supplierUUID", "Supplier of the purchase orders.")),
+		Response: page("purchaseOrders", []models.PurchaseOrder{})},
+	{Method: http.MethodPost, Path: "/purchase-orders", Tag: "Purchase orders", Summary: "Create a draft purchase order from a supplier.",
+		Request: controllers.PurchaseOrderCreateRequest{}, Status: http.StatusCreated, Response: Object{"purchaseOrder": models.PurchaseOrder{}}},
+	{Method: http.MethodGet, Path: "/purchase-orders/:purchaseOrderUUID", Tag: "Purchase orders", Summary: "Get purchase order details.",
+		Response: Object{"purchaseOrder": models.PurchaseOrder{}}},
+	{Method: http.MethodPut, Path: "/purchase-orders/:purchaseOrderUUID/status", Tag: "Purchase orders", Summary: "Move a purchase order to ordered or cancelled.",
+		Request: controllers.PurchaseOrderStatusRequest{}, Form: true, Response: Object{"purchaseOrder": models.PurchaseOrder{}}},
+	{Method: http.MethodPost, Path: "/purchase-orders/:purchaseOrderUUID/receive", Tag: "Purchase orders", Summary: "Receive some or all of the ordered units and add them to stock.",
+		Request: controllers.PurchaseOrderReceiveRequest{}, Response: Object{"purchaseOrder": models.PurchaseOrder{}}},
+
+	// Imports
+	{Method: http.MethodGet, Path: "/imports", Tag: "Imports", Summary: "Get all imports.", Query: pageQuery, Response: page("imports", []models.Import{})},
+	{Method: http.MethodPost, Path: "/imports", Tag: "Imports", Summary: "Upload a CSV or XLSX file of products and variants to import in the background.",
+		Request: controllers.ImportCreateRequest{}, FormOnly: true, Status: http.StatusAccepted, Response: Object{"import": models.Import{}, "job": models.Job{}}},
+	{Method: http.MethodGet, Path: "/imports/:importUUID", Tag: "Imports", Summary: "Get the status and row counts of an import.", Response: Object{"import": models.Import{}}},
+	{Method: http.MethodGet, Path: "/imports/:importUUID/errors", Tag: "Imports", Summary: "Download the row errors of an import as CSV.",
+		Response: Binary{ContentType: "text/csv", Description: "One row, field and message per line"}},
+
+	// Exports
+	{Method: http.MethodGet, Path: "/exports/products", Tag: "Exports", Summary: "Download your products as CSV, NDJSON or XLSX.",
+		Query: exportQuery, Response: Binary{ContentType: "text/csv", Description: "The products, as application/x-ndjson or an XLSX workbook in the other formats"}},
+	{Method: http.MethodGet, Path: "/exports/variants", Tag: "Exports", Summary: "Download your variants as CSV, NDJSON or XLSX.",
+		Query: exportQuery, Response: Binary{ContentType: "text/csv", Description: "The variants, as application/x-ndjson or an XLSX workbook in the other formats"}},
+
+	// Webhooks
+	{Method: http.MethodGet, Path: "/webhooks", Tag: "Webhooks", Summary: "Get all webhook subscriptions.", Response: Object{"webhooks": []models.WebhookSubscription{}}},
+	{Method: http.MethodPost, Path: "/webhooks", Tag: "Webhooks", Summary: "Subscribe a URL to catalog and stock events.",
+		Request: controllers.WebhookRequest{}, Status: http.StatusCreated, Response: Object{"webhook": models.WebhookSubscription{}, "secret": ""}},
+	{Method: http.MethodGet, Path: "/webhooks/:webhookUUID", Tag: "Webhooks", Summary: "Get a webhook subscription.", Response: Object{"webhook": models.WebhookSubscription{}}},
+	{Method: http.MethodPut, Path: "/webhooks/:webhookUUID", Tag: "Webhooks", Summary: "Update a webhook subscription.",
+		Request: controllers.WebhookRequest{}, Response: Object{"webhook": models.WebhookSubscription{}}},
+	{Method: http.MethodDelete, Path: "/webhooks/:webhookUUID", Tag: "Webhooks", Summary: "Delete a webhook subscription.", Response: deleted},
+	{Method: http.MethodGet, Path: "/webhooks/:webhookUUID/deliveries", Tag: "Webhooks", Summary: "Get the deliveries of a webhook, newest first.",
+		Query:    append(pageQuery, query("status", "pending, succeeded or failed."), query("event", "Event type of the deliveries.")),
+		Response: page("deliveries", []models.WebhookDelivery{})},
+	{Method: http.MethodGet, Path: "/webhooks/deliveries/:deliveryUUID", Tag: "Webhooks", Summary: "Get a delivery with the log of its attempts.",
+		Response: Object{"delivery": models.WebhookDelivery{}}},
+	{Method: http.MethodPost, Path: "/webhooks/deliveries/:deliveryUUID/replay", Tag: "Webhooks", Summary: "Send a delivery's event again.",
+		Status: http.StatusAccepted, Response: Object{"delivery": models.WebhookDelivery{}}},
+
+	// Events
+	{Method: http.MethodGet, Path: "/events/stream", Tag: "Events", Summary: "Stream your catalog's product, variant and stock changes as Server-Sent Events.",
+		Query: []Parameter{query("types", "Comma separated event types to stream."), query("lastEventId", "ID of the last event received, to resume from."),
+			{Name: "Last-Event-ID", In: "header", Description: "ID of the last event received, sent by EventSource when reconnecting.", Schema: &Schema{Type: "string"}}},
+		Response: Binary{ContentType: "text/event-stream", Description: "One event per change, with its dispatch sequence as the event ID"}},
+
+	// Jobs
+	{Method: http.MethodGet, Path: "/jobs", Tag: "Jobs", Summary: "Get your background jobs, newest first.",
+		Query:    append(pageQuery, query("status", "queued, running, succeeded or failed."), query("type", "Type of the jobs.")),
+		Response: page("jobs", []models.Job{})},
+	{Method: http.MethodGet, Path: "/jobs/:jobUUID", Tag: "Jobs", Summary: "Get the status, attempts and last error of a background job.", Response: Object{"job": models.Job{}}},
+	{Method: http.MethodPost, Path: "/jobs/:jobUUID/retry", Tag: "Jobs", Summary: "Queue a failed background job again.",
+		Status: http.StatusAccepted, Response: Object{"job": models.Job{}}},
+
+	// Carts
+	{Method: http.MethodPost, Path: "/carts", Tag: "Carts", Summary: "Create a cart and get its token.", Public: true,
+		Request: controllers.CartCreateRequest{}, Form: true, Status: http.StatusCreated, Response: Object{"cart": controllers.CartResponse{}}},
+	{Method: http.MethodGet, Path: "/carts/:cartToken", Tag: "Carts", Summary: "Get a cart with its totals and stock status.", Public: true,
+		Response: Object{"cart": controllers.CartResponse{}}},
+	{Method: http.MethodPost, Path: "/carts/:cartToken/lines", Tag: "Carts", Summary: "Add a variant to a cart.", Public: true,
+		Request: controllers.CartLineRequest{}, Form: true, Response: Object{"cart": controllers.CartResponse{}}},
+	{Method: http.MethodPut, Path: "/carts/:cartToken/lines/:variantUUID", Tag: "Carts", Summary: "Change the quantity of a variant in a cart.", Public: true,
+		Request: controllers.CartLineUpdateRequest{}, Form: true, Response: Object{"cart": controllers.CartResponse{}}},
+	{Method: http.MethodDelete, Path: "/carts/:cartToken/lines/:variantUUID", Tag: "Carts", Summary: "Remove a variant from a cart.", Public: true,
+		Response: Object{"cart": controllers.CartResponse{}}},
+	{Method: http.MethodPost, Path: "/carts/:cartToken/checkout", Tag: "Carts", Summary: "Convert a cart into a pending order.", Public: true,
+		Status: http.StatusCreated, Response: Object{"order": models.Order{}}},
+
+	// Documentation
+	{Method: http.MethodGet, Path: "/openapi.json", Tag: "Documentation", Summary: "Get this OpenAPI document.", Public: true,
+		Response: Binary{ContentType: "application/json", Description: "The OpenAPI 3 document"}},
+	{Method: http.MethodGet, Path: "/docs", Tag: "Documentation", Summary: "Browse the API with Swagger UI.", Public: true,
+		Response: Binary{ContentType: "text/html", Description: "The Swagger UI page"}},
+}
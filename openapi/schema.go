@@ -0,0 +1,245 @@
+package openapi
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Schema is an OpenAPI schema object.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Object describes a JSON object response by the values of its keys, such as
+// Object{"product": models.Product{}} for gin.H{"product": product}.
+type Object map[string]interface{}
+
+// Binary describes a response body that is not JSON, such as a CSV download.
+type Binary struct {
+	ContentType string
+	Description string
+}
+
+// Well-known types that are not described by their fields.
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	deletedAtType  = reflect.TypeOf(gorm.DeletedAt{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// schemaBuilder builds schemas from Go values, adding the named struct types it meets to the
+// components of the document.
+type schemaBuilder struct {
+	components map[string]*Schema
+}
+
+// valueSchema describes a value: an Object by its keys, anything else by its type.
+func (b *schemaBuilder) valueSchema(value interface{}) *Schema {
+	if object, ok := value.(Object); ok {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, key := range keys {
+			schema.Properties[key] = b.valueSchema(object[key])
+		}
+		return schema
+	}
+	if value == nil {
+		return &Schema{}
+	}
+	return b.typeSchema(reflect.TypeOf(value), "json")
+}
+
+// typeSchema describes a Go type as encoded with the given struct tag, json for bodies and
+// form for form fields. JSON bodies refer to named structs as components; forms are flat.
+func (b *schemaBuilder) typeSchema(t reflect.Type, tag string) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var schema *Schema
+	switch {
+	case t == timeType:
+		schema = &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		schema = &Schema{Type: "integer", Format: "int64"}
+	case t == uuidType:
+		schema = &Schema{Type: "string", Format: "uuid"}
+	case t == deletedAtType:
+		schema = &Schema{Type: "string", Format: "date-time", Nullable: true}
+	case t == rawMessageType:
+		schema = &Schema{}
+	case t == fileHeaderType:
+		schema = &Schema{Type: "string", Format: "binary"}
+	default:
+		schema = b.kindSchema(t, tag)
+	}
+
+	if nullable && schema.Ref == "" {
+		schema.Nullable = true
+	}
+	return schema
+}
+
+// kindSchema describes a Go type by its kind.
+func (b *schemaBuilder) kindSchema(t reflect.Type, tag string) *Schema {
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.typeSchema(t.Elem(), tag)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.typeSchema(t.Elem(), tag)}
+	case reflect.Struct:
+		if t.Name() == "" || tag != "json" {
+			return b.structSchema(t, tag)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			// Register the name first, so that recursive types refer to themselves
+			b.components[t.Name()] = &Schema{}
+			*b.components[t.Name()] = *b.structSchema(t, tag)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema describes the fields of a struct as named by the given tag, flattening
+// embedded structs. Fields required by their valid or binding tag are listed as required,
+// and the in, length, email, url and uuid rules of valid tags are described.
+func (b *schemaBuilder) structSchema(t reflect.Type, tag string) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := b.structSchema(embedded, tag)
+				for key, property := range inner.Properties {
+					schema.Properties[key] = property
+				}
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+
+		// Forms only bind the fields with a form tag, and files only come in forms
+		if name == "" {
+			if tag != "json" {
+				continue
+			}
+			name = field.Name
+		}
+		if tag == "json" && isFile(field.Type) {
+			continue
+		}
+
+		property := b.typeSchema(field.Type, tag)
+		if applyValidation(property, field.Tag) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// isFile reports whether a field holds an uploaded file.
+func isFile(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == fileHeaderType
+}
+
+// applyValidation describes the valid tag rules of a field on its schema, and reports
+// whether the field is required.
+func applyValidation(schema *Schema, tag reflect.StructTag) bool {
+	required := false
+	for _, rule := range strings.Split(tag.Get("binding"), ",") {
+		if rule == "required" {
+			required = true
+		}
+	}
+
+	for _, rule := range strings.Split(tag.Get("valid"), ",") {
+		name, args, _ := strings.Cut(strings.TrimSuffix(rule, ")"), "(")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "uuid", "uuidv4":
+			schema.Format = "uuid"
+		case "in":
+			schema.Enum = strings.Split(args, "|")
+		case "length", "runelength":
+			bounds := strings.Split(args, "|")
+			if len(bounds) == 2 {
+				if min, err := strconv.Atoi(bounds[0]); err == nil {
+					schema.MinLength = &min
+				}
+				if max, err := strconv.Atoi(bounds[1]); err == nil {
+					schema.MaxLength = &max
+				}
+			}
+		}
+	}
+	return required
+}
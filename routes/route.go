@@ -3,6 +3,9 @@ package routes
 import (
 	"basictrade/controllers"
 	"basictrade/middleware"
+	"basictrade/models"
+	"basictrade/openapi"
+	"log"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,6 +13,9 @@ import (
 func StartApp() *gin.Engine {
 	router := gin.Default()
 
+	// Replay the responses of POST requests retried with the same Idempotency-Key
+	router.Use(middleware.IdempotencyMiddleware())
+
 	// Auth routes
 	auth := router.Group("/auth")
 	{
@@ -28,13 +34,218 @@ func StartApp() *gin.Engine {
 		product.PUT("/:productUUID", middleware.ValidateProductAuthorization(),controllers.UpdateProduct)
 		product.DELETE("/:productUUID",middleware.ValidateProductAuthorization(), controllers.DeleteProduct)
 		product.GET("/:productUUID", controllers.GetProductDetail)
+		product.POST("/:productUUID/categories", middleware.ValidateProductAuthorization(), controllers.AddProductCategories)
+		product.DELETE("/:productUUID/categories/:categoryUUID", middleware.ValidateProductAuthorization(), controllers.RemoveProductCategory)
+		product.POST("/:productUUID/tags", middleware.ValidateProductAuthorization(), controllers.AddProductTags)
+		product.DELETE("/:productUUID/tags/:tag", middleware.ValidateProductAuthorization(), controllers.RemoveProductTag)
+		product.GET("/:productUUID/options", controllers.GetProductOptions)
+		product.PUT("/:productUUID/options", middleware.ValidateProductAuthorization(), controllers.SetProductOptions)
+		product.POST("/:productUUID/variants/generate", middleware.ValidateProductAuthorization(), controllers.GenerateVariants)
 
 		// Variant routes
 		product.GET("/variants", controllers.GetAllVariants)
+		product.GET("/variants/lookup", controllers.LookupVariant)
 		product.POST("/variants", controllers.CreateVariant)
+		product.POST("/variants/batch", controllers.BatchVariants)
 		product.PUT("/variants/:variantUUID", middleware.ValidateVariantAuthorization(),controllers.UpdateVariant)
 		product.DELETE("/variants/:variantUUID", middleware.ValidateVariantAuthorization(),controllers.DeleteVariant)
 		product.GET("/variants/:variantUUID", controllers.GetVariantDetail)
+		product.GET("/variants/:variantUUID/barcode", middleware.ValidateVariantAuthorization(), controllers.GetVariantBarcode)
+		product.GET("/variants/:variantUUID/components", middleware.ValidateVariantAuthorization(), controllers.GetVariantComponents)
+		product.PUT("/variants/:variantUUID/components", middleware.ValidateVariantAuthorization(), controllers.SetVariantComponents)
+	}
+
+	// Search routes
+	search := router.Group("/search")
+	{
+		// Middleware
+		search.Use(middleware.AuthMiddleware())
+
+		search.GET("", controllers.Search)
+		search.POST("/reindex", controllers.ReindexSearch)
+	}
+
+	// Exchange rate routes
+	exchangeRate := router.Group("/exchange-rates")
+	{
+		// Middleware
+		exchangeRate.Use(middleware.AuthMiddleware())
+
+		exchangeRate.GET("", controllers.GetAllExchangeRates)
+
+		// Rates are shared by every catalog, so only superadmins change them
+		superadmin := middleware.RequireRole(models.AdminRoleSuperadmin)
+		exchangeRate.POST("", superadmin, controllers.UpsertExchangeRate)
+		exchangeRate.POST("/upload", superadmin, controllers.UploadExchangeRates)
+		exchangeRate.PUT("/:currency", superadmin, controllers.UpsertExchangeRate)
+		exchangeRate.DELETE("/:currency", superadmin, controllers.DeleteExchangeRate)
+	}
+
+	// Category routes
+	category := router.Group("/categories")
+	{
+		// Middleware
+		category.Use(middleware.AuthMiddleware())
+
+		category.GET("", controllers.GetAllCategories)
+		category.POST("", controllers.CreateCategory)
+		category.GET("/:categoryUUID", middleware.ValidateCategoryAuthorization(), controllers.GetCategoryDetail)
+		category.PUT("/:categoryUUID", middleware.ValidateCategoryAuthorization(), controllers.UpdateCategory)
+		category.DELETE("/:categoryUUID", middleware.ValidateCategoryAuthorization(), controllers.DeleteCategory)
+	}
+
+	// Tag routes
+	tag := router.Group("/tags")
+	{
+		// Middleware
+		tag.Use(middleware.AuthMiddleware())
+
+		tag.GET("", controllers.GetAllTags)
+	}
+
+	// Promotion routes
+	promotion := router.Group("/promotions")
+	{
+		// Middleware
+		promotion.Use(middleware.AuthMiddleware())
+
+		promotion.GET("", controllers.GetAllPromotions)
+		promotion.POST("", controllers.CreatePromotion)
+		promotion.POST("/evaluate", controllers.EvaluatePromotions)
+		promotion.GET("/:promotionUUID", middleware.ValidatePromotionAuthorization(), controllers.GetPromotionDetail)
+		promotion.PUT("/:promotionUUID", middleware.ValidatePromotionAuthorization(), controllers.UpdatePromotion)
+		promotion.DELETE("/:promotionUUID", middleware.ValidatePromotionAuthorization(), controllers.DeletePromotion)
+	}
+
+	// Order routes
+	order := router.Group("/orders")
+	{
+		// Middleware
+		order.Use(middleware.AuthMiddleware())
+
+		order.GET("", controllers.GetAllOrders)
+		order.POST("", controllers.CreateOrder)
+		order.GET("/:orderUUID", middleware.ValidateOrderAuthorization(), controllers.GetOrderDetail)
+		order.PUT("/:orderUUID/status", middleware.ValidateOrderAuthorization(), controllers.UpdateOrderStatus)
+		order.POST("/:orderUUID/returns", middleware.ValidateOrderAuthorization(), controllers.CreateReturn)
+	}
+
+	// Return routes
+	returns := router.Group("/returns")
+	{
+		// Middleware
+		returns.Use(middleware.AuthMiddleware())
+
+		returns.GET("", controllers.GetAllReturns)
+		returns.GET("/:returnUUID", middleware.ValidateReturnAuthorization(), controllers.GetReturnDetail)
+		returns.PUT("/:returnUUID/approve", middleware.ValidateReturnAuthorization(), controllers.ApproveReturn)
+		returns.PUT("/:returnUUID/reject", middleware.ValidateReturnAuthorization(), controllers.RejectReturn)
+		returns.PUT("/:returnUUID/receive", middleware.ValidateReturnAuthorization(), controllers.ReceiveReturn)
+		returns.PUT("/:returnUUID/refund", middleware.ValidateReturnAuthorization(), controllers.RefundReturn)
+	}
+
+	// Supplier routes
+	supplier := router.Group("/suppliers")
+	{
+		// Middleware
+		supplier.Use(middleware.AuthMiddleware())
+
+		supplier.GET("", controllers.GetAllSuppliers)
+		supplier.POST("", controllers.CreateSupplier)
+		supplier.GET("/:supplierUUID", middleware.ValidateSupplierAuthorization(), controllers.GetSupplierDetail)
+		supplier.PUT("/:supplierUUID", middleware.ValidateSupplierAuthorization(), controllers.UpdateSupplier)
+		supplier.DELETE("/:supplierUUID", middleware.ValidateSupplierAuthorization(), controllers.DeleteSupplier)
+	}
+
+	// Purchase order routes
+	purchaseOrder := router.Group("/purchase-orders")
+	{
+		// Middleware
+		purchaseOrder.Use(middleware.AuthMiddleware())
+
+		purchaseOrder.GET("", controllers.GetAllPurchaseOrders)
+		purchaseOrder.POST("", controllers.CreatePurchaseOrder)
+		purchaseOrder.GET("/:purchaseOrderUUID", middleware.ValidatePurchaseOrderAuthorization(), controllers.GetPurchaseOrderDetail)
+		purchaseOrder.PUT("/:purchaseOrderUUID/status", middleware.ValidatePurchaseOrderAuthorization(), controllers.UpdatePurchaseOrderStatus)
+		purchaseOrder.POST("/:purchaseOrderUUID/receive", middleware.ValidatePurchaseOrderAuthorization(), controllers.ReceivePurchaseOrder)
+	}
+
+	// Import routes
+	imports := router.Group("/imports")
+	{
+		// Middleware
+		imports.Use(middleware.AuthMiddleware())
+
+		imports.GET("", controllers.GetAllImports)
+		imports.POST("", controllers.CreateImport)
+		imports.GET("/:importUUID", middleware.ValidateImportAuthorization(), controllers.GetImportDetail)
+		imports.GET("/:importUUID/errors", middleware.ValidateImportAuthorization(), controllers.GetImportErrors)
+	}
+
+	// Export routes
+	exports := router.Group("/exports")
+	{
+		// Middleware
+		exports.Use(middleware.AuthMiddleware())
+
+		exports.GET("/products", controllers.ExportProducts)
+		exports.GET("/variants", controllers.ExportVariants)
+	}
+
+	// Webhook routes
+	webhook := router.Group("/webhooks")
+	{
+		// Middleware
+		webhook.Use(middleware.AuthMiddleware())
+
+		webhook.GET("", controllers.GetAllWebhooks)
+		webhook.POST("", controllers.CreateWebhook)
+		webhook.GET("/:webhookUUID", middleware.ValidateWebhookAuthorization(), controllers.GetWebhookDetail)
+		webhook.PUT("/:webhookUUID", middleware.ValidateWebhookAuthorization(), controllers.UpdateWebhook)
+		webhook.DELETE("/:webhookUUID", middleware.ValidateWebhookAuthorization(), controllers.DeleteWebhook)
+		webhook.GET("/:webhookUUID/deliveries", middleware.ValidateWebhookAuthorization(), controllers.GetWebhookDeliveries)
+		webhook.GET("/deliveries/:deliveryUUID", middleware.ValidateWebhookDeliveryAuthorization(), controllers.GetWebhookDeliveryDetail)
+		webhook.POST("/deliveries/:deliveryUUID/replay", middleware.ValidateWebhookDeliveryAuthorization(), controllers.ReplayWebhookDelivery)
+	}
+
+	// Job routes
+	job := router.Group("/jobs")
+	{
+		// Middleware
+		job.Use(middleware.AuthMiddleware())
+
+		job.GET("", controllers.GetAllJobs)
+		job.GET("/:jobUUID", middleware.ValidateJobAuthorization(), controllers.GetJobDetail)
+		job.POST("/:jobUUID/retry", middleware.ValidateJobAuthorization(), controllers.RetryJob)
+	}
+
+	// Event routes
+	events := router.Group("/events")
+	{
+		// Middleware
+		events.Use(middleware.AuthMiddleware())
+
+		events.GET("/stream", controllers.StreamEvents)
+	}
+
+	// Cart routes, used anonymously by storefront clients through the cart token
+	cart := router.Group("/carts")
+	{
+		cart.POST("", controllers.CreateCart)
+		cart.GET("/:cartToken", controllers.GetCart)
+		cart.POST("/:cartToken/lines", controllers.AddCartLine)
+		cart.PUT("/:cartToken/lines/:variantUUID", controllers.UpdateCartLine)
+		cart.DELETE("/:cartToken/lines/:variantUUID", controllers.DeleteCartLine)
+		cart.POST("/:cartToken/checkout", controllers.CheckoutCart)
+	}
+
+	// API documentation routes
+	router.GET("/openapi.json", openapi.ServeDocument)
+	router.GET("/docs", openapi.ServeUI)
+
+	// Routes missing from the API document are caught by the openapi tests; the rest is served
+	if err := openapi.Generate(router.Routes()); err != nil {
+		log.Println("Failed to generate the API document:", err)
 	}
 
 	return router
@@ -0,0 +1,173 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// bleveIndex is an Index backed by an embedded Bleve index.
+type bleveIndex struct {
+	index bleve.Index
+}
+
+// NewBleveIndex opens the Bleve index at path, creating it when it does not exist. An empty
+// path keeps the index in memory only.
+func NewBleveIndex(path string) (Index, error) {
+	if path == "" {
+		index, err := bleve.NewMemOnly(documentMapping())
+		if err != nil {
+			return nil, err
+		}
+		return &bleveIndex{index: index}, nil
+	}
+
+	index, err := bleve.Open(path)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		index, err = bleve.New(path, documentMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bleveIndex{index: index}, nil
+}
+
+// documentMapping analyzes names and descriptions as English text and keeps identifiers as keywords.
+func documentMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+
+	identifier := bleve.NewTextFieldMapping()
+	identifier.Analyzer = keyword.Name
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("type", identifier)
+	doc.AddFieldMappingsAt("uuid", identifier)
+	doc.AddFieldMappingsAt("admin_uuid", identifier)
+	doc.AddFieldMappingsAt("product_uuid", identifier)
+	doc.AddFieldMappingsAt("name", text)
+	doc.AddFieldMappingsAt("description", text)
+	doc.AddFieldMappingsAt("sku", identifier)
+	doc.AddFieldMappingsAt("barcode", identifier)
+	doc.AddFieldMappingsAt("tags", text)
+	doc.AddFieldMappingsAt("attributes", text)
+
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.DefaultMapping = doc
+	return indexMapping
+}
+
+func (b *bleveIndex) Index(docs ...Document) error {
+	batch := b.index.NewBatch()
+	for _, doc := range docs {
+		if err := batch.Index(doc.ID(), doc); err != nil {
+			return err
+		}
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *bleveIndex) Delete(ids ...string) error {
+	batch := b.index.NewBatch()
+	for _, id := range ids {
+		batch.Delete(id)
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *bleveIndex) Search(q Query) (Result, error) {
+	adminQuery := bleve.NewTermQuery(q.AdminUUID)
+	adminQuery.SetField("admin_uuid")
+	conjuncts := []query.Query{adminQuery}
+
+	if q.ProductUUID != "" {
+		productQuery := bleve.NewTermQuery(q.ProductUUID)
+		productQuery.SetField("product_uuid")
+		conjuncts = append(conjuncts, productQuery)
+	}
+
+	if q.Type != "" {
+		typeQuery := bleve.NewTermQuery(q.Type)
+		typeQuery.SetField("type")
+		conjuncts = append(conjuncts, typeQuery)
+	}
+
+	if text := strings.TrimSpace(q.Text); text != "" {
+		conjuncts = append(conjuncts, textQuery(text))
+	}
+
+	request := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(conjuncts...), q.Size, q.From, false)
+	request.Highlight = bleve.NewHighlightWithStyle("html")
+	request.Highlight.AddField("name")
+	request.Highlight.AddField("description")
+	request.Fields = []string{"type", "uuid"}
+
+	response, err := b.index.Search(request)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Total: response.Total, Hits: make([]Hit, 0, len(response.Hits))}
+	for _, hit := range response.Hits {
+		docType, _ := hit.Fields["type"].(string)
+		uuid, _ := hit.Fields["uuid"].(string)
+		result.Hits = append(result.Hits, Hit{
+			Type:       docType,
+			UUID:       uuid,
+			Score:      hit.Score,
+			Highlights: matchedFragments(hit.Fragments),
+		})
+	}
+
+	return result, nil
+}
+
+func (b *bleveIndex) Close() error {
+	return b.index.Close()
+}
+
+// textQuery matches the text against every searchable field. Names weigh the most, exact
+// phrases beat scattered words, and words one typo away still match.
+func textQuery(text string) query.Query {
+	var disjuncts []query.Query
+
+	for field, boost := range map[string]float64{"name": 3, "tags": 2, "attributes": 1.5, "description": 1} {
+		match := bleve.NewMatchQuery(text)
+		match.SetField(field)
+		match.SetFuzziness(1)
+		match.SetBoost(boost)
+
+		phrase := bleve.NewMatchPhraseQuery(text)
+		phrase.SetField(field)
+		phrase.SetBoost(boost * 2)
+
+		disjuncts = append(disjuncts, match, phrase)
+	}
+
+	// Identifiers only match exactly
+	for _, field := range []string{"sku", "barcode"} {
+		term := bleve.NewTermQuery(text)
+		term.SetField(field)
+		term.SetBoost(5)
+		disjuncts = append(disjuncts, term)
+	}
+
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// matchedFragments drops the fragments of fields that did not match the query.
+func matchedFragments(fragments map[string][]string) map[string][]string {
+	matched := make(map[string][]string, len(fragments))
+	for field, fieldFragments := range fragments {
+		for _, fragment := range fieldFragments {
+			if strings.Contains(fragment, "<mark>") {
+				matched[field] = append(matched[field], fragment)
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,91 @@
+// Package search indexes products and variants for full-text search. The index behind it is
+// pluggable; Bleve is the default.
+package search
+
+import "sync"
+
+// Document types.
+const (
+	TypeProduct = "product"
+	TypeVariant = "variant"
+)
+
+// Document is a product or variant as stored in the search index.
+type Document struct {
+	Type        string   `json:"type"`
+	UUID        string   `json:"uuid"`
+	AdminUUID   string   `json:"admin_uuid"`
+	ProductUUID string   `json:"product_uuid"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	SKU         string   `json:"sku"`
+	Barcode     string   `json:"barcode"`
+	Tags        []string `json:"tags"`
+	Attributes  []string `json:"attributes"`
+}
+
+// ID returns the key of the document in the index.
+func (doc Document) ID() string {
+	return DocumentID(doc.Type, doc.UUID)
+}
+
+// DocumentID returns the key of a product or variant in the index.
+func DocumentID(docType string, uuid string) string {
+	return docType + ":" + uuid
+}
+
+// Query searches the documents of one admin, narrowed to one product when ProductUUID is set.
+// An empty text matches every document.
+type Query struct {
+	Text        string
+	AdminUUID   string
+	ProductUUID string
+	Type        string
+	From        int
+	Size        int
+}
+
+// Hit is a document matching a query, with the matched text of its fields highlighted.
+type Hit struct {
+	Type       string              `json:"type"`
+	UUID       string              `json:"uuid"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// Result is a page of hits, best first, and the total number of matching documents.
+type Result struct {
+	Total uint64
+	Hits  []Hit
+}
+
+// Index stores documents and answers queries over them.
+type Index interface {
+	// Index adds the documents, replacing those with the same ID.
+	Index(docs ...Document) error
+	// Delete removes the documents with the IDs.
+	Delete(ids ...string) error
+	// Search returns the documents matching the query, best first.
+	Search(query Query) (Result, error)
+	// Close releases the resources of the index.
+	Close() error
+}
+
+var (
+	defaultIndex Index
+	mu           sync.RWMutex
+)
+
+// SetDefault makes index the one used by the application.
+func SetDefault(index Index) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultIndex = index
+}
+
+// Default returns the index used by the application, or nil before SetDefault is called.
+func Default() Index {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultIndex
+}
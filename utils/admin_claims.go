@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// GetAdminUUID extracts the authenticated admin's UUID from the claims set by AuthMiddleware.
+func GetAdminUUID(c *gin.Context) (uuid.UUID, error) {
+	adminData, ok := c.MustGet("adminData").(jwt.MapClaims)
+	if !ok {
+		return uuid.Nil, errors.New("Unauthorized")
+	}
+
+	adminUUIDStr, ok := adminData["adminUUID"].(string)
+	if !ok {
+		return uuid.Nil, errors.New("Invalid admin UUID format")
+	}
+
+	adminUUID, err := uuid.Parse(adminUUIDStr)
+	if err != nil {
+		return uuid.Nil, errors.New("Invalid admin UUID format")
+	}
+
+	return adminUUID, nil
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"basictrade/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The unique indexes of the variant identifiers, and the MySQL error a duplicate key raises.
+const (
+	variantSKUIndex     = "idx_variants_admin_sku"
+	variantBarcodeIndex = "idx_variants_admin_barcode"
+	mysqlDuplicateEntry = 1062
+)
+
+// Barcode symbologies and image formats supported by RenderBarcode.
+const (
+	BarcodeCode128 = "code128"
+	BarcodeEAN13   = "ean13"
+
+	BarcodeFormatPNG = "png"
+	BarcodeFormatSVG = "svg"
+)
+
+var (
+	// ErrInvalidBarcode is returned when a barcode is not a GTIN with a valid check digit.
+	ErrInvalidBarcode = errors.New("Barcode must be a GTIN-8, UPC-A, EAN-13 or GTIN-14 with a valid check digit")
+	// ErrInvalidSKU is returned when a SKU is too long or contains whitespace.
+	ErrInvalidSKU = errors.New("SKU must be at most 64 characters without whitespace")
+	// ErrDuplicateSKU is returned when another variant of the catalog already has the SKU.
+	ErrDuplicateSKU = errors.New("SKU is already used by another variant")
+	// ErrDuplicateBarcode is returned when another variant of the catalog already has the barcode.
+	ErrDuplicateBarcode = errors.New("Barcode is already used by another variant")
+	// ErrBarcodeNotEncodable is returned when a value cannot be drawn in the requested symbology.
+	ErrBarcodeNotEncodable = errors.New("Value cannot be encoded in the requested barcode symbology")
+)
+
+// NormalizeSKU trims a SKU and checks that it fits the column and has no inner whitespace.
+func NormalizeSKU(sku string) (string, error) {
+	sku = strings.TrimSpace(sku)
+	if len(sku) > 64 || strings.ContainsAny(sku, " \t\r\n") {
+		return "", ErrInvalidSKU
+	}
+	return sku, nil
+}
+
+// NormalizeGTIN strips spaces and dashes from a GTIN-8, UPC-A (GTIN-12), EAN-13 or GTIN-14
+// and checks its check digit.
+func NormalizeGTIN(code string) (string, error) {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+	if code == "" {
+		return "", nil
+	}
+
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", ErrInvalidBarcode
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidBarcode
+		}
+	}
+
+	if GTINCheckDigit(code[:len(code)-1]) != code[len(code)-1] {
+		return "", ErrInvalidBarcode
+	}
+
+	return code, nil
+}
+
+// GTINCheckDigit computes the GS1 check digit of a GTIN without its last digit.
+func GTINCheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		// Digits are weighted 3 and 1 alternately, starting with 3 next to the check digit
+		if (len(digits)-1-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// CheckVariantIdentifiers checks that no other variant of the admin's catalog has the SKU
+// or barcode of the variant.
+func CheckVariantIdentifiers(tx *gorm.DB, adminUUID uuid.UUID, variant models.Variant) error {
+	if variant.SKU != "" {
+		var count int64
+		if err := CatalogVariants(tx, adminUUID).Where("variants.sku = ? AND variants.uuid <> ?", variant.SKU, variant.UUID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", ErrDuplicateSKU, variant.SKU)
+		}
+	}
+
+	if variant.Barcode != "" {
+		var count int64
+		if err := WhereBarcode(CatalogVariants(tx, adminUUID), variant.Barcode).Where("variants.uuid <> ?", variant.UUID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", ErrDuplicateBarcode, variant.Barcode)
+		}
+	}
+
+	return nil
+}
+
+// CatalogVariants returns a query over the variants of the admin's products.
+func CatalogVariants(db *gorm.DB, adminUUID uuid.UUID) *gorm.DB {
+	return db.Model(&models.Variant{}).
+		Joins("JOIN products ON products.uuid = variants.product_uuid").
+		Where("products.admin_uuid = ?", adminUUID)
+}
+
+// WhereBarcode filters variants on a GTIN. Leading zeros are ignored, so a UPC-A code
+// matches the same product's EAN-13 or GTIN-14 code.
+func WhereBarcode(query *gorm.DB, code string) *gorm.DB {
+	return query.Where("variants.barcode_key = ?", strings.TrimLeft(code, "0"))
+}
+
+// VariantIdentifierError returns ErrDuplicateSKU or ErrDuplicateBarcode when err is the
+// database refusing a variant whose SKU or barcode another variant of the catalog took since
+// it was checked, and err otherwise.
+func VariantIdentifierError(err error, variant models.Variant) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlDuplicateEntry {
+		return err
+	}
+
+	switch {
+	case strings.Contains(mysqlErr.Message, variantSKUIndex):
+		return fmt.Errorf("%w: %s", ErrDuplicateSKU, variant.SKU)
+	case strings.Contains(mysqlErr.Message, variantBarcodeIndex):
+		return fmt.Errorf("%w: %s", ErrDuplicateBarcode, variant.Barcode)
+	}
+	return err
+}
+
+// SaveVariant saves the fields of a variant, without its associations.
+func SaveVariant(tx *gorm.DB, variant *models.Variant) error {
+	return VariantIdentifierError(tx.Omit(clause.Associations).Save(variant).Error, *variant)
+}
+
+// BackfillVariantKeys sets the owner and identifier keys of the variants saved before they
+// existed, so the unique indexes cover them. Owners are set first, then the keys one variant
+// at a time: a key another variant of the catalog already holds is left NULL, and the
+// duplicate is returned so it can be changed by hand.
+func BackfillVariantKeys(db *gorm.DB) ([]error, error) {
+	if err := db.Exec(`UPDATE variants JOIN products ON products.uuid = variants.product_uuid
+		SET variants.admin_uuid = products.admin_uuid
+		WHERE variants.admin_uuid IS NULL OR variants.admin_uuid IN ('', ?)`, uuid.Nil.String()).Error; err != nil {
+		return nil, err
+	}
+
+	var duplicates []error
+	var variants []models.Variant
+	err := db.Select("id", "uuid", "sku", "barcode", "sku_key", "barcode_key").
+		Where("(sku_key IS NULL AND sku <> '') OR (barcode_key IS NULL AND TRIM(LEADING '0' FROM barcode) <> '')").
+		FindInBatches(&variants, 500, func(tx *gorm.DB, batch int) error {
+			for _, variant := range variants {
+				columns := map[string]*string{}
+				if variant.SKUKey == nil && variant.SKU != "" {
+					sku := variant.SKU
+					columns["sku_key"] = &sku
+				}
+				if barcode := strings.TrimLeft(variant.Barcode, "0"); variant.BarcodeKey == nil && barcode != "" {
+					columns["barcode_key"] = &barcode
+				}
+
+				// Each key on its own, so a duplicate SKU does not keep the barcode from being keyed
+				for column, key := range columns {
+					err := VariantIdentifierError(db.Model(&models.Variant{}).Where("id = ?", variant.ID).UpdateColumn(column, key).Error, variant)
+					if errors.Is(err, ErrDuplicateSKU) || errors.Is(err, ErrDuplicateBarcode) {
+						duplicates = append(duplicates, fmt.Errorf("variant %s: %w", variant.UUID, err))
+						continue
+					}
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}).Error
+
+	return duplicates, err
+}
+
+// RenderBarcode draws value as a Code128 or EAN-13 barcode of the given size, encoded as
+// PNG or SVG. It returns the image and its content type.
+func RenderBarcode(value string, symbology string, format string, width int, height int) ([]byte, string, error) {
+	var code barcode.Barcode
+	var err error
+	switch symbology {
+	case BarcodeEAN13:
+		// UPC-A codes are EAN-13 codes with a leading zero
+		if len(value) == 12 {
+			value = "0" + value
+		}
+		if len(value) != 13 {
+			return nil, "", ErrBarcodeNotEncodable
+		}
+		code, err = ean.Encode(value)
+	case BarcodeCode128:
+		code, err = code128.Encode(value)
+	default:
+		return nil, "", ErrBarcodeNotEncodable
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrBarcodeNotEncodable, err.Error())
+	}
+
+	// Never scale below one pixel per module
+	if width < code.Bounds().Dx() {
+		width = code.Bounds().Dx()
+	}
+
+	if format == BarcodeFormatSVG {
+		return barcodeSVG(code, width, height), "image/svg+xml", nil
+	}
+
+	scaled, err := barcode.Scale(code, width, height)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaled); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// barcodeSVG draws the bars of a one-dimensional barcode as SVG rectangles.
+func barcodeSVG(code barcode.Barcode, width int, height int) []byte {
+	modules := code.Bounds().Dx()
+	moduleWidth := float64(width) / float64(modules)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`, width, height)
+
+	for x := 0; x < modules; {
+		if !isDark(code.At(code.Bounds().Min.X+x, code.Bounds().Min.Y)) {
+			x++
+			continue
+		}
+
+		// Merge adjacent dark modules into one bar
+		start := x
+		for x < modules && isDark(code.At(code.Bounds().Min.X+x, code.Bounds().Min.Y)) {
+			x++
+		}
+		fmt.Fprintf(&buf, `<rect x="%.2f" width="%.2f" height="%d"/>`, float64(start)*moduleWidth, float64(x-start)*moduleWidth, height)
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// isDark reports whether a barcode module is a bar.
+func isDark(c color.Color) bool {
+	gray := color.GrayModel.Convert(c).(color.Gray)
+	return gray.Y < 128
+}
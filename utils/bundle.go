@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"basictrade/models"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotBundle is returned when components are set on a variant of a standard product.
+	ErrNotBundle = errors.New("Only variants of bundle products can have components")
+	// ErrInvalidComponent is returned when a component is the bundle itself, another bundle or has no quantity.
+	ErrInvalidComponent = errors.New("Components must be variants of standard products with a quantity of at least one")
+	// ErrComponentInUse is returned when a variant that is part of a bundle is deleted.
+	ErrComponentInUse = errors.New("Variant is a component of a bundle")
+	// ErrBundleHasComponents is returned when a bundle product with components is made a standard product.
+	ErrBundleHasComponents = errors.New("Remove the components of the bundle's variants first")
+)
+
+// BundleItem is a component variant and its quantity per bundle.
+type BundleItem struct {
+	VariantUUID string
+	Quantity    uint
+}
+
+// SetBundleComponents replaces the components of a bundle variant, with the variant.updated
+// event reporting them. Components must be variants of standard products in the admin's
+// catalog.
+func SetBundleComponents(db *gorm.DB, adminUUID uuid.UUID, bundle models.Variant, items []BundleItem) ([]models.BundleComponent, error) {
+	components := make([]models.BundleComponent, 0, len(items))
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Where("uuid = ?", bundle.ProductUUID).First(&product).Error; err != nil {
+			return err
+		}
+		if product.Type != models.ProductTypeBundle {
+			return ErrNotBundle
+		}
+
+		// Merge repeated variants into one component
+		componentIndex := make(map[string]int)
+		for _, item := range items {
+			if item.Quantity == 0 || item.VariantUUID == bundle.UUID {
+				return fmt.Errorf("%w: %s", ErrInvalidComponent, item.VariantUUID)
+			}
+			if i, ok := componentIndex[item.VariantUUID]; ok {
+				components[i].Quantity += item.Quantity
+				continue
+			}
+
+			variant, err := FindCatalogVariant(tx, adminUUID, item.VariantUUID)
+			if err != nil {
+				return err
+			}
+
+			var componentProduct models.Product
+			if err := tx.Where("uuid = ?", variant.ProductUUID).First(&componentProduct).Error; err != nil {
+				return err
+			}
+			if componentProduct.Type == models.ProductTypeBundle {
+				return fmt.Errorf("%w: %s", ErrInvalidComponent, item.VariantUUID)
+			}
+
+			componentIndex[item.VariantUUID] = len(components)
+			components = append(components, models.BundleComponent{
+				BundleVariantUUID:    uuid.MustParse(bundle.UUID),
+				ComponentVariantUUID: uuid.MustParse(variant.UUID),
+				Quantity:             item.Quantity,
+			})
+		}
+
+		if err := tx.Where("bundle_variant_uuid = ?", bundle.UUID).Delete(&models.BundleComponent{}).Error; err != nil {
+			return err
+		}
+		if len(components) > 0 {
+			if err := tx.Create(&components).Error; err != nil {
+				return err
+			}
+		}
+
+		bundle.Components = components
+		return RecordVariantEvent(tx, adminUUID, models.EventVariantUpdated, bundle)
+	})
+
+	return components, err
+}
+
+// BundleAvailability returns how many bundles can be assembled from the stock of the
+// components, given the component variants by UUID.
+func BundleAvailability(components []models.BundleComponent, variants map[string]models.Variant) uint {
+	var available uint
+	for i, component := range components {
+		// A deleted component leaves nothing to assemble
+		stock := variants[component.ComponentVariantUUID.String()].Quantity / component.Quantity
+		if i == 0 || stock < available {
+			available = stock
+		}
+	}
+	return available
+}
+
+// FillBundleAvailability loads the components of the variants and, for bundle variants,
+// replaces their quantity with the number of bundles the component stock allows.
+func FillBundleAvailability(db *gorm.DB, variants []models.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	variantUUIDs := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		variantUUIDs = append(variantUUIDs, variant.UUID)
+	}
+
+	var components []models.BundleComponent
+	if err := db.Where("bundle_variant_uuid IN ?", variantUUIDs).Order("id").Find(&components).Error; err != nil {
+		return err
+	}
+	if len(components) == 0 {
+		return nil
+	}
+
+	componentUUIDs := make([]string, 0, len(components))
+	componentsByBundle := make(map[string][]models.BundleComponent)
+	for _, component := range components {
+		componentUUIDs = append(componentUUIDs, component.ComponentVariantUUID.String())
+		bundleUUID := component.BundleVariantUUID.String()
+		componentsByBundle[bundleUUID] = append(componentsByBundle[bundleUUID], component)
+	}
+
+	var componentVariants []models.Variant
+	if err := db.Where("uuid IN ?", componentUUIDs).Find(&componentVariants).Error; err != nil {
+		return err
+	}
+	stock := make(map[string]models.Variant, len(componentVariants))
+	for _, variant := range componentVariants {
+		stock[variant.UUID] = variant
+	}
+
+	for i := range variants {
+		if bundleComponents, ok := componentsByBundle[variants[i].UUID]; ok {
+			variants[i].Components = bundleComponents
+			variants[i].Quantity = BundleAvailability(bundleComponents, stock)
+		}
+	}
+
+	return nil
+}
+
+// CheckComponentInUse returns ErrComponentInUse when the variant is a component of a bundle.
+func CheckComponentInUse(db *gorm.DB, variantUUID string) error {
+	var count int64
+	if err := db.Model(&models.BundleComponent{}).Where("component_variant_uuid = ?", variantUUID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrComponentInUse
+	}
+	return nil
+}
+
+// CheckBundleType returns ErrBundleHasComponents when a bundle product whose variants have
+// components would become a standard product.
+func CheckBundleType(db *gorm.DB, product models.Product, productType string) error {
+	if product.Type != models.ProductTypeBundle || productType == models.ProductTypeBundle {
+		return nil
+	}
+
+	var count int64
+	if err := db.Model(&models.BundleComponent{}).
+		Where("bundle_variant_uuid IN (?)", db.Model(&models.Variant{}).Select("uuid").Where("product_uuid = ?", product.UUID)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrBundleHasComponents
+	}
+	return nil
+}
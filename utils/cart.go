@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"basictrade/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewCartToken generates a random token that identifies a cart.
+func NewCartToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+// PurgeExpiredCarts deletes carts, and their lines, that expired before now.
+func PurgeExpiredCarts(db *gorm.DB, now time.Time) (int64, error) {
+	var purged int64
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.Cart{}).Select("uuid").Where("expires_at < ?", now)
+		if err := tx.Where("cart_uuid IN (?)", expired).Delete(&models.CartLine{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("expires_at < ?", now).Delete(&models.Cart{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+
+	return purged, err
+}
+
+// purgeExpiredCartsJob runs PurgeExpiredCarts as a background job.
+func purgeExpiredCartsJob(ctx context.Context, db *gorm.DB, job models.Job) error {
+	purged, err := PurgeExpiredCarts(db, time.Now())
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("Purged %d expired carts\n", purged)
+	}
+	return nil
+}
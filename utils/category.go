@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"basictrade/models"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCategoryNotFound is returned when a category does not exist.
+	ErrCategoryNotFound = errors.New("Category not found")
+	// ErrCategoryNotInCatalog is returned when a category belongs to another admin.
+	ErrCategoryNotInCatalog = errors.New("Category does not belong to your catalog")
+	// ErrCategoryCycle is returned when a category would become its own ancestor.
+	ErrCategoryCycle = errors.New("A category cannot be moved under itself or its descendants")
+	// ErrCategoryTooDeep is returned when a category would be nested deeper than MaxCategoryDepth.
+	ErrCategoryTooDeep = errors.New("Categories cannot be nested this deep")
+)
+
+// CategoryNode is a category with its children, used to render the category tree.
+type CategoryNode struct {
+	models.Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// CategoryPathUUIDs returns the UUIDs in a materialized path, root first.
+func CategoryPathUUIDs(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
+
+// FindCatalogCategory loads a category and checks that it belongs to the admin's catalog.
+func FindCatalogCategory(tx *gorm.DB, adminUUID uuid.UUID, categoryUUID string) (models.Category, error) {
+	var category models.Category
+	if err := tx.Where("uuid = ?", categoryUUID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return category, ErrCategoryNotFound
+		}
+		return category, err
+	}
+
+	if category.AdminUUID != adminUUID {
+		return category, ErrCategoryNotInCatalog
+	}
+
+	return category, nil
+}
+
+// CreateCategory creates a category at the root of the tree, or under parentUUID when it is set.
+func CreateCategory(db *gorm.DB, adminUUID uuid.UUID, name string, parentUUID string) (models.Category, error) {
+	category := models.Category{Name: name, AdminUUID: adminUUID}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		parentPath := "/"
+		if parentUUID != "" {
+			parent, err := FindCatalogCategory(tx, adminUUID, parentUUID)
+			if err != nil {
+				return err
+			}
+			if parent.Depth+1 >= models.MaxCategoryDepth {
+				return ErrCategoryTooDeep
+			}
+
+			parentKey := uuid.MustParse(parent.UUID)
+			category.ParentUUID = &parentKey
+			category.Depth = parent.Depth + 1
+			parentPath = parent.Path
+		}
+
+		// The path ends with the category's own UUID, which only exists once it is created
+		category.Path = parentPath
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+
+		category.Path = parentPath + category.UUID + "/"
+		return tx.Model(&category).Update("path", category.Path).Error
+	})
+
+	return category, err
+}
+
+// MoveCategory moves a category, with all of its descendants, to the root of the tree or
+// under parentUUID when it is set.
+func MoveCategory(db *gorm.DB, category models.Category, parentUUID string) (models.Category, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the tree of the admin while paths are rewritten
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("admin_uuid = ?", category.AdminUUID).Find(&[]models.Category{}).Error; err != nil {
+			return err
+		}
+
+		newParentPath := "/"
+		var newParentUUID *uuid.UUID
+		var newDepth uint
+		if parentUUID != "" {
+			parent, err := FindCatalogCategory(tx, category.AdminUUID, parentUUID)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(parent.Path, category.Path) {
+				return ErrCategoryCycle
+			}
+
+			parentKey := uuid.MustParse(parent.UUID)
+			newParentUUID = &parentKey
+			newParentPath = parent.Path
+			newDepth = parent.Depth + 1
+		}
+
+		// The deepest descendant must still fit once moved
+		var maxDepth uint
+		if err := tx.Model(&models.Category{}).Where("path LIKE ?", category.Path+"%").Select("COALESCE(MAX(depth), 0)").Scan(&maxDepth).Error; err != nil {
+			return err
+		}
+		if maxDepth-category.Depth+newDepth >= models.MaxCategoryDepth {
+			return ErrCategoryTooDeep
+		}
+
+		oldPath := category.Path
+		newPath := newParentPath + category.UUID + "/"
+
+		// Rewrite the path prefix and depth of the category and its descendants
+		if err := tx.Model(&models.Category{}).Where("path LIKE ?", oldPath+"%").Updates(map[string]interface{}{
+			"path":  gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", newPath, len(oldPath)+1),
+			"depth": gorm.Expr("depth + ? - ?", newDepth, category.Depth),
+		}).Error; err != nil {
+			return err
+		}
+
+		category.ParentUUID = newParentUUID
+		category.Path = newPath
+		category.Depth = newDepth
+		return tx.Model(&category).Update("parent_uuid", newParentUUID).Error
+	})
+
+	return category, err
+}
+
+// CategoryDescendants returns a query selecting the UUIDs of a category and all of its descendants.
+func CategoryDescendants(db *gorm.DB, category models.Category) *gorm.DB {
+	return db.Model(&models.Category{}).Select("uuid").Where("path LIKE ?", category.Path+"%")
+}
+
+// CategoryProductUUIDs returns the UUIDs of the products assigned to a category or to one of
+// its descendants.
+func CategoryProductUUIDs(db *gorm.DB, category models.Category) ([]string, error) {
+	var productUUIDs []string
+	err := db.Table("product_categories").Distinct("product_uuid").
+		Where("category_uuid IN (?)", CategoryDescendants(db, category)).
+		Pluck("product_uuid", &productUUIDs).Error
+	return productUUIDs, err
+}
+
+// ProductCategoryUUIDs returns, for each product, the UUIDs of its categories and of all
+// their ancestors.
+func ProductCategoryUUIDs(db *gorm.DB, productUUIDs []string) (map[string][]string, error) {
+	var rows []struct {
+		ProductUUID string
+		Path        string
+	}
+	if len(productUUIDs) > 0 {
+		if err := db.Table("product_categories").
+			Select("product_categories.product_uuid, categories.path").
+			Joins("JOIN categories ON categories.uuid = product_categories.category_uuid").
+			Where("product_categories.product_uuid IN ?", productUUIDs).
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]map[string]bool)
+	categories := make(map[string][]string)
+	for _, row := range rows {
+		if seen[row.ProductUUID] == nil {
+			seen[row.ProductUUID] = make(map[string]bool)
+		}
+		for _, categoryUUID := range CategoryPathUUIDs(row.Path) {
+			if !seen[row.ProductUUID][categoryUUID] {
+				seen[row.ProductUUID][categoryUUID] = true
+				categories[row.ProductUUID] = append(categories[row.ProductUUID], categoryUUID)
+			}
+		}
+	}
+
+	return categories, nil
+}
+
+// BuildCategoryTree nests the categories under their parents, sorted by name.
+func BuildCategoryTree(categories []models.Category) []*CategoryNode {
+	nodes := make(map[string]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.UUID] = &CategoryNode{Category: category, Children: []*CategoryNode{}}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.UUID]
+		if category.ParentUUID != nil {
+			if parent, ok := nodes[category.ParentUUID.String()]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var sortNodes func([]*CategoryNode)
+	sortNodes = func(list []*CategoryNode) {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		for _, node := range list {
+			sortNodes(node.Children)
+		}
+	}
+	sortNodes(roots)
+
+	return roots
+}
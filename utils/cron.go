@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCronSpec is returned when a cron schedule cannot be parsed.
+var ErrInvalidCronSpec = errors.New("Invalid cron schedule")
+
+// cronDescriptors are the shorthands accepted in place of the five fields of a schedule.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// CronSchedule is a parsed cron schedule: minute, hour, day of month, month and day of week,
+// each field a set of the values it matches.
+type CronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	// A day matches either day field when both are restricted, and both otherwise
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// ParseCron parses a standard five-field cron schedule, such as "*/15 * * * *", whose fields
+// accept *, values, ranges, steps and lists, or one of @hourly, @daily, @weekly, @monthly
+// and @yearly.
+func ParseCron(spec string) (*CronSchedule, error) {
+	if expanded, ok := cronDescriptors[strings.TrimSpace(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q must have 5 fields", ErrInvalidCronSpec, spec)
+	}
+
+	schedule := &CronSchedule{
+		anyDayOfMonth: strings.HasPrefix(fields[2], "*"),
+		anyDayOfWeek:  strings.HasPrefix(fields[4], "*"),
+	}
+
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// Sunday is both 0 and 7
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseCronField parses a comma separated list of *, values and ranges, each with an
+// optional step, into the set of values between min and max it matches.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+			parsed, err := strconv.Atoi(part[i+1:])
+			if err != nil || parsed < 1 {
+				return 0, fmt.Errorf("%w: invalid step in %q", ErrInvalidCronSpec, part)
+			}
+			step = parsed
+		}
+
+		low, high := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			low, err1 = strconv.Atoi(bounds[0])
+			high, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("%w: invalid range %q", ErrInvalidCronSpec, rangePart)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("%w: invalid value %q", ErrInvalidCronSpec, rangePart)
+			}
+			low, high = value, value
+			// A single value with a step runs from the value to the end of the field
+			if step > 1 {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%w: %q is outside %d-%d", ErrInvalidCronSpec, part, min, max)
+		}
+		for value := low; value <= high; value += step {
+			set |= 1 << uint(value)
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t matching the schedule, or the zero time when none
+// does within five years.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches the day fields of the schedule.
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// cronJob is a job enqueued on a cron schedule.
+type cronJob struct {
+	name     string
+	schedule *CronSchedule
+	jobType  string
+	payload  interface{}
+	next     time.Time
+}
+
+var (
+	cronJobsMutex sync.Mutex
+	cronJobs      []*cronJob
+)
+
+// ScheduleJob enqueues a job of the given type on every run of a cron schedule. The name
+// identifies the schedule, so that each run is enqueued once however many instances share
+// the queue.
+func ScheduleJob(name string, spec string, jobType string, payload interface{}) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	cronJobsMutex.Lock()
+	defer cronJobsMutex.Unlock()
+
+	cronJobs = append(cronJobs, &cronJob{
+		name:     name,
+		schedule: schedule,
+		jobType:  jobType,
+		payload:  payload,
+		next:     schedule.Next(time.Now()),
+	})
+	return nil
+}
+
+// mustScheduleJob schedules a built-in cron job, whose schedule is known to be valid.
+func mustScheduleJob(name string, spec string, jobType string, payload interface{}) {
+	if err := ScheduleJob(name, spec, jobType, payload); err != nil {
+		log.Fatal("error scheduling job ", name, ": ", err)
+	}
+}
+
+// EnqueueDueCronJobs enqueues the cron jobs whose run is due. Runs missed while the process
+// was not running are skipped.
+func EnqueueDueCronJobs(db *gorm.DB, now time.Time) error {
+	cronJobsMutex.Lock()
+	defer cronJobsMutex.Unlock()
+
+	for _, scheduled := range cronJobs {
+		if scheduled.next.IsZero() || scheduled.next.After(now) {
+			continue
+		}
+
+		job, err := newJob(scheduled.jobType, nil, scheduled.payload, scheduled.next)
+		if err != nil {
+			return err
+		}
+		uniqueKey := scheduled.name + ":" + scheduled.next.UTC().Format(time.RFC3339)
+		job.UniqueKey = &uniqueKey
+
+		// Another instance may have enqueued the run already
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&job).Error; err != nil {
+			return err
+		}
+
+		scheduled.next = scheduled.schedule.Next(now)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCurrency is returned when a currency code is not a 3-letter ISO 4217 code.
+	ErrInvalidCurrency = errors.New("Currency must be a 3-letter ISO 4217 code")
+	// ErrUnknownCurrency is returned when no exchange rate is stored for a currency.
+	ErrUnknownCurrency = errors.New("No exchange rate found for currency")
+)
+
+// currencyMinorUnits lists the currencies that do not use 2 decimal places.
+var currencyMinorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IDR": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyConversion describes the exchange rate applied to a response.
+type CurrencyConversion struct {
+	Currency      string    `json:"currency"`
+	BaseCurrency  string    `json:"base_currency"`
+	Rate          float64   `json:"rate"`
+	RateUpdatedAt time.Time `json:"rate_updated_at"`
+}
+
+// NormalizeCurrency upper-cases a currency code and checks its format.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return code, nil
+}
+
+// CurrencyMinorUnits returns the number of decimal places used by a currency.
+func CurrencyMinorUnits(code string) int {
+	if units, ok := currencyMinorUnits[code]; ok {
+		return units
+	}
+	return 2
+}
+
+// RoundPrice rounds an amount half away from zero to the minor units of the currency.
+func RoundPrice(amount float64, currency string) float64 {
+	scale := math.Pow10(CurrencyMinorUnits(currency))
+	// Drop float noise such as 1.005 being stored as 1.00499999 before rounding
+	scaled := math.Round(amount*scale*1e6) / 1e6
+	return math.Round(scaled) / scale
+}
+
+// Convert converts an amount in the base currency to the conversion currency.
+func (conversion CurrencyConversion) Convert(amount float64) float64 {
+	return RoundPrice(amount*conversion.Rate, conversion.Currency)
+}
+
+// GetCurrencyConversion looks up the stored exchange rate for a currency.
+func GetCurrencyConversion(db *gorm.DB, code string) (*CurrencyConversion, error) {
+	currency, err := NormalizeCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prices are stored in the base currency, so no lookup is needed
+	baseCurrency := helpers.EnvBaseCurrency()
+	if currency == baseCurrency {
+		return &CurrencyConversion{Currency: currency, BaseCurrency: baseCurrency, Rate: 1}, nil
+	}
+
+	var rate models.ExchangeRate
+	if err := db.Where("currency = ?", currency).First(&rate).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnknownCurrency
+		}
+		return nil, err
+	}
+
+	return &CurrencyConversion{
+		Currency:      currency,
+		BaseCurrency:  baseCurrency,
+		Rate:          rate.Rate,
+		RateUpdatedAt: rate.UpdatedAt,
+	}, nil
+}
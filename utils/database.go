@@ -45,9 +45,41 @@ func StartDB() {
 	// AutoMigrate models
 	db.Debug().AutoMigrate(
 		&model.Admin{}, 
+		&model.Category{},
+		&model.Tag{},
 		&model.Product{}, 
 		&model.Variant{},
+		&model.ProductOption{},
+		&model.VariantAttribute{},
+		&model.BundleComponent{},
+		&model.ExchangeRate{},
+		&model.Promotion{},
+		&model.Order{},
+		&model.OrderLine{},
+		&model.Cart{},
+		&model.CartLine{},
+		&model.Return{},
+		&model.Supplier{},
+		&model.PurchaseOrder{},
+		&model.PurchaseOrderLine{},
+		&model.Import{},
+		&model.ImportError{},
+		&model.OutboxEvent{},
+		&model.OutboxSequence{},
+		&model.WebhookSubscription{},
+		&model.WebhookDelivery{},
+		&model.WebhookAttempt{},
+		&model.Job{},
+		&model.JobBlob{},
+		&model.IdempotencyKey{},
 	)
+	duplicates, err := BackfillVariantKeys(db)
+	if err != nil {
+		log.Println("Failed to backfill the variant keys:", err)
+	}
+	for _, duplicate := range duplicates {
+		log.Println("Change the SKU or barcode of a variant by hand:", duplicate)
+	}
 
 	db.Callback().Create().Before("gorm:before_create").Register("before_create", BeforeCreateUUID)
 
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"basictrade/models"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// eventStreamBuffer is how many events a stream can fall behind before it is closed.
+const eventStreamBuffer = 256
+
+// EventStream receives the domain events of an admin's catalog as they are dispatched, by
+// any instance, in dispatch order. Events is closed when the stream is closed, or when its
+// reader fell too far behind; the reader then resumes from the outbox with EventsSince.
+type EventStream struct {
+	AdminUUID uuid.UUID
+	Events    chan models.OutboxEvent
+}
+
+var (
+	eventStreamsMutex sync.Mutex
+	eventStreams      = map[*EventStream]bool{}
+)
+
+// OpenEventStream starts receiving the events of the admin's catalog.
+func OpenEventStream(adminUUID uuid.UUID) *EventStream {
+	stream := &EventStream{AdminUUID: adminUUID, Events: make(chan models.OutboxEvent, eventStreamBuffer)}
+
+	eventStreamsMutex.Lock()
+	defer eventStreamsMutex.Unlock()
+
+	eventStreams[stream] = true
+	return stream
+}
+
+// CloseEventStream stops a stream receiving events.
+func CloseEventStream(stream *EventStream) {
+	eventStreamsMutex.Lock()
+	defer eventStreamsMutex.Unlock()
+
+	if eventStreams[stream] {
+		delete(eventStreams, stream)
+		close(stream.Events)
+	}
+}
+
+// CloseEventStreams closes every open stream, so their readers return when the server shuts down.
+func CloseEventStreams() {
+	eventStreamsMutex.Lock()
+	defer eventStreamsMutex.Unlock()
+
+	for stream := range eventStreams {
+		delete(eventStreams, stream)
+		close(stream.Events)
+	}
+}
+
+// broadcastEvent hands an event to the open streams of its admin without waiting on them,
+// closing the streams that are full.
+func broadcastEvent(event models.OutboxEvent) {
+	eventStreamsMutex.Lock()
+	defer eventStreamsMutex.Unlock()
+
+	for stream := range eventStreams {
+		if stream.AdminUUID != event.AdminUUID {
+			continue
+		}
+		select {
+		case stream.Events <- event:
+		default:
+			delete(eventStreams, stream)
+			close(stream.Events)
+		}
+	}
+}
+
+// hasEventStreams reports whether any stream is open on this instance.
+func hasEventStreams() bool {
+	eventStreamsMutex.Lock()
+	defer eventStreamsMutex.Unlock()
+
+	return len(eventStreams) > 0
+}
+
+// EventsSince returns up to limit of the admin's dispatched events that follow the one with
+// dispatch sequence lastSeq, in dispatch order.
+func EventsSince(db *gorm.DB, adminUUID uuid.UUID, lastSeq uint64, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := db.Where("admin_uuid = ? AND dispatch_seq > ?", adminUUID, lastSeq).
+		Order("dispatch_seq").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// StreamDispatchedEventsEvery reads the events dispatched since the last tick, by this
+// instance or another one, and broadcasts them to the open streams, until stop is closed.
+// Sequences become visible in order, so reading past the last one seen misses none.
+func StreamDispatchedEventsEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastSeq uint64
+	seen := false
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		if lastSeq, err = streamDispatchedEvents(GetDB(), lastSeq, seen); err != nil {
+			log.Println("Failed to read dispatched events:", err)
+			continue
+		}
+		seen = true
+	}
+}
+
+// streamDispatchedEvents broadcasts the events dispatched after lastSeq and returns the last
+// sequence read. Without open streams, or on the first read, it only skips to the latest one.
+func streamDispatchedEvents(db *gorm.DB, lastSeq uint64, seen bool) (uint64, error) {
+	if !seen || !hasEventStreams() {
+		err := db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(dispatch_seq), 0)").Scan(&lastSeq).Error
+		return lastSeq, err
+	}
+
+	for {
+		var events []models.OutboxEvent
+		if err := db.Where("dispatch_seq > ?", lastSeq).Order("dispatch_seq").Limit(outboxBatchSize).Find(&events).Error; err != nil {
+			return lastSeq, err
+		}
+		for _, event := range events {
+			broadcastEvent(event)
+			lastSeq = *event.DispatchSeq
+		}
+		if len(events) < outboxBatchSize {
+			return lastSeq, nil
+		}
+	}
+}
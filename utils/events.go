@@ -0,0 +1,336 @@
+package utils
+
+import (
+	"basictrade/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// outboxBatchSize is how many events are published on each tick of the dispatcher.
+	outboxBatchSize = 100
+	// outboxRetryDelay is the wait before publishing a failed event again, doubled after every failure.
+	outboxRetryDelay = time.Second
+	// outboxMaxRetryDelay caps the wait before publishing a failed event again.
+	outboxMaxRetryDelay = 10 * time.Minute
+	// OutboxRetention is how long published events are kept, for clients resuming a stream.
+	OutboxRetention = 7 * 24 * time.Hour
+)
+
+// EventHandler handles a domain event published from the outbox. Events are published at
+// least once, so a handler may see an event again and must tolerate it.
+type EventHandler func(db *gorm.DB, event models.OutboxEvent) error
+
+// eventSubscriber is a named handler of every domain event.
+type eventSubscriber struct {
+	name    string
+	handler EventHandler
+}
+
+var (
+	subscribersMutex sync.RWMutex
+	eventSubscribers []eventSubscriber
+)
+
+// StockChange is the data of a variant.stock_changed event.
+type StockChange struct {
+	VariantUUID      string `json:"variant_uuid"`
+	ProductUUID      string `json:"product_uuid"`
+	SKU              string `json:"sku"`
+	PreviousQuantity uint   `json:"previous_quantity"`
+	Quantity         uint   `json:"quantity"`
+}
+
+// Subscribe registers a handler for every domain event published from the outbox.
+func Subscribe(name string, handler EventHandler) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+
+	eventSubscribers = append(eventSubscribers, eventSubscriber{name: name, handler: handler})
+}
+
+// StartEventBus subscribes the search index and the webhooks to the domain events, publishes
+// the outbox alongside the runner's jobs, and feeds the event streams of this instance with
+// the events dispatched by any instance, until the runner shuts down.
+func StartEventBus(runner *JobRunner) {
+	Subscribe("search", indexEvent)
+	Subscribe("webhooks", QueueWebhookEvent)
+
+	runner.Go(func(stop <-chan struct{}) { DispatchOutboxEvery(time.Second, stop) })
+	runner.Go(func(stop <-chan struct{}) { StreamDispatchedEventsEvery(500*time.Millisecond, stop) })
+}
+
+// RecordEvent writes a domain event about a product, or one of its variants, to the outbox.
+// It must run in the transaction of the change, so the event is only published if the
+// change is committed.
+func RecordEvent(tx *gorm.DB, adminUUID uuid.UUID, eventType string, productUUID string, variantUUID string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxEvent{
+		Type:        eventType,
+		AdminUUID:   adminUUID,
+		ProductUUID: productUUID,
+		VariantUUID: variantUUID,
+		Payload:     string(payload),
+	}).Error
+}
+
+// RecordProductEvent writes a domain event about a product to the outbox.
+func RecordProductEvent(tx *gorm.DB, eventType string, product models.Product) error {
+	return RecordEvent(tx, product.AdminUUID, eventType, product.UUID, "", product)
+}
+
+// RecordProductsUpdated writes a product.updated event to the outbox for each of the products,
+// after a change that affects them all, such as a category of theirs being renamed.
+func RecordProductsUpdated(tx *gorm.DB, productUUIDs []string) error {
+	if len(productUUIDs) == 0 {
+		return nil
+	}
+
+	var products []models.Product
+	if err := tx.Where("uuid IN ?", productUUIDs).Order("id").Find(&products).Error; err != nil {
+		return err
+	}
+	for _, product := range products {
+		if err := RecordProductEvent(tx, models.EventProductUpdated, product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordVariantEvent writes a domain event about a variant of the admin's catalog to the outbox.
+func RecordVariantEvent(tx *gorm.DB, adminUUID uuid.UUID, eventType string, variant models.Variant) error {
+	return RecordEvent(tx, adminUUID, eventType, variant.ProductUUID.String(), variant.UUID, variant)
+}
+
+// RecordStockChange writes a variant.stock_changed event to the outbox when the quantity of
+// a variant changed, for the admin owning the variant's product.
+func RecordStockChange(tx *gorm.DB, variant models.Variant, previousQuantity uint) error {
+	if variant.Quantity == previousQuantity {
+		return nil
+	}
+
+	var product models.Product
+	if err := tx.Select("uuid", "admin_uuid").Where("uuid = ?", variant.ProductUUID).First(&product).Error; err != nil {
+		return err
+	}
+
+	return RecordEvent(tx, product.AdminUUID, models.EventVariantStockChanged, product.UUID, variant.UUID, StockChange{
+		VariantUUID:      variant.UUID,
+		ProductUUID:      product.UUID,
+		SKU:              variant.SKU,
+		PreviousQuantity: previousQuantity,
+		Quantity:         variant.Quantity,
+	})
+}
+
+// DispatchOutbox publishes the due events of the outbox to the subscribers, in order, and
+// returns how many were fully published. An event is given a dispatch sequence the first
+// time it is dispatched, whether or not every subscriber handled it, and the subscribers that
+// failed to handle it get it again after an exponential backoff, without holding up the
+// others. The events of a product are published in the order they were recorded: while one
+// is not fully published, the later ones wait, so a retried event never overwrites a newer
+// one. The events are locked while they are published, so several instances can dispatch
+// the same outbox.
+func DispatchOutbox(db *gorm.DB, now time.Time) (int, error) {
+	subscribersMutex.RLock()
+	subscribers := eventSubscribers
+	subscribersMutex.RUnlock()
+
+	dispatched := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Leave out the events waiting behind an earlier event of their product that failed
+		var events []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", now).
+			Where(`NOT EXISTS (SELECT 1 FROM outbox_events AS earlier WHERE earlier.product_uuid = outbox_events.product_uuid
+				AND earlier.dispatched_at IS NULL AND earlier.id < outbox_events.id AND earlier.next_attempt_at > ?)`, now).
+			Order("id").Limit(outboxBatchSize).Find(&events).Error; err != nil {
+			return err
+		}
+
+		earliest, err := earliestPendingEvents(tx, events)
+		if err != nil {
+			return err
+		}
+
+		var firstDispatched []uint
+		seen := map[string]bool{}
+		blocked := map[string]bool{}
+		for _, event := range events {
+			// Wait for an earlier event of the product, being published by another instance or
+			// failed in this batch
+			if blocked[event.ProductUUID] || !seen[event.ProductUUID] && earliest[event.ProductUUID] < event.ID {
+				blocked[event.ProductUUID] = true
+				continue
+			}
+			seen[event.ProductUUID] = true
+
+			// The first dispatch goes to every subscriber, retries only to those that failed
+			pending := subscribers
+			if event.DispatchSeq != nil {
+				pending = pendingSubscribers(subscribers, event.PendingSubscribers)
+			} else {
+				firstDispatched = append(firstDispatched, event.ID)
+			}
+
+			failed, err := publishEvent(db, pending, event)
+			updates := map[string]interface{}{"attempts": event.Attempts + 1, "last_error": "", "pending_subscribers": strings.Join(failed, ",")}
+			if err != nil {
+				log.Println("Failed to publish event", event.ID, event.Type+":", err)
+				updates["last_error"] = err.Error()
+				updates["next_attempt_at"] = now.Add(outboxBackoff(event.Attempts + 1))
+				blocked[event.ProductUUID] = true
+			} else {
+				updates["dispatched_at"] = now
+				dispatched++
+			}
+
+			if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		return assignDispatchSequences(tx, firstDispatched)
+	})
+
+	return dispatched, err
+}
+
+// earliestPendingEvents returns, for each product of the events, the ID of its earliest event
+// that is not fully published.
+func earliestPendingEvents(tx *gorm.DB, events []models.OutboxEvent) (map[string]uint, error) {
+	earliest := map[string]uint{}
+	if len(events) == 0 {
+		return earliest, nil
+	}
+
+	var productUUIDs []string
+	for _, event := range events {
+		productUUIDs = append(productUUIDs, event.ProductUUID)
+	}
+
+	var rows []struct {
+		ProductUUID string
+		ID          uint
+	}
+	if err := tx.Model(&models.OutboxEvent{}).Select("product_uuid, MIN(id) AS id").
+		Where("dispatched_at IS NULL AND product_uuid IN ?", productUUIDs).
+		Group("product_uuid").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		earliest[row.ProductUUID] = row.ID
+	}
+	return earliest, nil
+}
+
+// assignDispatchSequences gives the events their dispatch sequence, in order. The sequence
+// counter stays locked until the transaction commits, so dispatchers on other instances
+// commit their sequences after these, and a reader never sees a sequence before a lower one.
+func assignDispatchSequences(tx *gorm.DB, eventIDs []uint) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.OutboxSequence{ID: 1}).Error; err != nil {
+		return err
+	}
+	var sequence models.OutboxSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sequence, 1).Error; err != nil {
+		return err
+	}
+
+	for _, eventID := range eventIDs {
+		sequence.Value++
+		if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", eventID).Update("dispatch_seq", sequence.Value).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&sequence).Update("value", sequence.Value).Error
+}
+
+// pendingSubscribers returns the subscribers named in a comma separated list.
+func pendingSubscribers(subscribers []eventSubscriber, names string) []eventSubscriber {
+	var pending []eventSubscriber
+	for _, subscriber := range subscribers {
+		if containsString(strings.Split(names, ","), subscriber.name) {
+			pending = append(pending, subscriber)
+		}
+	}
+	return pending
+}
+
+// publishEvent hands an event to every subscriber, even when some of them fail, and returns
+// the names of those that failed.
+func publishEvent(db *gorm.DB, subscribers []eventSubscriber, event models.OutboxEvent) ([]string, error) {
+	var failed []string
+	var errs []error
+	for _, subscriber := range subscribers {
+		if err := subscriber.handler(db, event); err != nil {
+			failed = append(failed, subscriber.name)
+			errs = append(errs, fmt.Errorf("%s: %w", subscriber.name, err))
+		}
+	}
+	return failed, errors.Join(errs...)
+}
+
+// outboxBackoff is the wait before publishing an event that failed attempts times again.
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxRetryDelay
+	for i := 1; i < attempts && delay < outboxMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxRetryDelay {
+		delay = outboxMaxRetryDelay
+	}
+	return delay
+}
+
+// DispatchOutboxEvery runs DispatchOutbox on every tick of interval until stop is closed,
+// and deletes the events published longer ago than OutboxRetention once an hour.
+func DispatchOutboxEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastPurge := time.Now()
+	for {
+		var now time.Time
+		select {
+		case <-stop:
+			return
+		case now = <-ticker.C:
+		}
+
+		for {
+			dispatched, err := DispatchOutbox(GetDB(), now)
+			if err != nil {
+				log.Println("Failed to dispatch the outbox:", err)
+			}
+			// Keep going while full batches are published
+			if err != nil || dispatched < outboxBatchSize {
+				break
+			}
+		}
+
+		if now.Sub(lastPurge) >= time.Hour {
+			lastPurge = now
+			if err := GetDB().Where("dispatched_at < ?", now.Add(-OutboxRetention)).Delete(&models.OutboxEvent{}).Error; err != nil {
+				log.Println("Failed to purge the outbox:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Export file formats.
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatXLSX   = "xlsx"
+)
+
+var (
+	// ErrInvalidExportFormat is returned when an export is requested in an unknown format.
+	ErrInvalidExportFormat = errors.New("Format must be csv, ndjson or xlsx")
+	// ErrInvalidExportColumn is returned when an export is requested with an unknown column.
+	ErrInvalidExportColumn = errors.New("Invalid export column")
+)
+
+// ExportWriter writes the rows of an export one at a time.
+type ExportWriter interface {
+	// WriteRow writes the values of a row, in the order of the columns.
+	WriteRow(values []interface{}) error
+	// Flush sends the rows written so far, where the format allows it.
+	Flush() error
+	// Close writes what is left of the export.
+	Close() error
+}
+
+// ExportContentType returns the content type and file extension of an export format.
+func ExportContentType(format string) (string, string, error) {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv", "csv", nil
+	case ExportFormatNDJSON:
+		return "application/x-ndjson", "ndjson", nil
+	case ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx", nil
+	default:
+		return "", "", ErrInvalidExportFormat
+	}
+}
+
+// SelectExportColumns parses a comma-separated list of columns, checking each one is
+// available. An empty list selects every available column.
+func SelectExportColumns(columnsParam string, available []string) ([]string, error) {
+	var columns []string
+	for _, column := range strings.Split(columnsParam, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		if !containsString(available, column) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidExportColumn, column)
+		}
+		columns = append(columns, column)
+	}
+
+	if len(columns) == 0 {
+		return available, nil
+	}
+	return columns, nil
+}
+
+// NewExportWriter starts an export with the given columns written to w. CSV and XLSX exports
+// start with a header row, and NDJSON exports write an object per row keyed by column.
+func NewExportWriter(format string, w io.Writer, columns []string) (ExportWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		writer := &csvExportWriter{writer: csv.NewWriter(w)}
+		header := make([]interface{}, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		return writer, writer.WriteRow(header)
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter{writer: w, columns: columns}, nil
+	case ExportFormatXLSX:
+		file := excelize.NewFile()
+		stream, err := file.NewStreamWriter(file.GetSheetName(0))
+		if err != nil {
+			return nil, err
+		}
+		writer := &xlsxExportWriter{writer: w, file: file, stream: stream}
+		header := make([]interface{}, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		return writer, writer.WriteRow(header)
+	default:
+		return nil, ErrInvalidExportFormat
+	}
+}
+
+// csvExportWriter writes an export as CSV.
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatExportValue(value)
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvExportWriter) Close() error {
+	return w.Flush()
+}
+
+// ndjsonExportWriter writes an export as one JSON object per line, its keys in column order.
+type ndjsonExportWriter struct {
+	writer  io.Writer
+	columns []string
+}
+
+func (w *ndjsonExportWriter) WriteRow(values []interface{}) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, err := json.Marshal(w.columns[i])
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(encoded)
+	}
+	line.WriteString("}\n")
+
+	_, err := w.writer.Write(line.Bytes())
+	return err
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// xlsxExportWriter writes an export as the first sheet of a workbook. The stream writer keeps
+// rows in a temporary file, so the workbook is only written out once the export is closed.
+type xlsxExportWriter struct {
+	writer io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+}
+
+func (w *xlsxExportWriter) WriteRow(values []interface{}) error {
+	w.rows++
+	cell, err := excelize.CoordinatesToCellName(1, w.rows)
+	if err != nil {
+		return err
+	}
+
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		switch value.(type) {
+		case nil, string, int, int64, uint, float64, bool:
+			row[i] = value
+		default:
+			row[i] = formatExportValue(value)
+		}
+	}
+	return w.stream.SetRow(cell, row)
+}
+
+func (w *xlsxExportWriter) Flush() error {
+	return nil
+}
+
+func (w *xlsxExportWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.writer)
+}
+
+// formatExportValue formats a value as text, times in RFC 3339 and nil as an empty string.
+func formatExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"basictrade/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyHeader is the header clients send to make a POST request safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyLockTimeout is how long a key whose response is not stored yet stays reserved.
+// A reservation older than this was abandoned by a crashed or stuck process, and the key can
+// be used again.
+const IdempotencyLockTimeout = 5 * time.Minute
+
+// idempotencyCompleteAttempts is how many times storing the response of a key is tried.
+const idempotencyCompleteAttempts = 3
+
+// idempotencyAnonymousScope is the scope of the requests sent without a valid token.
+const idempotencyAnonymousScope = "anonymous"
+
+// IdempotencyScope identifies the admin a request is signed in as, from the adminUUID claim of
+// its verified token, so that the same Idempotency-Key used by two admins does not collide
+// while an admin keeps its keys across token refreshes. Requests without a valid token, given
+// an empty adminUUID, share one anonymous scope.
+func IdempotencyScope(adminUUID string) string {
+	if adminUUID == "" {
+		return idempotencyAnonymousScope
+	}
+	return "admin:" + adminUUID
+}
+
+// RequestFingerprint hashes the method, path, query and body of a request, so that a retry
+// can be told apart from a different request sent with the same Idempotency-Key. JSON bodies
+// are hashed regardless of key order and whitespace, form bodies regardless of field order,
+// and multipart bodies by their parts, whatever boundary the client picked.
+func RequestFingerprint(request *http.Request, body []byte) string {
+	digest := sha256.New()
+	fmt.Fprintf(digest, "%s %s?%s\n", request.Method, request.URL.Path, request.URL.Query().Encode())
+
+	mediaType, params, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/form-data":
+		if err := hashMultipart(digest, body, params["boundary"]); err != nil {
+			digest.Write(body)
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			digest.Write(body)
+		} else {
+			digest.Write([]byte(values.Encode()))
+		}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		digest.Write(canonicalJSON(body))
+	default:
+		digest.Write(body)
+	}
+
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// hashMultipart writes the name, file name, content type and content digest of each part of
+// a multipart body, in order.
+func hashMultipart(digest hash.Hash, body []byte, boundary string) error {
+	if boundary == "" {
+		return errors.New("missing multipart boundary")
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		content := sha256.New()
+		if _, err := io.Copy(content, part); err != nil {
+			return err
+		}
+		fmt.Fprintf(digest, "%q %q %q %x\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), content.Sum(nil))
+	}
+}
+
+// canonicalJSON re-encodes a JSON body with sorted keys and no whitespace, or returns it as
+// is when it is not valid JSON.
+func canonicalJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+// ReserveIdempotencyKey records the first use of a key within its scope, and reports true.
+// When the key was already used and has not expired, it returns that use instead.
+func ReserveIdempotencyKey(db *gorm.DB, key models.IdempotencyKey, now time.Time) (models.IdempotencyKey, bool, error) {
+	lookup := models.IdempotencyKey{Scope: key.Scope, Key: key.Key}
+
+	// Forget an expired use of the key, or a reservation that was abandoned
+	if err := db.Where(&lookup).
+		Where("expires_at <= ? OR (completed = ? AND updated_at <= ?)", now, false, now.Add(-IdempotencyLockTimeout)).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return key, false, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&key)
+	if result.Error != nil {
+		return key, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return key, true, nil
+	}
+
+	var existing models.IdempotencyKey
+	err := db.Where(&lookup).First(&existing).Error
+	return existing, false, err
+}
+
+// CompleteIdempotencyKey stores the response to the request of a reserved key, to be replayed.
+// The request already had its effects, so a failed write is tried again before giving up.
+func CompleteIdempotencyKey(db *gorm.DB, key *models.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	key.Completed = true
+	key.StatusCode = statusCode
+	key.ContentType = contentType
+	key.ResponseBody = body
+
+	var err error
+	for attempt := 1; attempt <= idempotencyCompleteAttempts; attempt++ {
+		if err = db.Model(key).Select("completed", "status_code", "content_type", "response_body").Updates(key).Error; err == nil {
+			return nil
+		}
+		if attempt < idempotencyCompleteAttempts {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+	}
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved key whose request did not complete, so that it can
+// be retried.
+func ReleaseIdempotencyKey(db *gorm.DB, key models.IdempotencyKey) error {
+	return db.Delete(&models.IdempotencyKey{}, key.ID).Error
+}
+
+// purgeIdempotencyKeysJob deletes the expired idempotency keys as a background job.
+func purgeIdempotencyKeysJob(ctx context.Context, db *gorm.DB, job models.Job) error {
+	result := db.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired idempotency keys\n", result.RowsAffected)
+	}
+	return nil
+}
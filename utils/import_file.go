@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Import file formats.
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatXLSX = "xlsx"
+)
+
+// MaxImportRows is the largest number of rows an import file may hold.
+const MaxImportRows = 10000
+
+// ImportAttributePrefix starts the import fields holding a variant attribute, e.g. attribute:Color.
+const ImportAttributePrefix = "attribute:"
+
+// ImportFields are the fields the columns of an import file can be mapped to. Each row holds
+// one variant and the product it belongs to.
+var ImportFields = []string{
+	"product_uuid", "product_name", "description", "status", "type", "image_url", "published_at",
+	"variant_uuid", "variant_name", "quantity", "price", "sku", "barcode",
+}
+
+var (
+	// ErrInvalidImport is returned when an import file cannot be read.
+	ErrInvalidImport = errors.New("Invalid import file")
+	// ErrInvalidMapping is returned when a column mapping names an unknown field or column.
+	ErrInvalidMapping = errors.New("Invalid column mapping")
+)
+
+// ImportRow is a row of an import file by field. Number is the line of the row in the file.
+type ImportRow struct {
+	Number     int
+	Fields     map[string]string
+	Attributes map[string]string
+}
+
+// ImportFormat returns the format of an import file from its name.
+func ImportFormat(fileName string) (string, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return ImportFormatCSV, nil
+	case ".xlsx":
+		return ImportFormatXLSX, nil
+	default:
+		return "", fmt.Errorf("%w: only .csv and .xlsx files are supported", ErrInvalidImport)
+	}
+}
+
+// ParseImportMapping parses a column mapping given as a JSON object of column headers to
+// fields. An empty mapping maps each column whose header is a field name to that field.
+func ParseImportMapping(raw string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if strings.TrimSpace(raw) == "" {
+		return mapping, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMapping, err.Error())
+	}
+	for column, field := range mapping {
+		if !isImportField(field) {
+			return nil, fmt.Errorf("%w: unknown field %s for column %s", ErrInvalidMapping, field, column)
+		}
+	}
+
+	return mapping, nil
+}
+
+// ReadImportRows reads the rows of an import file, its first row holding the column headers,
+// and maps their columns to fields.
+func ReadImportRows(data []byte, format string, mapping map[string]string) ([]ImportRow, error) {
+	records, err := readImportRecords(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+	if len(records)-1 > MaxImportRows {
+		return nil, fmt.Errorf("%w: the file has more than %d rows", ErrInvalidImport, MaxImportRows)
+	}
+
+	// Resolve the field of each column
+	fields := make([]string, len(records[0]))
+	found := make(map[string]bool, len(mapping))
+	for i, header := range records[0] {
+		header = strings.TrimSpace(header)
+		if field, ok := mapping[header]; ok {
+			fields[i] = field
+			found[header] = true
+		} else if len(mapping) == 0 && isImportField(header) {
+			fields[i] = header
+		}
+	}
+	for column := range mapping {
+		if !found[column] {
+			return nil, fmt.Errorf("%w: the file has no column %s", ErrInvalidMapping, column)
+		}
+	}
+
+	rows := make([]ImportRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		row := ImportRow{Number: i + 2, Fields: map[string]string{}, Attributes: map[string]string{}}
+		empty := true
+		for j, value := range record {
+			if j >= len(fields) || fields[j] == "" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if value != "" {
+				empty = false
+			}
+
+			if strings.HasPrefix(fields[j], ImportAttributePrefix) {
+				if value != "" {
+					row.Attributes[strings.TrimPrefix(fields[j], ImportAttributePrefix)] = value
+				}
+				continue
+			}
+			row.Fields[fields[j]] = value
+		}
+
+		// Skip blank lines, common at the end of spreadsheets
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// readImportRecords reads the cells of a CSV file, or of the first sheet of an XLSX file.
+func readImportRecords(data []byte, format string) ([][]string, error) {
+	switch format {
+	case ImportFormatCSV:
+		csvReader := csv.NewReader(bytes.NewReader(data))
+		csvReader.FieldsPerRecord = -1
+		csvReader.TrimLeadingSpace = true
+
+		records, err := csvReader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+		}
+		return records, nil
+	case ImportFormatXLSX:
+		file, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+		}
+		defer file.Close()
+
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("%w: the workbook has no sheets", ErrInvalidImport)
+		}
+		records, err := file.GetRows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+		}
+		return records, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown format %s", ErrInvalidImport, format)
+	}
+}
+
+// isImportField reports whether columns can be mapped to field.
+func isImportField(field string) bool {
+	if strings.HasPrefix(field, ImportAttributePrefix) {
+		return strings.TrimSpace(strings.TrimPrefix(field, ImportAttributePrefix)) != ""
+	}
+	return containsString(ImportFields, field)
+}
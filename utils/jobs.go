@@ -0,0 +1,430 @@
+package utils
+
+import (
+	"basictrade/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// jobPollInterval is how long an idle worker waits before looking for a due job again.
+	jobPollInterval = time.Second
+	// jobHeartbeat is how often a running job renews its lock.
+	jobHeartbeat = 30 * time.Second
+	// jobLockTimeout is how long a running job may go without renewing its lock before it is
+	// considered lost, because its worker stopped, and is queued again.
+	jobLockTimeout = 2 * time.Minute
+	// jobRetryDelay is the wait before retrying a failed job, doubled after every failed attempt.
+	jobRetryDelay = 10 * time.Second
+	// jobMaxRetryDelay caps the wait before retrying a failed job.
+	jobMaxRetryDelay = time.Hour
+	// jobMaintenanceInterval is how often lost jobs are recovered and cron jobs are scheduled.
+	jobMaintenanceInterval = 10 * time.Second
+	// JobRetention is how long finished jobs are kept.
+	JobRetention = 7 * 24 * time.Hour
+)
+
+// ErrUnknownJobType is returned when a job is enqueued for a type without a handler.
+var ErrUnknownJobType = errors.New("Unknown job type")
+
+// JobHandler runs a background job. The context is cancelled when the job must stop because
+// the runner is shutting down; the job is then queued again without counting the attempt. A
+// job that fails is retried, so a handler may run the same job again and must tolerate it.
+type JobHandler func(ctx context.Context, db *gorm.DB, job models.Job) error
+
+// jobType is a registered kind of job.
+type jobType struct {
+	handler     JobHandler
+	maxAttempts int
+}
+
+var (
+	jobTypesMutex sync.RWMutex
+	jobTypes      = map[string]jobType{}
+)
+
+// RegisterJob registers the handler of a job type, and how many times a job of the type is
+// tried before it is marked failed.
+func RegisterJob(name string, maxAttempts int, handler JobHandler) {
+	jobTypesMutex.Lock()
+	defer jobTypesMutex.Unlock()
+
+	jobTypes[name] = jobType{handler: handler, maxAttempts: maxAttempts}
+}
+
+// lookupJobType returns the registered job type with the given name.
+func lookupJobType(name string) (jobType, bool) {
+	jobTypesMutex.RLock()
+	defer jobTypesMutex.RUnlock()
+
+	registered, ok := jobTypes[name]
+	return registered, ok
+}
+
+// StartJobs registers the background jobs of the catalog, schedules the periodic ones and
+// starts the workers. The jobs of other packages must be registered before.
+func StartJobs(workers int) *JobRunner {
+	RegisterJob(models.JobUploadProductImage, 5, uploadProductImageJob)
+	RegisterJob(models.JobReindexSearch, 3, reindexSearchJob)
+	RegisterJob(models.JobPurgeExpiredCarts, 1, purgeExpiredCartsJob)
+	RegisterJob(models.JobPublishDueProducts, 1, publishDueProductsJob)
+	RegisterJob(models.JobPurgeIdempotency, 1, purgeIdempotencyKeysJob)
+
+	mustScheduleJob("purge-expired-carts", "0 * * * *", models.JobPurgeExpiredCarts, nil)
+	mustScheduleJob("publish-due-products", "* * * * *", models.JobPublishDueProducts, nil)
+	mustScheduleJob("purge-idempotency-keys", "30 * * * *", models.JobPurgeIdempotency, nil)
+
+	return StartJobRunner(GetDB(), workers)
+}
+
+// EnqueueJob queues a job to run at runAt, or as soon as possible when runAt is zero, for an
+// admin or for the system when adminUUID is nil. Enqueued within a transaction, the job only
+// runs once the transaction commits.
+func EnqueueJob(db *gorm.DB, name string, adminUUID *uuid.UUID, payload interface{}, runAt time.Time) (models.Job, error) {
+	job, err := newJob(name, adminUUID, payload, runAt)
+	if err != nil {
+		return job, err
+	}
+	err = db.Create(&job).Error
+	return job, err
+}
+
+// newJob builds a queued job of a registered type.
+func newJob(name string, adminUUID *uuid.UUID, payload interface{}, runAt time.Time) (models.Job, error) {
+	registered, ok := lookupJobType(name)
+	if !ok {
+		return models.Job{}, fmt.Errorf("%w: %s", ErrUnknownJobType, name)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	return models.Job{
+		Type:        name,
+		Payload:     string(data),
+		Status:      models.JobStatusQueued,
+		RunAt:       runAt,
+		MaxAttempts: registered.maxAttempts,
+		AdminUUID:   adminUUID,
+	}, nil
+}
+
+// DecodeJobPayload unmarshals the payload of a job into v.
+func DecodeJobPayload(job models.Job, v interface{}) error {
+	return json.Unmarshal([]byte(job.Payload), v)
+}
+
+// RetryJob queues a failed job again with a fresh set of attempts.
+func RetryJob(db *gorm.DB, job *models.Job) error {
+	job.Status = models.JobStatusQueued
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	job.LastError = ""
+	job.StartedAt = nil
+	job.FinishedAt = nil
+
+	return db.Model(job).Select("status", "attempts", "run_at", "last_error", "started_at", "finished_at").Updates(job).Error
+}
+
+// JobRetryDelay is the wait after a job's failed attempts before it is tried again.
+func JobRetryDelay(attempts int) time.Duration {
+	delay := jobRetryDelay
+	for i := 1; i < attempts && delay < jobMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > jobMaxRetryDelay {
+		delay = jobMaxRetryDelay
+	}
+	return delay
+}
+
+// JobRunner runs the queued jobs with a pool of workers. Several instances can share the
+// queue: each job is claimed by one worker, and the lock is renewed while it runs so the job
+// is recovered if its worker disappears.
+type JobRunner struct {
+	db     *gorm.DB
+	id     string
+	stop   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// StartJobRunner starts workers running the queued jobs, and a loop recovering lost jobs,
+// scheduling cron jobs and purging old jobs.
+func StartJobRunner(db *gorm.DB, workers int) *JobRunner {
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runner := &JobRunner{
+		db:     db,
+		id:     fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), uuid.New().String()[:8]),
+		stop:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for i := 0; i < workers; i++ {
+		runner.wg.Add(1)
+		go runner.work()
+	}
+
+	runner.wg.Add(1)
+	go runner.maintain()
+
+	return runner
+}
+
+// Shutdown stops claiming jobs and waits for the running ones to finish. When ctx is done
+// first, the running jobs are cancelled and queued again, and ctx's error is returned.
+func (r *JobRunner) Shutdown(ctx context.Context) error {
+	close(r.stop)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.cancel()
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Go runs a background loop alongside the workers. The loop must return once stop is
+// closed, and Shutdown waits for it like for the running jobs.
+func (r *JobRunner) Go(loop func(stop <-chan struct{})) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		loop(r.stop)
+	}()
+}
+
+// stopping reports whether the runner was asked to stop.
+func (r *JobRunner) stopping() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait pauses for d, and reports false when the runner is stopped meanwhile.
+func (r *JobRunner) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-r.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// work claims and runs due jobs until the runner is stopped.
+func (r *JobRunner) work() {
+	defer r.wg.Done()
+
+	for !r.stopping() {
+		job, claimed, err := claimJob(r.db, r.id, time.Now())
+		if err != nil {
+			log.Println("Failed to claim a job:", err)
+		}
+		if err != nil || !claimed {
+			if !r.wait(jobPollInterval) {
+				return
+			}
+			continue
+		}
+
+		r.run(job)
+	}
+}
+
+// claimJob locks the next due job and marks it running for the worker.
+func claimJob(db *gorm.DB, workerID string, now time.Time) (models.Job, bool, error) {
+	var job models.Job
+	claimed := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", models.JobStatusQueued, now).
+			Order("run_at, id").Limit(1).Find(&job).Error
+		if err != nil || job.ID == 0 {
+			return err
+		}
+
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedBy = workerID
+		job.LockedAt = &now
+		job.StartedAt = &now
+		claimed = true
+		return tx.Model(&job).Select("status", "attempts", "locked_by", "locked_at", "started_at").Updates(&job).Error
+	})
+
+	return job, claimed && err == nil, err
+}
+
+// run runs a claimed job, renewing its lock meanwhile, and records how it ended.
+func (r *JobRunner) run(job models.Job) {
+	heartbeatDone := make(chan struct{})
+	go r.heartbeat(job, heartbeatDone)
+
+	err := runJobHandler(r.ctx, r.db, job)
+	close(heartbeatDone)
+
+	if err != nil {
+		log.Println("Job", job.UUID, job.Type, "failed:", err)
+	}
+	if err := finishJob(r.db, r.id, job, err, r.ctx.Err() != nil, time.Now()); err != nil {
+		log.Println("Failed to record the end of job", job.UUID+":", err)
+	}
+}
+
+// runJobHandler calls the handler of a job, turning a panic into an error.
+func runJobHandler(ctx context.Context, db *gorm.DB, job models.Job) (err error) {
+	registered, ok := lookupJobType(job.Type)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+
+	return registered.handler(ctx, db, job)
+}
+
+// heartbeat renews the lock of a running job until done is closed.
+func (r *JobRunner) heartbeat(job models.Job, done chan struct{}) {
+	ticker := time.NewTicker(jobHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if err := r.db.Model(&models.Job{}).Where("id = ? AND locked_by = ?", job.ID, r.id).
+				Update("locked_at", now).Error; err != nil {
+				log.Println("Failed to renew the lock of job", job.UUID+":", err)
+			}
+		}
+	}
+}
+
+// finishJob records the end of a job run by the worker: succeeded, queued for a retry after a
+// backoff, failed once it ran out of attempts, or queued again at once when it was
+// interrupted by the runner shutting down.
+func finishJob(db *gorm.DB, workerID string, job models.Job, jobErr error, interrupted bool, now time.Time) error {
+	updates := map[string]interface{}{"locked_by": "", "locked_at": nil}
+	switch {
+	case jobErr == nil:
+		updates["status"] = models.JobStatusSucceeded
+		updates["last_error"] = ""
+		updates["finished_at"] = now
+	case interrupted:
+		updates["status"] = models.JobStatusQueued
+		updates["attempts"] = job.Attempts - 1
+		updates["run_at"] = now
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobStatusFailed
+		updates["last_error"] = jobErr.Error()
+		updates["finished_at"] = now
+	default:
+		updates["status"] = models.JobStatusQueued
+		updates["last_error"] = jobErr.Error()
+		updates["run_at"] = now.Add(JobRetryDelay(job.Attempts))
+	}
+
+	// Leave the job alone when it was recovered from this worker meanwhile
+	return db.Model(&models.Job{}).Where("id = ? AND locked_by = ?", job.ID, workerID).Updates(updates).Error
+}
+
+// maintain recovers lost jobs and schedules cron jobs on every tick, and purges the jobs
+// finished and the blobs stored longer ago than JobRetention once an hour, until the runner
+// is stopped.
+func (r *JobRunner) maintain() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(jobMaintenanceInterval)
+	defer ticker.Stop()
+
+	lastPurge := time.Time{}
+	for {
+		now := time.Now()
+		if err := RecoverLostJobs(r.db, now); err != nil {
+			log.Println("Failed to recover lost jobs:", err)
+		}
+		if err := EnqueueDueCronJobs(r.db, now); err != nil {
+			log.Println("Failed to schedule cron jobs:", err)
+		}
+		if now.Sub(lastPurge) >= time.Hour {
+			lastPurge = now
+			if err := r.db.Where("status IN ? AND finished_at < ?", []string{models.JobStatusSucceeded, models.JobStatusFailed}, now.Add(-JobRetention)).
+				Delete(&models.Job{}).Error; err != nil {
+				log.Println("Failed to purge finished jobs:", err)
+			}
+			if err := r.db.Where("created_at < ?", now.Add(-JobRetention)).Delete(&models.JobBlob{}).Error; err != nil {
+				log.Println("Failed to purge job blobs:", err)
+			}
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RecoverLostJobs queues again the running jobs whose lock was not renewed within the lock
+// timeout, or marks them failed when they ran out of attempts.
+func RecoverLostJobs(db *gorm.DB, now time.Time) error {
+	lost := db.Model(&models.Job{}).Where("status = ? AND locked_at < ?", models.JobStatusRunning, now.Add(-jobLockTimeout))
+
+	if err := lost.Session(&gorm.Session{}).Where("attempts >= max_attempts").Updates(map[string]interface{}{
+		"status":      models.JobStatusFailed,
+		"last_error":  "The worker running the job stopped",
+		"finished_at": now,
+		"locked_by":   "",
+		"locked_at":   nil,
+	}).Error; err != nil {
+		return err
+	}
+
+	return lost.Session(&gorm.Session{}).Updates(map[string]interface{}{
+		"status":    models.JobStatusQueued,
+		"run_at":    now,
+		"locked_by": "",
+		"locked_at": nil,
+	}).Error
+}
@@ -0,0 +1,279 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Field types of list filters, deciding how values are parsed and which operators apply.
+const (
+	FieldString = "string"
+	FieldNumber = "number"
+	FieldTime   = "time"
+)
+
+var (
+	// ErrInvalidFilter is returned when a filter names an unknown field or operator, or has a malformed value.
+	ErrInvalidFilter = errors.New("Invalid filter")
+	// ErrInvalidSort is returned when a sort names an unknown field.
+	ErrInvalidSort = errors.New("Invalid sort")
+	// ErrInvalidFacet is returned when an unknown facet is requested.
+	ErrInvalidFacet = errors.New("Invalid facet")
+)
+
+// filterKey matches query parameters such as filter[quantity][lt].
+var filterKey = regexp.MustCompile(`^filter\[([a-z_]+)\](?:\[([a-z]+)\])?$`)
+
+// filterOperators maps filter operators to SQL, by the field types they apply to.
+var filterOperators = map[string]struct {
+	sql   string
+	types []string
+}{
+	"eq":   {"= ?", []string{FieldString, FieldNumber, FieldTime}},
+	"ne":   {"<> ?", []string{FieldString, FieldNumber, FieldTime}},
+	"lt":   {"< ?", []string{FieldNumber, FieldTime}},
+	"lte":  {"<= ?", []string{FieldNumber, FieldTime}},
+	"gt":   {"> ?", []string{FieldNumber, FieldTime}},
+	"gte":  {">= ?", []string{FieldNumber, FieldTime}},
+	"in":   {"IN ?", []string{FieldString, FieldNumber}},
+	"like": {"LIKE ?", []string{FieldString}},
+}
+
+// ListField is a column a list endpoint can be filtered and sorted on.
+type ListField struct {
+	Column string
+	Type   string
+}
+
+// FacetCount is the number of items of a list having one value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facet counts the items selected by query for each of their values.
+type Facet func(query *gorm.DB) ([]FacetCount, error)
+
+// ListSpec whitelists the fields and facets of a list endpoint.
+type ListSpec struct {
+	Fields map[string]ListField
+	Facets map[string]Facet
+	// IDColumn breaks ties so every sort gives a stable order.
+	IDColumn string
+}
+
+// ApplyFilters adds the filter[field][op]=value parameters of values to query. The operator
+// defaults to eq, and in takes a comma-separated list.
+func ApplyFilters(query *gorm.DB, spec ListSpec, values url.Values) (*gorm.DB, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+
+		match := filterKey.FindStringSubmatch(key)
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFilter, key)
+		}
+		name, op := match[1], match[2]
+		if op == "" {
+			op = "eq"
+		}
+
+		field, ok := spec.Fields[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %s", ErrInvalidFilter, name)
+		}
+		operator, ok := filterOperators[op]
+		if !ok || !containsString(operator.types, field.Type) {
+			return nil, fmt.Errorf("%w: %s does not support %s", ErrInvalidFilter, name, op)
+		}
+
+		for _, raw := range values[key] {
+			var value interface{}
+			var err error
+			switch op {
+			case "in":
+				value, err = parseFilterList(field.Type, raw)
+			case "like":
+				value = ContainsPattern(raw)
+			default:
+				value, err = parseFilterValue(field.Type, raw)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s %s", ErrInvalidFilter, name, err.Error())
+			}
+
+			query = query.Where(field.Column+" "+operator.sql, value)
+		}
+	}
+
+	return query, nil
+}
+
+// ApplySort orders query by a comma-separated list of fields, each descending when prefixed
+// with a minus sign, then by the ID column.
+func ApplySort(query *gorm.DB, spec ListSpec, sortParam string) (*gorm.DB, error) {
+	columns, err := sortColumns(spec, sortParam)
+	if err != nil {
+		return nil, err
+	}
+	return orderBy(query, columns, false), nil
+}
+
+// sortColumn is a column a list is ordered by.
+type sortColumn struct {
+	ListField
+	Desc bool
+}
+
+// sortColumns parses a sort parameter into the columns it orders by, ending with the ID column.
+func sortColumns(spec ListSpec, sortParam string) ([]sortColumn, error) {
+	var columns []sortColumn
+	for _, name := range strings.Split(sortParam, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		field, ok := spec.Fields[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %s", ErrInvalidSort, name)
+		}
+		columns = append(columns, sortColumn{ListField: field, Desc: desc})
+	}
+
+	return append(columns, sortColumn{ListField: ListField{Column: spec.IDColumn, Type: FieldNumber}}), nil
+}
+
+// orderBy orders query by columns, in the opposite direction when reverse is set.
+func orderBy(query *gorm.DB, columns []sortColumn, reverse bool) *gorm.DB {
+	for _, column := range columns {
+		direction := "ASC"
+		if column.Desc != reverse {
+			direction = "DESC"
+		}
+		query = query.Order(column.Column + " " + direction)
+	}
+	return query
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ContainsPattern returns a LIKE pattern matching the text anywhere, its own % and _ taken
+// literally.
+func ContainsPattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+// CountFacets counts the values of the named facets over the items selected by query. No
+// facet is counted when names is empty.
+func CountFacets(query *gorm.DB, spec ListSpec, names []string) (map[string][]FacetCount, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	facets := make(map[string][]FacetCount, len(names))
+	for _, name := range names {
+		facet, ok := spec.Facets[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFacet, name)
+		}
+
+		counts, err := facet(query.Session(&gorm.Session{}))
+		if err != nil {
+			return nil, err
+		}
+		facets[name] = counts
+	}
+
+	return facets, nil
+}
+
+// ColumnFacet counts the items of a list by the values of one of their columns.
+func ColumnFacet(column string) Facet {
+	return func(query *gorm.DB) ([]FacetCount, error) {
+		counts := []FacetCount{}
+		err := query.Select(column + " AS value, COUNT(*) AS count").Group(column).Order("count DESC, value").Scan(&counts).Error
+		return counts, err
+	}
+}
+
+// SubqueryFacet counts the values selected by facetQuery for the items whose key, selected
+// from the list by keyColumn, is in keyField of facetQuery.
+func SubqueryFacet(facetQuery func(db *gorm.DB) *gorm.DB, valueExpr string, keyField string, keyColumn string) Facet {
+	return func(query *gorm.DB) ([]FacetCount, error) {
+		counts := []FacetCount{}
+		err := facetQuery(query.Session(&gorm.Session{NewDB: true})).
+			Select(valueExpr+" AS value, COUNT(*) AS count").
+			Where(keyField+" IN (?)", query.Select(keyColumn)).
+			Group(valueExpr).
+			Order("count DESC, value").
+			Scan(&counts).Error
+		return counts, err
+	}
+}
+
+// parseFilterValue converts a filter value to the type of its field.
+func parseFilterValue(fieldType string, raw string) (interface{}, error) {
+	switch fieldType {
+	case FieldNumber:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return number, nil
+	case FieldTime:
+		if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+			return parsed, nil
+		}
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, errors.New("must be an RFC 3339 time or a date")
+		}
+		return parsed, nil
+	default:
+		return raw, nil
+	}
+}
+
+// parseFilterList converts a comma-separated filter value to a list of the type of its field.
+func parseFilterList(fieldType string, raw string) (interface{}, error) {
+	parts := strings.Split(raw, ",")
+	list := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		value, err := parseFilterValue(fieldType, strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+// containsString reports whether list contains value.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package utils
+
+import "gorm.io/gorm"
+
+// ProductListSpec whitelists the filters, sorts and facets of GET /products.
+var ProductListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"product_name": {Column: "products.product_name", Type: FieldString},
+		"status":       {Column: "products.status", Type: FieldString},
+		"type":         {Column: "products.type", Type: FieldString},
+		"created_at":   {Column: "products.created_at", Type: FieldTime},
+		"updated_at":   {Column: "products.updated_at", Type: FieldTime},
+		"published_at": {Column: "products.published_at", Type: FieldTime},
+	},
+	Facets: map[string]Facet{
+		"status": ColumnFacet("products.status"),
+		"type":   ColumnFacet("products.type"),
+		"tags": SubqueryFacet(func(db *gorm.DB) *gorm.DB {
+			return db.Table("product_tags").Joins("JOIN tags ON tags.uuid = product_tags.tag_uuid")
+		}, "tags.name", "product_tags.product_uuid", "products.uuid"),
+	},
+	IDColumn: "products.id",
+}
+
+// VariantListSpec whitelists the filters, sorts and facets of GET /products/variants.
+var VariantListSpec = ListSpec{
+	Fields: map[string]ListField{
+		"variant_name": {Column: "variants.variant_name", Type: FieldString},
+		"quantity":     {Column: "variants.quantity", Type: FieldNumber},
+		"price":        {Column: "variants.price", Type: FieldNumber},
+		"sku":          {Column: "variants.sku", Type: FieldString},
+		"barcode":      {Column: "variants.barcode", Type: FieldString},
+		"product_uuid": {Column: "variants.product_uuid", Type: FieldString},
+		"created_at":   {Column: "variants.created_at", Type: FieldTime},
+		"updated_at":   {Column: "variants.updated_at", Type: FieldTime},
+	},
+	Facets: map[string]Facet{
+		"stock": ColumnFacet("CASE WHEN variants.quantity > 0 THEN 'in_stock' ELSE 'out_of_stock' END"),
+		"attributes": SubqueryFacet(func(db *gorm.DB) *gorm.DB {
+			return db.Table("variant_attributes")
+		}, "CONCAT(variant_attributes.name, ':', variant_attributes.value)", "variant_attributes.variant_uuid", "variants.uuid"),
+	},
+	IDColumn: "variants.id",
+}
@@ -0,0 +1,336 @@
+package utils
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrVariantNotFound is returned when an order references a variant that does not exist.
+	ErrVariantNotFound = errors.New("Variant not found")
+	// ErrVariantNotInCatalog is returned when an order references a variant of another admin.
+	ErrVariantNotInCatalog = errors.New("Variant does not belong to your catalog")
+	// ErrProductUnavailable is returned when an order references a variant of a product that is not active.
+	ErrProductUnavailable = errors.New("Product is not available for sale")
+	// ErrInvalidOrderTransition is returned when an order cannot move to the requested status.
+	ErrInvalidOrderTransition = errors.New("Invalid order status transition")
+)
+
+// orderTransitions lists the statuses each order status can move to.
+var orderTransitions = map[string][]string{
+	models.OrderStatusPending: {models.OrderStatusPaid, models.OrderStatusCancelled},
+	models.OrderStatusPaid:    {models.OrderStatusShipped, models.OrderStatusCancelled},
+}
+
+// OrderItem is a variant and quantity to place in an order.
+type OrderItem struct {
+	VariantUUID string
+	Quantity    uint
+}
+
+// CanTransitionOrder reports whether an order in status from can move to status to.
+func CanTransitionOrder(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PlaceOrder creates a pending order for the items and decrements the stock of every
+// variant in a single transaction, so either the whole order is placed or nothing changes.
+func PlaceOrder(db *gorm.DB, adminUUID uuid.UUID, items []OrderItem) (models.Order, error) {
+	var order models.Order
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = PlaceOrderTx(tx, adminUUID, items)
+		return err
+	})
+
+	return order, err
+}
+
+// PlaceOrderTx is PlaceOrder for callers that already run inside a transaction.
+func PlaceOrderTx(tx *gorm.DB, adminUUID uuid.UUID, items []OrderItem) (models.Order, error) {
+	currency := helpers.EnvBaseCurrency()
+
+	// Merge repeated variants
+	quantities := make(map[string]uint)
+	var variantUUIDs []string
+	for _, item := range items {
+		if _, ok := quantities[item.VariantUUID]; !ok {
+			variantUUIDs = append(variantUUIDs, item.VariantUUID)
+		}
+		quantities[item.VariantUUID] += item.Quantity
+	}
+	sort.Strings(variantUUIDs)
+
+	// Lock the variants and the components of bundles together in a stable order to avoid deadlocks
+	stock, components, err := LockOrderStock(tx, variantUUIDs)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	order := models.Order{Status: models.OrderStatusPending, AdminUUID: adminUUID}
+	lines := make([]models.OrderLine, 0, len(variantUUIDs))
+	promotionLines := make([]PromotionLine, 0, len(variantUUIDs))
+	productUUIDs := make([]string, 0, len(variantUUIDs))
+
+	for _, variantUUID := range variantUUIDs {
+		quantity := quantities[variantUUID]
+
+		variant, ok := stock[variantUUID]
+		if !ok {
+			return order, fmt.Errorf("%w: %s", ErrVariantNotFound, variantUUID)
+		}
+
+		// Share-lock the product so it cannot be unpublished or archived until the order is placed
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("uuid = ?", variant.ProductUUID).First(&product).Error; err != nil {
+			return order, err
+		}
+		if product.AdminUUID != adminUUID {
+			return order, fmt.Errorf("%w: %s", ErrVariantNotInCatalog, variantUUID)
+		}
+		if product.Status != models.ProductStatusActive {
+			return order, fmt.Errorf("%w: %s", ErrProductUnavailable, product.UUID)
+		}
+
+		sold, err := DecrementStock(tx, variantUUID, quantity, stock, components)
+		if err != nil {
+			return order, err
+		}
+
+		lines = append(lines, models.OrderLine{
+			VariantUUID: uuid.MustParse(variant.UUID),
+			VariantName: variant.VariantName,
+			Quantity:    quantity,
+			UnitPrice:   variant.Price,
+			Components:  orderLineComponents(sold.Components),
+		})
+		promotionLines = append(promotionLines, PromotionLine{
+			VariantUUID: variant.UUID,
+			ProductUUID: product.UUID,
+			Quantity:    quantity,
+			UnitPrice:   variant.Price,
+		})
+		productUUIDs = append(productUUIDs, product.UUID)
+	}
+
+	// Category promotions also apply to the products of descendant categories
+	categoryUUIDs, err := ProductCategoryUUIDs(tx, productUUIDs)
+	if err != nil {
+		return order, err
+	}
+	for i := range promotionLines {
+		promotionLines[i].CategoryUUIDs = categoryUUIDs[promotionLines[i].ProductUUID]
+	}
+
+	evaluation, err := applyOrderPromotions(tx, adminUUID, promotionLines, time.Now(), currency)
+	if err != nil {
+		return order, err
+	}
+	for i := range lines {
+		lines[i].Discount = evaluation.Lines[i].Discount
+		lines[i].Total = evaluation.Lines[i].Total
+	}
+	order.Discount = evaluation.Discount
+	order.Total = evaluation.Total
+
+	if err := tx.Omit(clause.Associations).Create(&order).Error; err != nil {
+		return order, err
+	}
+
+	orderUUID := uuid.MustParse(order.UUID)
+	for i := range lines {
+		lines[i].OrderUUID = orderUUID
+	}
+	if len(lines) > 0 {
+		if err := tx.Create(&lines).Error; err != nil {
+			return order, err
+		}
+	}
+	order.Lines = lines
+
+	return order, nil
+}
+
+// applyOrderPromotions evaluates the admin's promotions against the lines of an order and
+// counts a use of every promotion applied. The count only goes up while it is under the
+// promotion's usage limit, so concurrent orders cannot overuse a promotion; one whose limit
+// was reached in the meantime is left out and the lines are evaluated again.
+func applyOrderPromotions(tx *gorm.DB, adminUUID uuid.UUID, lines []PromotionLine, now time.Time, currency string) (PromotionEvaluation, error) {
+	var promotions []models.Promotion
+	if err := tx.Where("admin_uuid = ?", adminUUID).Find(&promotions).Error; err != nil {
+		return PromotionEvaluation{}, err
+	}
+
+	for {
+		evaluation := EvaluatePromotions(promotions, lines, now, currency)
+
+		exhausted, err := usePromotions(tx, appliedPromotionUUIDs(evaluation))
+		if err != nil || exhausted == "" {
+			return evaluation, err
+		}
+
+		remaining := promotions[:0:0]
+		for _, promotion := range promotions {
+			if promotion.UUID != exhausted {
+				remaining = append(remaining, promotion)
+			}
+		}
+		promotions = remaining
+	}
+}
+
+// appliedPromotionUUIDs lists the promotions giving a discount in an evaluation, once each.
+func appliedPromotionUUIDs(evaluation PromotionEvaluation) []string {
+	seen := make(map[string]bool)
+	var promotionUUIDs []string
+	for _, line := range evaluation.Lines {
+		for _, discount := range line.Discounts {
+			if !seen[discount.PromotionUUID] {
+				seen[discount.PromotionUUID] = true
+				promotionUUIDs = append(promotionUUIDs, discount.PromotionUUID)
+			}
+		}
+	}
+	sort.Strings(promotionUUIDs)
+	return promotionUUIDs
+}
+
+// usePromotions counts a use of each promotion. When one has reached its usage limit, the
+// uses counted so far are given back and its UUID is returned.
+func usePromotions(tx *gorm.DB, promotionUUIDs []string) (string, error) {
+	for i, promotionUUID := range promotionUUIDs {
+		result := tx.Model(&models.Promotion{}).
+			Where("uuid = ? AND (usage_limit = 0 OR usage_count < usage_limit)", promotionUUID).
+			Update("usage_count", gorm.Expr("usage_count + 1"))
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected > 0 {
+			continue
+		}
+
+		if len(promotionUUIDs[:i]) > 0 {
+			if err := tx.Model(&models.Promotion{}).Where("uuid IN ?", promotionUUIDs[:i]).
+				Update("usage_count", gorm.Expr("usage_count - 1")).Error; err != nil {
+				return "", err
+			}
+		}
+		return promotionUUID, nil
+	}
+	return "", nil
+}
+
+// TransitionOrder moves an order to a new status. Cancelling an order puts the stock of
+// its lines back in the same transaction, except for the units received back by returns.
+func TransitionOrder(db *gorm.DB, orderUUID string, status string) (models.Order, error) {
+	var order models.Order
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the order so concurrent transitions cannot both restock it
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").Where("uuid = ?", orderUUID).First(&order).Error; err != nil {
+			return err
+		}
+
+		if !CanTransitionOrder(order.Status, status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidOrderTransition, order.Status, status)
+		}
+
+		if status == models.OrderStatusCancelled {
+			received, err := receivedReturnQuantities(tx, order.UUID)
+			if err != nil {
+				return err
+			}
+
+			for _, line := range order.Lines {
+				// Units received back through returns are already in stock
+				returned := received[line.UUID]
+				if returned >= line.Quantity {
+					continue
+				}
+
+				if err := RestockOrderLine(tx, line, line.Quantity-returned); err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Model(&order).Update("status", status).Error
+	})
+
+	return order, err
+}
+
+// orderLineComponents snapshots the components of a bundle for an order line.
+func orderLineComponents(components []models.BundleComponent) []models.OrderLineComponent {
+	if len(components) == 0 {
+		return nil
+	}
+
+	snapshot := make([]models.OrderLineComponent, 0, len(components))
+	for _, component := range components {
+		snapshot = append(snapshot, models.OrderLineComponent{
+			VariantUUID: component.ComponentVariantUUID,
+			Quantity:    component.Quantity,
+		})
+	}
+	return snapshot
+}
+
+// RestockOrderLine puts quantity units of an order line back in stock: the variant sold, or for
+// a bundle line the components it was sold with, whatever the variants are made of now.
+// Variants deleted since the sale have no stock to restore. It must run inside a transaction.
+func RestockOrderLine(tx *gorm.DB, line models.OrderLine, quantity uint) error {
+	if len(line.Components) == 0 {
+		if _, err := IncrementVariantStock(tx, line.VariantUUID.String(), quantity); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	// Lock the components in a stable order, as selling a bundle does
+	components := append([]models.OrderLineComponent(nil), line.Components...)
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].VariantUUID.String() < components[j].VariantUUID.String()
+	})
+	for _, component := range components {
+		if _, err := IncrementVariantStock(tx, component.VariantUUID.String(), quantity*component.Quantity); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// receivedReturnQuantities sums the units of each line of an order that were received back
+// by returns, keyed by order line UUID.
+func receivedReturnQuantities(tx *gorm.DB, orderUUID string) (map[string]uint, error) {
+	var rows []struct {
+		OrderLineUUID string
+		Quantity      uint
+	}
+	if err := tx.Model(&models.Return{}).
+		Select("order_line_uuid, SUM(quantity) AS quantity").
+		Where("order_uuid = ? AND status IN ?", orderUUID, []string{models.ReturnStatusReceived, models.ReturnStatusRefunded}).
+		Group("order_line_uuid").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	received := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		received[row.OrderLineUUID] = row.Quantity
+	}
+	return received, nil
+}
@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MaxPageSize is the largest page a list endpoint returns.
+const MaxPageSize = 100
+
+var (
+	// ErrInvalidPage is returned when page or pageSize is out of range.
+	ErrInvalidPage = fmt.Errorf("page must be at least 1 and pageSize between 1 and %d", MaxPageSize)
+	// ErrInvalidCursor is returned when a cursor is malformed or was issued for another sort.
+	ErrInvalidCursor = errors.New("Invalid cursor")
+)
+
+// schemaCache caches the parsed schemas of the models cursors are read from.
+var schemaCache = &sync.Map{}
+
+// Cursor marks the item a page starts after, or ends before when Before is set. Values holds
+// the sort columns of the item followed by its ID.
+type Cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	Before bool          `json:"b,omitempty"`
+}
+
+// Pagination is the page of a list requested by the page, pageSize, cursor, sort and count
+// query parameters. A cursor takes precedence over page.
+type Pagination struct {
+	Sort     string
+	Page     int
+	PageSize int
+	Count    bool
+	Cursor   *Cursor
+
+	columns []sortColumn
+}
+
+// PageInfo holds the cursors of the pages around a fetched page, empty when there is none.
+type PageInfo struct {
+	NextCursor string
+	PrevCursor string
+}
+
+// ParsePagination reads the pagination parameters of a list request.
+func ParsePagination(spec ListSpec, values url.Values, defaultPageSize int) (Pagination, error) {
+	pagination := Pagination{Sort: strings.TrimSpace(values.Get("sort")), Page: 1, PageSize: defaultPageSize, Count: true}
+
+	var err error
+	if raw := values.Get("page"); raw != "" {
+		if pagination.Page, err = strconv.Atoi(raw); err != nil {
+			return Pagination{}, ErrInvalidPage
+		}
+	}
+	if raw := values.Get("pageSize"); raw != "" {
+		if pagination.PageSize, err = strconv.Atoi(raw); err != nil {
+			return Pagination{}, ErrInvalidPage
+		}
+	}
+	if pagination.Page < 1 || pagination.PageSize < 1 || pagination.PageSize > MaxPageSize {
+		return Pagination{}, ErrInvalidPage
+	}
+	if raw := values.Get("count"); raw != "" {
+		if pagination.Count, err = strconv.ParseBool(raw); err != nil {
+			return Pagination{}, errors.New("count must be true or false")
+		}
+	}
+
+	if pagination.columns, err = sortColumns(spec, pagination.Sort); err != nil {
+		return Pagination{}, err
+	}
+
+	if token := values.Get("cursor"); token != "" {
+		if pagination.Cursor, err = pagination.decodeCursor(token); err != nil {
+			return Pagination{}, err
+		}
+	}
+
+	return pagination, nil
+}
+
+// Apply orders query and selects the requested page, plus one item telling whether more follow.
+func (p Pagination) Apply(query *gorm.DB) *gorm.DB {
+	if p.Cursor == nil {
+		return orderBy(query, p.columns, false).Offset((p.Page - 1) * p.PageSize).Limit(p.PageSize + 1)
+	}
+
+	// Select the items after the cursor in the order of the page: for sort columns a, b and
+	// the ID, (a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND id > ?)
+	var conditions []string
+	var args []interface{}
+	for i, column := range p.columns {
+		var parts []string
+		for j := 0; j < i; j++ {
+			part, partArgs := equalTo(p.columns[j], p.Cursor.Values[j])
+			parts = append(parts, part)
+			args = append(args, partArgs...)
+		}
+		part, partArgs := after(column, p.Cursor.Values[i], column.Desc != p.Cursor.Before)
+		parts = append(parts, part)
+		args = append(args, partArgs...)
+
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	return orderBy(query, p.columns, p.Cursor.Before).Limit(p.PageSize + 1)
+}
+
+// Finish trims the items fetched with Apply, a pointer to a slice of models, to the page in
+// sort order, and returns the cursors of the next and previous pages.
+func (p Pagination) Finish(db *gorm.DB, items interface{}) (PageInfo, error) {
+	slice := reflect.ValueOf(items).Elem()
+	more := slice.Len() > p.PageSize
+	if more {
+		slice.Set(slice.Slice(0, p.PageSize))
+	}
+
+	if p.Cursor != nil && p.Cursor.Before {
+		swap := reflect.Swapper(slice.Interface())
+		for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	// A page reached through a cursor has items on the side it was reached from
+	hasNext, hasPrev := more, p.Page > 1
+	if p.Cursor != nil && p.Cursor.Before {
+		hasNext, hasPrev = true, more
+	} else if p.Cursor != nil {
+		hasPrev = true
+	}
+
+	var info PageInfo
+	if slice.Len() == 0 {
+		return info, nil
+	}
+
+	itemSchema, err := schema.Parse(slice.Index(0).Addr().Interface(), schemaCache, db.NamingStrategy)
+	if err != nil {
+		return info, err
+	}
+	if hasNext {
+		if info.NextCursor, err = p.encodeCursor(itemSchema, slice.Index(slice.Len()-1), false); err != nil {
+			return info, err
+		}
+	}
+	if hasPrev {
+		if info.PrevCursor, err = p.encodeCursor(itemSchema, slice.Index(0), true); err != nil {
+			return info, err
+		}
+	}
+
+	return info, nil
+}
+
+// encodeCursor builds the token of a cursor at item.
+func (p Pagination) encodeCursor(itemSchema *schema.Schema, item reflect.Value, before bool) (string, error) {
+	cursor := Cursor{Sort: p.Sort, Before: before}
+	for _, column := range p.columns {
+		name := column.Column[strings.LastIndex(column.Column, ".")+1:]
+		field := itemSchema.LookUpField(name)
+		if field == nil {
+			return "", fmt.Errorf("no field for sort column %s", column.Column)
+		}
+
+		value, zero := field.ValueOf(context.Background(), item)
+		if zero && field.FieldType.Kind() == reflect.Ptr {
+			value = nil
+		} else if t, ok := value.(*time.Time); ok {
+			value = *t
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a cursor token, checking it was issued for the sort of the request.
+func (p Pagination) decodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != p.Sort {
+		return nil, fmt.Errorf("%w: it was issued for another sort", ErrInvalidCursor)
+	}
+	if len(cursor.Values) != len(p.columns) {
+		return nil, ErrInvalidCursor
+	}
+
+	// JSON decodes every number as a float and times as strings, so convert them back
+	for i, column := range p.columns {
+		switch value := cursor.Values[i].(type) {
+		case nil:
+		case float64:
+			if column.Type != FieldNumber {
+				return nil, ErrInvalidCursor
+			}
+		case string:
+			if column.Type == FieldNumber {
+				return nil, ErrInvalidCursor
+			}
+			if column.Type == FieldTime {
+				parsed, err := time.Parse(time.RFC3339Nano, value)
+				if err != nil {
+					return nil, ErrInvalidCursor
+				}
+				cursor.Values[i] = parsed
+			}
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	return &cursor, nil
+}
+
+// equalTo is the condition of column being equal to value, NULL included.
+func equalTo(column sortColumn, value interface{}) (string, []interface{}) {
+	if value == nil {
+		return column.Column + " IS NULL", nil
+	}
+	return column.Column + " = ?", []interface{}{value}
+}
+
+// after is the condition of column coming after value in the given direction. MySQL sorts
+// NULL first in ascending order and last in descending order.
+func after(column sortColumn, value interface{}, desc bool) (string, []interface{}) {
+	switch {
+	case !desc && value == nil:
+		return column.Column + " IS NOT NULL", nil
+	case !desc:
+		return column.Column + " > ?", []interface{}{value}
+	case value == nil:
+		return "1 = 0", nil
+	default:
+		return "(" + column.Column + " < ? OR " + column.Column + " IS NULL)", []interface{}{value}
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"basictrade/models"
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidProductStatus is returned when a product status is not draft, active or archived.
+	ErrInvalidProductStatus = errors.New("Status must be draft, active or archived")
+	// ErrFuturePublishTime is returned when a product is made active with a publish time in the future.
+	ErrFuturePublishTime = errors.New("An active product cannot be published in the future; keep it as a draft to schedule it")
+)
+
+var (
+	markdown       = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	markdownPolicy = bluemonday.UGCPolicy()
+)
+
+// RenderMarkdown converts a Markdown description to HTML that is safe to embed in a page.
+func RenderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return markdownPolicy.Sanitize(buf.String()), nil
+}
+
+// ApplyProductStatus sets the status and publish time of a product. An empty status keeps the
+// current one, or for a new product means draft when publishedAt is in the future and active
+// otherwise. Making a product active publishes it now unless it was published before. A draft
+// or archived product only keeps a publish time in the future, as its schedule, so that
+// unpublishing a product does not get it published again by PublishDueProducts.
+func ApplyProductStatus(product *models.Product, status string, publishedAt *time.Time, now time.Time) error {
+	if status == "" {
+		status = product.Status
+	}
+	if status == "" {
+		status = models.ProductStatusActive
+		if publishedAt != nil && publishedAt.After(now) {
+			status = models.ProductStatusDraft
+		}
+	}
+
+	switch status {
+	case models.ProductStatusDraft, models.ProductStatusArchived:
+		if publishedAt != nil {
+			product.PublishedAt = publishedAt
+		}
+		if product.PublishedAt != nil && !product.PublishedAt.After(now) {
+			product.PublishedAt = nil
+		}
+	case models.ProductStatusActive:
+		if publishedAt != nil && publishedAt.After(now) {
+			return ErrFuturePublishTime
+		}
+		if publishedAt != nil {
+			product.PublishedAt = publishedAt
+		}
+		if product.PublishedAt == nil || product.PublishedAt.After(now) {
+			product.PublishedAt = &now
+		}
+	default:
+		return ErrInvalidProductStatus
+	}
+
+	product.Status = status
+	return nil
+}
+
+// PublishDueProducts makes active the drafts whose publish time has passed, recording a
+// product.updated event for each one.
+func PublishDueProducts(db *gorm.DB, now time.Time) (int64, error) {
+	var published int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var products []models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND published_at <= ?", models.ProductStatusDraft, now).
+			Find(&products).Error; err != nil {
+			return err
+		}
+
+		for _, product := range products {
+			product.Status = models.ProductStatusActive
+			if err := tx.Model(&product).Update("status", product.Status).Error; err != nil {
+				return err
+			}
+			if err := RecordProductEvent(tx, models.EventProductUpdated, product); err != nil {
+				return err
+			}
+		}
+
+		published = int64(len(products))
+		return nil
+	})
+	return published, err
+}
+
+// publishDueProductsJob runs PublishDueProducts as a background job.
+func publishDueProductsJob(ctx context.Context, db *gorm.DB, job models.Job) error {
+	published, err := PublishDueProducts(db, time.Now())
+	if err != nil {
+		return err
+	}
+	if published > 0 {
+		log.Printf("Published %d scheduled products\n", published)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"basictrade/models"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// PromotionLine is a line to evaluate promotions against.
+type PromotionLine struct {
+	VariantUUID   string
+	ProductUUID   string
+	CategoryUUIDs []string
+	Quantity      uint
+	UnitPrice     float64
+}
+
+// AppliedDiscount is the discount a single promotion gives on a line.
+type AppliedDiscount struct {
+	PromotionUUID string  `json:"promotion_uuid"`
+	Name          string  `json:"name"`
+	Type          string  `json:"type"`
+	Amount        float64 `json:"amount"`
+}
+
+// LineEvaluation is the result of evaluating promotions against a line.
+type LineEvaluation struct {
+	VariantUUID string            `json:"variant_uuid"`
+	Quantity    uint              `json:"quantity"`
+	UnitPrice   float64           `json:"unit_price"`
+	Subtotal    float64           `json:"subtotal"`
+	Discount    float64           `json:"discount"`
+	Total       float64           `json:"total"`
+	Discounts   []AppliedDiscount `json:"discounts"`
+}
+
+// PromotionEvaluation is the result of evaluating promotions against a list of lines.
+type PromotionEvaluation struct {
+	Lines    []LineEvaluation `json:"lines"`
+	Subtotal float64          `json:"subtotal"`
+	Discount float64          `json:"discount"`
+	Total    float64          `json:"total"`
+}
+
+// ValidatePromotion checks that the values of a promotion make sense for its type.
+func ValidatePromotion(promotion models.Promotion) error {
+	switch promotion.Type {
+	case models.PromotionTypePercentage:
+		if promotion.Value <= 0 || promotion.Value > 100 {
+			return errors.New("Percentage value must be greater than 0 and at most 100")
+		}
+	case models.PromotionTypeFixedAmount:
+		if promotion.Value <= 0 {
+			return errors.New("Fixed amount value must be greater than 0")
+		}
+	case models.PromotionTypeBuyXGetY:
+		if promotion.BuyQuantity == 0 || promotion.GetQuantity == 0 {
+			return errors.New("Buy and get quantities must be at least 1")
+		}
+		if promotion.Value <= 0 || promotion.Value > 100 {
+			return errors.New("Buy X get Y value must be greater than 0 and at most 100")
+		}
+	default:
+		return errors.New("Unknown promotion type")
+	}
+
+	switch promotion.TargetType {
+	case models.PromotionTargetProduct, models.PromotionTargetVariant, models.PromotionTargetCategory:
+	default:
+		return errors.New("Unknown promotion target type")
+	}
+
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return errors.New("Promotion must end after it starts")
+	}
+
+	if promotion.MinimumSpend < 0 {
+		return errors.New("Minimum spend must not be negative")
+	}
+
+	return nil
+}
+
+// PromotionIsActive reports whether a promotion can be applied at the given time.
+func PromotionIsActive(promotion models.Promotion, now time.Time) bool {
+	if promotion.StartsAt != nil && now.Before(*promotion.StartsAt) {
+		return false
+	}
+	if promotion.EndsAt != nil && !now.Before(*promotion.EndsAt) {
+		return false
+	}
+	if promotion.UsageLimit > 0 && promotion.UsageCount >= promotion.UsageLimit {
+		return false
+	}
+	return true
+}
+
+// promotionTargetsLine reports whether a promotion targets the line.
+func promotionTargetsLine(promotion models.Promotion, line PromotionLine) bool {
+	switch promotion.TargetType {
+	case models.PromotionTargetVariant:
+		return promotion.TargetUUID == line.VariantUUID
+	case models.PromotionTargetProduct:
+		return promotion.TargetUUID == line.ProductUUID
+	case models.PromotionTargetCategory:
+		for _, categoryUUID := range line.CategoryUUIDs {
+			if promotion.TargetUUID == categoryUUID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// promotionMeetsMinimumSpend reports whether the lines a promotion targets reach its minimum spend.
+func promotionMeetsMinimumSpend(promotion models.Promotion, lines []PromotionLine, currency string) bool {
+	if promotion.MinimumSpend <= 0 {
+		return true
+	}
+
+	var spend float64
+	for _, line := range lines {
+		if promotionTargetsLine(promotion, line) {
+			spend += RoundPrice(line.UnitPrice*float64(line.Quantity), currency)
+		}
+	}
+	return RoundPrice(spend, currency) >= promotion.MinimumSpend
+}
+
+// promotionDiscount computes the discount of a promotion on a quantity of units at a unit price.
+func promotionDiscount(promotion models.Promotion, unitPrice float64, quantity uint) float64 {
+	subtotal := unitPrice * float64(quantity)
+
+	var discount float64
+	switch promotion.Type {
+	case models.PromotionTypePercentage:
+		discount = subtotal * promotion.Value / 100
+	case models.PromotionTypeFixedAmount:
+		discount = math.Min(promotion.Value, unitPrice) * float64(quantity)
+	case models.PromotionTypeBuyXGetY:
+		// Every complete group of buy+get units discounts the get units
+		groupSize := promotion.BuyQuantity + promotion.GetQuantity
+		discountedUnits := (quantity / groupSize) * promotion.GetQuantity
+		discount = unitPrice * float64(discountedUnits) * promotion.Value / 100
+	}
+
+	return math.Min(discount, subtotal)
+}
+
+// EvaluatePromotions computes line-level discounts for the lines, priced and rounded in
+// currency.
+//
+// A promotion with a minimum spend only applies when the lines it targets reach it together.
+// Stackable promotions are applied one after another in priority order, each on what is
+// left of the line after the previous ones. A non-stackable promotion is never combined
+// with another one. For each line the option with the largest discount wins: the best
+// non-stackable promotion alone, or all stackable promotions together.
+func EvaluatePromotions(promotions []models.Promotion, lines []PromotionLine, now time.Time, currency string) PromotionEvaluation {
+	// Keep only the promotions that can be applied, highest priority first
+	var active []models.Promotion
+	for _, promotion := range promotions {
+		if PromotionIsActive(promotion, now) && promotionMeetsMinimumSpend(promotion, lines, currency) {
+			active = append(active, promotion)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return active[i].Priority > active[j].Priority
+	})
+
+	evaluation := PromotionEvaluation{Lines: make([]LineEvaluation, 0, len(lines))}
+
+	for _, line := range lines {
+		subtotal := RoundPrice(line.UnitPrice*float64(line.Quantity), currency)
+		result := LineEvaluation{
+			VariantUUID: line.VariantUUID,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			Subtotal:    subtotal,
+			Discounts:   []AppliedDiscount{},
+		}
+
+		if line.Quantity > 0 && subtotal > 0 {
+			var stacked []AppliedDiscount
+			var stackedTotal float64
+			var best *AppliedDiscount
+
+			for _, promotion := range active {
+				if !promotionTargetsLine(promotion, line) {
+					continue
+				}
+
+				if promotion.Stackable {
+					remaining := subtotal - stackedTotal
+					if remaining <= 0 {
+						continue
+					}
+					amount := RoundPrice(promotionDiscount(promotion, remaining/float64(line.Quantity), line.Quantity), currency)
+					if amount <= 0 {
+						continue
+					}
+					stacked = append(stacked, newAppliedDiscount(promotion, amount))
+					stackedTotal += amount
+					continue
+				}
+
+				amount := RoundPrice(promotionDiscount(promotion, line.UnitPrice, line.Quantity), currency)
+				if amount > 0 && (best == nil || amount > best.Amount) {
+					applied := newAppliedDiscount(promotion, amount)
+					best = &applied
+				}
+			}
+
+			if best != nil && best.Amount > stackedTotal {
+				result.Discounts = []AppliedDiscount{*best}
+				result.Discount = best.Amount
+			} else if len(stacked) > 0 {
+				result.Discounts = stacked
+				result.Discount = RoundPrice(stackedTotal, currency)
+			}
+		}
+
+		result.Total = RoundPrice(result.Subtotal-result.Discount, currency)
+
+		evaluation.Lines = append(evaluation.Lines, result)
+		evaluation.Subtotal += result.Subtotal
+		evaluation.Discount += result.Discount
+	}
+
+	evaluation.Subtotal = RoundPrice(evaluation.Subtotal, currency)
+	evaluation.Discount = RoundPrice(evaluation.Discount, currency)
+	evaluation.Total = RoundPrice(evaluation.Subtotal-evaluation.Discount, currency)
+
+	return evaluation
+}
+
+func newAppliedDiscount(promotion models.Promotion, amount float64) AppliedDiscount {
+	return AppliedDiscount{
+		PromotionUUID: promotion.UUID,
+		Name:          promotion.Name,
+		Type:          promotion.Type,
+		Amount:        amount,
+	}
+}
@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"basictrade/models"
+	"testing"
+	"time"
+)
+
+func timeAt(value string) *time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return &parsed
+}
+
+func TestEvaluatePromotions(t *testing.T) {
+	now := *timeAt("2024-06-15T12:00:00Z")
+	shirt := PromotionLine{VariantUUID: "v-shirt", ProductUUID: "p-shirt", CategoryUUIDs: []string{"c-clothing", "c-tops"}, Quantity: 2, UnitPrice: 20}
+	mug := PromotionLine{VariantUUID: "v-mug", ProductUUID: "p-mug", CategoryUUIDs: []string{"c-kitchen"}, Quantity: 5, UnitPrice: 8}
+
+	percent := func(uuid string, value float64, targetType string, target string) models.Promotion {
+		return models.Promotion{UUID: uuid, Name: uuid, Type: models.PromotionTypePercentage, Value: value, TargetType: targetType, TargetUUID: target}
+	}
+	fixed := func(uuid string, value float64, target string) models.Promotion {
+		return models.Promotion{UUID: uuid, Name: uuid, Type: models.PromotionTypeFixedAmount, Value: value, TargetType: models.PromotionTargetVariant, TargetUUID: target}
+	}
+	stackable := func(promotion models.Promotion, priority int) models.Promotion {
+		promotion.Stackable = true
+		promotion.Priority = priority
+		return promotion
+	}
+	window := func(promotion models.Promotion, startsAt, endsAt *time.Time) models.Promotion {
+		promotion.StartsAt = startsAt
+		promotion.EndsAt = endsAt
+		return promotion
+	}
+	minimumSpend := func(promotion models.Promotion, spend float64) models.Promotion {
+		promotion.MinimumSpend = spend
+		return promotion
+	}
+	tee := PromotionLine{VariantUUID: "v-tee", ProductUUID: "p-tee", CategoryUUIDs: []string{"c-clothing"}, Quantity: 1, UnitPrice: 15}
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		lines      []PromotionLine
+		currency   string
+		discounts  []float64
+		applied    [][]string
+		total      float64
+	}{
+		{
+			name:      "no promotions",
+			lines:     []PromotionLine{shirt, mug},
+			discounts: []float64{0, 0},
+			applied:   [][]string{nil, nil},
+			total:     80,
+		},
+		{
+			name:       "percentage on a product",
+			promotions: []models.Promotion{percent("ten", 10, models.PromotionTargetProduct, "p-shirt")},
+			lines:      []PromotionLine{shirt, mug},
+			discounts:  []float64{4, 0},
+			applied:    [][]string{{"ten"}, nil},
+			total:      76,
+		},
+		{
+			name:       "percentage on a category",
+			promotions: []models.Promotion{percent("kitchen", 25, models.PromotionTargetCategory, "c-kitchen")},
+			lines:      []PromotionLine{shirt, mug},
+			discounts:  []float64{0, 10},
+			applied:    [][]string{nil, {"kitchen"}},
+			total:      70,
+		},
+		{
+			name:       "fixed amount per unit",
+			promotions: []models.Promotion{fixed("three-off", 3, "v-mug")},
+			lines:      []PromotionLine{mug},
+			discounts:  []float64{15},
+			applied:    [][]string{{"three-off"}},
+			total:      25,
+		},
+		{
+			name:       "fixed amount capped at the unit price",
+			promotions: []models.Promotion{fixed("fifty-off", 50, "v-mug")},
+			lines:      []PromotionLine{mug},
+			discounts:  []float64{40},
+			applied:    [][]string{{"fifty-off"}},
+			total:      0,
+		},
+		{
+			name: "buy two get one free",
+			promotions: []models.Promotion{{UUID: "b2g1", Type: models.PromotionTypeBuyXGetY, Value: 100, BuyQuantity: 2, GetQuantity: 1,
+				TargetType: models.PromotionTargetVariant, TargetUUID: "v-mug"}},
+			// Five mugs make one complete group of three
+			lines:     []PromotionLine{mug},
+			discounts: []float64{8},
+			applied:   [][]string{{"b2g1"}},
+			total:     32,
+		},
+		{
+			name: "buy one get one half off",
+			promotions: []models.Promotion{{UUID: "b1g1", Type: models.PromotionTypeBuyXGetY, Value: 50, BuyQuantity: 1, GetQuantity: 1,
+				TargetType: models.PromotionTargetVariant, TargetUUID: "v-mug"}},
+			lines:     []PromotionLine{mug},
+			discounts: []float64{8},
+			applied:   [][]string{{"b1g1"}},
+			total:     32,
+		},
+		{
+			name: "stackable promotions apply in priority order on what is left",
+			promotions: []models.Promotion{
+				stackable(percent("tops", 10, models.PromotionTargetCategory, "c-tops"), 1),
+				stackable(percent("shirts", 50, models.PromotionTargetProduct, "p-shirt"), 5),
+			},
+			// 50% of 40, then 10% of the remaining 20
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{22},
+			applied:   [][]string{{"shirts", "tops"}},
+			total:     18,
+		},
+		{
+			name: "best non-stackable promotion beats a smaller stack",
+			promotions: []models.Promotion{
+				stackable(percent("a", 10, models.PromotionTargetProduct, "p-shirt"), 0),
+				stackable(percent("b", 10, models.PromotionTargetProduct, "p-shirt"), 0),
+				percent("big", 30, models.PromotionTargetProduct, "p-shirt"),
+				percent("small", 20, models.PromotionTargetProduct, "p-shirt"),
+			},
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{12},
+			applied:   [][]string{{"big"}},
+			total:     28,
+		},
+		{
+			name: "stack beats a smaller non-stackable promotion",
+			promotions: []models.Promotion{
+				stackable(percent("a", 20, models.PromotionTargetProduct, "p-shirt"), 0),
+				stackable(fixed("b", 5, "v-shirt"), 0),
+				percent("small", 15, models.PromotionTargetProduct, "p-shirt"),
+			},
+			// 20% of 40, then 5 off each of the two units
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{18},
+			applied:   [][]string{{"a", "b"}},
+			total:     22,
+		},
+		{
+			name: "non-stackable promotions are exclusive, even with equal discounts",
+			promotions: []models.Promotion{
+				percent("first", 25, models.PromotionTargetProduct, "p-shirt"),
+				percent("second", 25, models.PromotionTargetCategory, "c-tops"),
+			},
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{10},
+			applied:   [][]string{{"first"}},
+			total:     30,
+		},
+		{
+			name: "non-stackable promotion wins a tie with a stack",
+			promotions: []models.Promotion{
+				stackable(percent("a", 10, models.PromotionTargetProduct, "p-shirt"), 0),
+				stackable(fixed("b", 2, "v-shirt"), 0),
+				fixed("alone", 4, "v-shirt"),
+			},
+			// The stack gives 4 off, then 2 off each unit: 8, as much as the promotion alone
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{8},
+			applied:   [][]string{{"a", "b"}},
+			total:     32,
+		},
+		{
+			name: "stackable promotions stop once the line is free",
+			promotions: []models.Promotion{
+				stackable(fixed("all", 20, "v-shirt"), 2),
+				stackable(percent("more", 10, models.PromotionTargetProduct, "p-shirt"), 1),
+			},
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{40},
+			applied:   [][]string{{"all"}},
+			total:     0,
+		},
+		{
+			name:       "minimum spend not reached",
+			promotions: []models.Promotion{minimumSpend(percent("spend", 10, models.PromotionTargetProduct, "p-shirt"), 40.01)},
+			lines:      []PromotionLine{shirt, mug},
+			discounts:  []float64{0, 0},
+			applied:    [][]string{nil, nil},
+			total:      80,
+		},
+		{
+			name:       "minimum spend reached exactly",
+			promotions: []models.Promotion{minimumSpend(percent("spend", 10, models.PromotionTargetProduct, "p-shirt"), 40)},
+			lines:      []PromotionLine{shirt, mug},
+			discounts:  []float64{4, 0},
+			applied:    [][]string{{"spend"}, nil},
+			total:      76,
+		},
+		{
+			name:       "minimum spend counts every targeted line",
+			promotions: []models.Promotion{minimumSpend(percent("clothing", 10, models.PromotionTargetCategory, "c-clothing"), 50)},
+			// 40 of shirts and 15 of tees reach 50 together; the mugs are not targeted
+			lines:     []PromotionLine{shirt, tee, mug},
+			discounts: []float64{4, 1.5, 0},
+			applied:   [][]string{{"clothing"}, {"clothing"}, nil},
+			total:     89.5,
+		},
+		{
+			name: "minimum spend leaves the other promotions of a stack",
+			promotions: []models.Promotion{
+				stackable(minimumSpend(percent("big-spender", 50, models.PromotionTargetProduct, "p-shirt"), 100), 1),
+				stackable(percent("everyone", 10, models.PromotionTargetProduct, "p-shirt"), 0),
+			},
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{4},
+			applied:   [][]string{{"everyone"}},
+			total:     36,
+		},
+		{
+			name: "date window edges",
+			promotions: []models.Promotion{
+				window(percent("starts-now", 10, models.PromotionTargetProduct, "p-shirt"), timeAt("2024-06-15T12:00:00Z"), nil),
+				window(percent("ends-now", 90, models.PromotionTargetProduct, "p-mug"), nil, timeAt("2024-06-15T12:00:00Z")),
+				window(percent("ends-next-second", 50, models.PromotionTargetProduct, "p-mug"), nil, timeAt("2024-06-15T12:00:01Z")),
+			},
+			lines:     []PromotionLine{shirt, mug},
+			discounts: []float64{4, 20},
+			applied:   [][]string{{"starts-now"}, {"ends-next-second"}},
+			total:     56,
+		},
+		{
+			name: "date windows",
+			promotions: []models.Promotion{
+				window(percent("running", 10, models.PromotionTargetProduct, "p-shirt"), timeAt("2024-06-01T00:00:00Z"), timeAt("2024-07-01T00:00:00Z")),
+				window(percent("future", 90, models.PromotionTargetProduct, "p-shirt"), timeAt("2024-06-16T00:00:00Z"), nil),
+				window(percent("ended", 90, models.PromotionTargetProduct, "p-shirt"), nil, timeAt("2024-06-15T12:00:00Z")),
+			},
+			lines:     []PromotionLine{shirt},
+			discounts: []float64{4},
+			applied:   [][]string{{"running"}},
+			total:     36,
+		},
+		{
+			name:       "usage limit reached",
+			promotions: []models.Promotion{{UUID: "used", Type: models.PromotionTypePercentage, Value: 50, TargetType: models.PromotionTargetProduct, TargetUUID: "p-shirt", UsageLimit: 3, UsageCount: 3}},
+			lines:      []PromotionLine{shirt},
+			discounts:  []float64{0},
+			applied:    [][]string{nil},
+			total:      40,
+		},
+		{
+			name:       "rounded to the minor units of the currency",
+			promotions: []models.Promotion{percent("third", 33.33, models.PromotionTargetProduct, "p-mug")},
+			lines:      []PromotionLine{{VariantUUID: "v-bowl", ProductUUID: "p-mug", Quantity: 1, UnitPrice: 1000}},
+			currency:   "JPY",
+			discounts:  []float64{333},
+			applied:    [][]string{{"third"}},
+			total:      667,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			currency := test.currency
+			if currency == "" {
+				currency = "USD"
+			}
+
+			evaluation := EvaluatePromotions(test.promotions, test.lines, now, currency)
+			if len(evaluation.Lines) != len(test.lines) {
+				t.Fatalf("got %d lines, expected %d", len(evaluation.Lines), len(test.lines))
+			}
+
+			for i, line := range evaluation.Lines {
+				if line.Discount != test.discounts[i] {
+					t.Errorf("line %d discount is %v, expected %v", i, line.Discount, test.discounts[i])
+				}
+				if line.Total != RoundPrice(line.Subtotal-line.Discount, currency) {
+					t.Errorf("line %d total is %v for a subtotal of %v and a discount of %v", i, line.Total, line.Subtotal, line.Discount)
+				}
+
+				var applied []string
+				for _, discount := range line.Discounts {
+					applied = append(applied, discount.PromotionUUID)
+				}
+				if len(applied) != len(test.applied[i]) {
+					t.Errorf("line %d applied %v, expected %v", i, applied, test.applied[i])
+					continue
+				}
+				for j := range applied {
+					if applied[j] != test.applied[i][j] {
+						t.Errorf("line %d applied %v, expected %v", i, applied, test.applied[i])
+						break
+					}
+				}
+			}
+
+			if evaluation.Total != test.total {
+				t.Errorf("total is %v, expected %v", evaluation.Total, test.total)
+			}
+		})
+	}
+}
+
+func TestPromotionIsActive(t *testing.T) {
+	startsAt := timeAt("2024-06-01T00:00:00Z")
+	endsAt := timeAt("2024-07-01T00:00:00Z")
+	promotion := models.Promotion{StartsAt: startsAt, EndsAt: endsAt}
+
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2024-05-31T23:59:59Z", false},
+		{"2024-06-01T00:00:00Z", true},
+		{"2024-06-30T23:59:59Z", true},
+		{"2024-07-01T00:00:00Z", false},
+	}
+	for _, test := range tests {
+		if got := PromotionIsActive(promotion, *timeAt(test.at)); got != test.want {
+			t.Errorf("PromotionIsActive at %s = %v, expected %v", test.at, got, test.want)
+		}
+	}
+}
+
+func TestValidatePromotionMinimumSpend(t *testing.T) {
+	promotion := models.Promotion{Type: models.PromotionTypePercentage, Value: 10, TargetType: models.PromotionTargetProduct, MinimumSpend: -1}
+	if err := ValidatePromotion(promotion); err == nil {
+		t.Error("a negative minimum spend was accepted")
+	}
+
+	promotion.MinimumSpend = 0
+	if err := ValidatePromotion(promotion); err != nil {
+		t.Errorf("no minimum spend was refused: %v", err)
+	}
+}
+
+func TestAppliedPromotionUUIDs(t *testing.T) {
+	evaluation := PromotionEvaluation{Lines: []LineEvaluation{
+		{Discounts: []AppliedDiscount{{PromotionUUID: "b"}, {PromotionUUID: "a"}}},
+		{Discounts: []AppliedDiscount{{PromotionUUID: "b"}}},
+		{},
+	}}
+
+	got := appliedPromotionUUIDs(evaluation)
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("applied promotions are %v", got)
+	}
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"basictrade/models"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidPurchaseOrderTransition is returned when a purchase order cannot move to the requested status.
+	ErrInvalidPurchaseOrderTransition = errors.New("Invalid purchase order status transition")
+	// ErrPurchaseOrderNotReceivable is returned when goods are received on a purchase order that was not ordered.
+	ErrPurchaseOrderNotReceivable = errors.New("Only ordered or partially received purchase orders can be received")
+	// ErrPurchaseOrderLineNotFound is returned when goods are received for a variant that is not on the purchase order.
+	ErrPurchaseOrderLineNotFound = errors.New("Variant is not on the purchase order")
+	// ErrReceiveQuantity is returned when more units are received than are outstanding.
+	ErrReceiveQuantity = errors.New("Received quantity exceeds the outstanding quantity")
+)
+
+// purchaseOrderTransitions lists the statuses each purchase order status can be moved to by
+// hand. The received statuses are set when goods are received.
+var purchaseOrderTransitions = map[string][]string{
+	models.PurchaseOrderStatusDraft:   {models.PurchaseOrderStatusOrdered, models.PurchaseOrderStatusCancelled},
+	models.PurchaseOrderStatusOrdered: {models.PurchaseOrderStatusCancelled},
+}
+
+// PurchaseOrderItem is a variant and quantity ordered from or received from a supplier.
+type PurchaseOrderItem struct {
+	VariantUUID string
+	Quantity    uint
+	UnitCost    float64
+}
+
+// FindCatalogVariant loads a variant and checks that it belongs to the admin's catalog.
+func FindCatalogVariant(tx *gorm.DB, adminUUID uuid.UUID, variantUUID string) (models.Variant, error) {
+	var variant models.Variant
+	if err := tx.Where("uuid = ?", variantUUID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return variant, fmt.Errorf("%w: %s", ErrVariantNotFound, variantUUID)
+		}
+		return variant, err
+	}
+
+	var product models.Product
+	if err := tx.Where("uuid = ?", variant.ProductUUID).First(&product).Error; err != nil {
+		return variant, err
+	}
+	if product.AdminUUID != adminUUID {
+		return variant, fmt.Errorf("%w: %s", ErrVariantNotInCatalog, variantUUID)
+	}
+
+	return variant, nil
+}
+
+// CreatePurchaseOrder creates a draft purchase order for the items from a supplier.
+func CreatePurchaseOrder(db *gorm.DB, adminUUID uuid.UUID, supplierUUID uuid.UUID, note string, items []PurchaseOrderItem) (models.PurchaseOrder, error) {
+	purchaseOrder := models.PurchaseOrder{
+		SupplierUUID: supplierUUID,
+		Status:       models.PurchaseOrderStatusDraft,
+		Note:         note,
+		AdminUUID:    adminUUID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Merge repeated variants into one line
+		lines := make([]models.PurchaseOrderLine, 0, len(items))
+		lineIndex := make(map[string]int)
+		for _, item := range items {
+			if i, ok := lineIndex[item.VariantUUID]; ok {
+				lines[i].OrderedQuantity += item.Quantity
+				continue
+			}
+
+			variant, err := FindCatalogVariant(tx, adminUUID, item.VariantUUID)
+			if err != nil {
+				return err
+			}
+
+			lineIndex[item.VariantUUID] = len(lines)
+			lines = append(lines, models.PurchaseOrderLine{
+				VariantUUID:     uuid.MustParse(variant.UUID),
+				OrderedQuantity: item.Quantity,
+				UnitCost:        item.UnitCost,
+			})
+		}
+
+		if err := tx.Omit(clause.Associations).Create(&purchaseOrder).Error; err != nil {
+			return err
+		}
+
+		purchaseOrderUUID := uuid.MustParse(purchaseOrder.UUID)
+		for i := range lines {
+			lines[i].PurchaseOrderUUID = purchaseOrderUUID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+		purchaseOrder.Lines = lines
+
+		return nil
+	})
+
+	return purchaseOrder, err
+}
+
+// TransitionPurchaseOrder moves a purchase order to ordered or cancelled.
+func TransitionPurchaseOrder(db *gorm.DB, purchaseOrderUUID string, status string) (models.PurchaseOrder, error) {
+	var purchaseOrder models.PurchaseOrder
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").Where("uuid = ?", purchaseOrderUUID).First(&purchaseOrder).Error; err != nil {
+			return err
+		}
+
+		allowed := false
+		for _, next := range purchaseOrderTransitions[purchaseOrder.Status] {
+			allowed = allowed || next == status
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidPurchaseOrderTransition, purchaseOrder.Status, status)
+		}
+
+		purchaseOrder.Status = status
+		return tx.Model(&purchaseOrder).Update("status", status).Error
+	})
+
+	return purchaseOrder, err
+}
+
+// ReceivePurchaseOrder records goods received on a purchase order and adds them to the
+// stock of their variants in one transaction. The purchase order becomes received once
+// every line is complete, and partially received before that.
+func ReceivePurchaseOrder(db *gorm.DB, purchaseOrderUUID string, items []PurchaseOrderItem) (models.PurchaseOrder, error) {
+	var purchaseOrder models.PurchaseOrder
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the purchase order so concurrent receipts cannot exceed the ordered quantities
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").Where("uuid = ?", purchaseOrderUUID).First(&purchaseOrder).Error; err != nil {
+			return err
+		}
+
+		if purchaseOrder.Status != models.PurchaseOrderStatusOrdered && purchaseOrder.Status != models.PurchaseOrderStatusPartiallyReceived {
+			return ErrPurchaseOrderNotReceivable
+		}
+
+		linesByVariant := make(map[string]*models.PurchaseOrderLine, len(purchaseOrder.Lines))
+		for i := range purchaseOrder.Lines {
+			linesByVariant[purchaseOrder.Lines[i].VariantUUID.String()] = &purchaseOrder.Lines[i]
+		}
+
+		for _, item := range items {
+			line, ok := linesByVariant[item.VariantUUID]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrPurchaseOrderLineNotFound, item.VariantUUID)
+			}
+
+			if line.ReceivedQuantity+item.Quantity > line.OrderedQuantity {
+				return fmt.Errorf("%w: %s has %d outstanding", ErrReceiveQuantity, item.VariantUUID, line.OrderedQuantity-line.ReceivedQuantity)
+			}
+
+			if _, err := IncrementStock(tx, item.VariantUUID, item.Quantity); err != nil {
+				return err
+			}
+
+			line.ReceivedQuantity += item.Quantity
+			if err := tx.Model(line).Update("received_quantity", line.ReceivedQuantity).Error; err != nil {
+				return err
+			}
+		}
+
+		status := models.PurchaseOrderStatusReceived
+		for _, line := range purchaseOrder.Lines {
+			if line.ReceivedQuantity < line.OrderedQuantity {
+				status = models.PurchaseOrderStatusPartiallyReceived
+				break
+			}
+		}
+
+		purchaseOrder.Status = status
+		return tx.Model(&purchaseOrder).Update("status", status).Error
+	})
+
+	return purchaseOrder, err
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrOrderNotReturnable is returned when a return is requested for an order that was not paid.
+	ErrOrderNotReturnable = errors.New("Only paid or shipped orders can be returned")
+	// ErrOrderLineNotFound is returned when a return references a line of another order.
+	ErrOrderLineNotFound = errors.New("Order line not found")
+	// ErrReturnQuantity is returned when more units are returned than were sold.
+	ErrReturnQuantity = errors.New("Return quantity exceeds the quantity left to return")
+	// ErrInvalidReturnTransition is returned when a return cannot move to the requested status.
+	ErrInvalidReturnTransition = errors.New("Invalid return status transition")
+	// ErrOrderCancelled is returned when a return of a cancelled order is received, as its
+	// units were put back in stock by the cancellation.
+	ErrOrderCancelled = errors.New("The order was cancelled and its units are already back in stock")
+	// ErrRefundAmount is returned when a refund is negative or larger than the returned value.
+	ErrRefundAmount = errors.New("Refund amount must be between 0 and the value of the returned units")
+)
+
+// returnTransitions lists the statuses each return status can move to.
+var returnTransitions = map[string][]string{
+	models.ReturnStatusRequested: {models.ReturnStatusApproved, models.ReturnStatusRejected},
+	models.ReturnStatusApproved:  {models.ReturnStatusReceived, models.ReturnStatusRejected},
+	models.ReturnStatusReceived:  {models.ReturnStatusRefunded},
+}
+
+// CanTransitionReturn reports whether a return in status from can move to status to.
+func CanTransitionReturn(from, to string) bool {
+	for _, status := range returnTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestReturn records a return for units of an order line. The units already covered by
+// other returns that were not rejected cannot be returned again.
+func RequestReturn(db *gorm.DB, orderUUID string, orderLineUUID string, quantity uint, reason string) (models.Return, error) {
+	var ret models.Return
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the order so concurrent requests cannot return the same units twice
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("uuid = ?", orderUUID).First(&order).Error; err != nil {
+			return err
+		}
+
+		if order.Status != models.OrderStatusPaid && order.Status != models.OrderStatusShipped {
+			return ErrOrderNotReturnable
+		}
+
+		var line models.OrderLine
+		if err := tx.Where("uuid = ? AND order_uuid = ?", orderLineUUID, orderUUID).First(&line).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderLineNotFound
+			}
+			return err
+		}
+
+		var returned int64
+		if err := tx.Model(&models.Return{}).
+			Where("order_line_uuid = ? AND status <> ?", orderLineUUID, models.ReturnStatusRejected).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&returned).Error; err != nil {
+			return err
+		}
+
+		if int64(quantity) > int64(line.Quantity)-returned {
+			return fmt.Errorf("%w: %d left", ErrReturnQuantity, int64(line.Quantity)-returned)
+		}
+
+		ret = models.Return{
+			OrderUUID:     uuid.MustParse(order.UUID),
+			OrderLineUUID: uuid.MustParse(line.UUID),
+			VariantUUID:   line.VariantUUID,
+			Quantity:      quantity,
+			Reason:        reason,
+			Status:        models.ReturnStatusRequested,
+			AdminUUID:     order.AdminUUID,
+		}
+		return tx.Create(&ret).Error
+	})
+
+	return ret, err
+}
+
+// TransitionReturn moves a return to a new status. Receiving a return puts its units back
+// in stock, unless its order was cancelled, and refunding it records refundAmount, or the full value of the returned units
+// when refundAmount is nil.
+func TransitionReturn(db *gorm.DB, returnUUID string, status string, refundAmount *float64) (models.Return, error) {
+	var ret models.Return
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the return so concurrent transitions cannot both restock it
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("uuid = ?", returnUUID).First(&ret).Error; err != nil {
+			return err
+		}
+
+		if !CanTransitionReturn(ret.Status, status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidReturnTransition, ret.Status, status)
+		}
+
+		updates := map[string]interface{}{"status": status}
+
+		switch status {
+		case models.ReturnStatusReceived:
+			// Lock the order so it cannot be cancelled, restocking the same units, meanwhile
+			var order models.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("uuid = ?", ret.OrderUUID).First(&order).Error; err != nil {
+				return err
+			}
+			if order.Status == models.OrderStatusCancelled {
+				return ErrOrderCancelled
+			}
+
+			var line models.OrderLine
+			if err := tx.Where("uuid = ?", ret.OrderLineUUID).First(&line).Error; err != nil {
+				return err
+			}
+			if err := RestockOrderLine(tx, line, ret.Quantity); err != nil {
+				return err
+			}
+		case models.ReturnStatusRefunded:
+			var line models.OrderLine
+			if err := tx.Where("uuid = ?", ret.OrderLineUUID).First(&line).Error; err != nil {
+				return err
+			}
+
+			// The returned units are worth their share of the line after its discount
+			maxRefund := RoundPrice(line.Total*float64(ret.Quantity)/float64(line.Quantity), helpers.EnvBaseCurrency())
+			amount := maxRefund
+			if refundAmount != nil {
+				amount = *refundAmount
+			}
+			if amount < 0 || amount > maxRefund {
+				return ErrRefundAmount
+			}
+
+			ret.RefundAmount = amount
+			updates["refund_amount"] = amount
+		}
+
+		ret.Status = status
+		return tx.Model(&ret).Updates(updates).Error
+	})
+
+	return ret, err
+}
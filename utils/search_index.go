@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"basictrade/helpers"
+	"basictrade/models"
+	"basictrade/search"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// reindexBatchSize is how many products are loaded and indexed at a time when reindexing.
+const reindexBatchSize = 200
+
+// StartSearch opens the search index at SEARCH_INDEX_PATH, in memory when it is unset, and
+// rebuilds it from the database in the background.
+func StartSearch() {
+	index, err := search.NewBleveIndex(helpers.EnvSearchIndexPath())
+	if err != nil {
+		log.Fatal("error opening search index: ", err)
+	}
+	search.SetDefault(index)
+
+	go func() {
+		if err := ReindexAll(GetDB()); err != nil {
+			log.Println("Failed to build the search index:", err)
+		}
+	}()
+}
+
+// ProductDocuments builds the search documents of a product and of its variants. The
+// product must be loaded with its tags and its variants' attributes.
+func ProductDocuments(product models.Product) []search.Document {
+	tags := make([]string, 0, len(product.Tags))
+	for _, tag := range product.Tags {
+		tags = append(tags, tag.Name)
+	}
+
+	docs := []search.Document{{
+		Type:        search.TypeProduct,
+		UUID:        product.UUID,
+		AdminUUID:   product.AdminUUID.String(),
+		ProductUUID: product.UUID,
+		Name:        product.ProductName,
+		Description: product.Description,
+		Tags:        tags,
+	}}
+
+	for _, variant := range product.Variants {
+		attributes := make([]string, 0, len(variant.Attributes))
+		for _, attribute := range variant.Attributes {
+			attributes = append(attributes, attribute.Value)
+		}
+
+		docs = append(docs, search.Document{
+			Type:        search.TypeVariant,
+			UUID:        variant.UUID,
+			AdminUUID:   product.AdminUUID.String(),
+			ProductUUID: product.UUID,
+			Name:        product.ProductName + " " + variant.VariantName,
+			SKU:         variant.SKU,
+			Barcode:     variant.Barcode,
+			Tags:        tags,
+			Attributes:  attributes,
+		})
+	}
+
+	return docs
+}
+
+// IndexProduct indexes a product and its variants.
+func IndexProduct(db *gorm.DB, productUUID string) error {
+	index := search.Default()
+	if index == nil {
+		return nil
+	}
+
+	var product models.Product
+	if err := db.Preload("Variants.Attributes").Preload("Tags").Where("uuid = ?", productUUID).First(&product).Error; err != nil {
+		return err
+	}
+
+	return index.Index(ProductDocuments(product)...)
+}
+
+// RemoveFromSearch deletes products or variants, given by type and UUIDs, from the search index.
+func RemoveFromSearch(docType string, uuids ...string) error {
+	index := search.Default()
+	if index == nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(uuids))
+	for _, docUUID := range uuids {
+		ids = append(ids, search.DocumentID(docType, docUUID))
+	}
+	return index.Delete(ids...)
+}
+
+// RemoveProductFromSearch deletes a product and every variant indexed under it from the search
+// index, including variants whose own deletion was never reported.
+func RemoveProductFromSearch(adminUUID uuid.UUID, productUUID string) error {
+	index := search.Default()
+	if index == nil {
+		return nil
+	}
+
+	ids := []string{search.DocumentID(search.TypeProduct, productUUID)}
+	for from := 0; ; from += reindexBatchSize {
+		result, err := index.Search(search.Query{AdminUUID: adminUUID.String(), ProductUUID: productUUID, From: from, Size: reindexBatchSize})
+		if err != nil {
+			return err
+		}
+		if len(result.Hits) == 0 {
+			break
+		}
+		for _, hit := range result.Hits {
+			ids = append(ids, search.DocumentID(hit.Type, hit.UUID))
+		}
+	}
+	return index.Delete(ids...)
+}
+
+// indexEvent keeps the search index in step with the catalog. It subscribes the index to the
+// event bus; the database is the source of truth, so a product is indexed as it is now
+// rather than as the event reports it.
+func indexEvent(db *gorm.DB, event models.OutboxEvent) error {
+	switch event.Type {
+	case models.EventProductCreated, models.EventProductUpdated, models.EventVariantCreated, models.EventVariantUpdated:
+		err := IndexProduct(db, event.ProductUUID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted since, a later event removes it
+			return nil
+		}
+		return err
+	case models.EventProductDeleted:
+		return RemoveProductFromSearch(event.AdminUUID, event.ProductUUID)
+	case models.EventVariantDeleted:
+		return RemoveFromSearch(search.TypeVariant, event.VariantUUID)
+	default:
+		return nil
+	}
+}
+
+// ReindexAll indexes every product and variant.
+func ReindexAll(db *gorm.DB) error {
+	return reindexProducts(db.Model(&models.Product{}))
+}
+
+// ReindexCatalog indexes every product and variant of the admin and removes the documents of
+// the catalog that no longer exist.
+func ReindexCatalog(db *gorm.DB, adminUUID uuid.UUID) (int, error) {
+	index := search.Default()
+	if index == nil {
+		return 0, nil
+	}
+
+	if err := reindexProducts(db.Model(&models.Product{}).Where("admin_uuid = ?", adminUUID)); err != nil {
+		return 0, err
+	}
+
+	// Collect the indexed documents of the catalog and delete those without a row
+	var stale []string
+	for from := 0; ; from += reindexBatchSize {
+		result, err := index.Search(search.Query{AdminUUID: adminUUID.String(), From: from, Size: reindexBatchSize})
+		if err != nil {
+			return 0, err
+		}
+		if len(result.Hits) == 0 {
+			break
+		}
+
+		var productUUIDs, variantUUIDs []string
+		for _, hit := range result.Hits {
+			if hit.Type == search.TypeProduct {
+				productUUIDs = append(productUUIDs, hit.UUID)
+			} else {
+				variantUUIDs = append(variantUUIDs, hit.UUID)
+			}
+		}
+
+		missingProducts, err := missingDocuments(db, &models.Product{}, search.TypeProduct, productUUIDs)
+		if err != nil {
+			return 0, err
+		}
+		missingVariants, err := missingDocuments(db, &models.Variant{}, search.TypeVariant, variantUUIDs)
+		if err != nil {
+			return 0, err
+		}
+		stale = append(stale, missingProducts...)
+		stale = append(stale, missingVariants...)
+	}
+
+	if len(stale) > 0 {
+		if err := index.Delete(stale...); err != nil {
+			return 0, err
+		}
+	}
+
+	var indexed int64
+	if err := db.Model(&models.Product{}).Where("admin_uuid = ?", adminUUID).Count(&indexed).Error; err != nil {
+		return 0, err
+	}
+	return int(indexed), nil
+}
+
+// reindexSearchJob rebuilds the search documents of the catalog of the job's admin, or of
+// every catalog for a system job.
+func reindexSearchJob(ctx context.Context, db *gorm.DB, job models.Job) error {
+	if job.AdminUUID == nil {
+		return ReindexAll(db)
+	}
+
+	indexed, err := ReindexCatalog(db, *job.AdminUUID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Reindexed %d products of admin %s\n", indexed, job.AdminUUID)
+	return nil
+}
+
+// reindexProducts indexes the products selected by query, a batch at a time.
+func reindexProducts(query *gorm.DB) error {
+	index := search.Default()
+	if index == nil {
+		return nil
+	}
+
+	var products []models.Product
+	return query.Preload("Variants.Attributes").Preload("Tags").FindInBatches(&products, reindexBatchSize, func(tx *gorm.DB, batch int) error {
+		var docs []search.Document
+		for _, product := range products {
+			docs = append(docs, ProductDocuments(product)...)
+		}
+		return index.Index(docs...)
+	}).Error
+}
+
+// missingDocuments returns the index IDs of the UUIDs that have no row in the table of model.
+func missingDocuments(db *gorm.DB, model interface{}, docType string, uuids []string) ([]string, error) {
+	if len(uuids) == 0 {
+		return nil, nil
+	}
+
+	var existing []string
+	if err := db.Model(model).Where("uuid IN ?", uuids).Pluck("uuid", &existing).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(existing))
+	for _, docUUID := range existing {
+		found[docUUID] = true
+	}
+
+	var missing []string
+	for _, docUUID := range uuids {
+		if !found[docUUID] {
+			missing = append(missing, search.DocumentID(docType, docUUID))
+		}
+	}
+	return missing, nil
+}
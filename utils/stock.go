@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"basictrade/models"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientStock is matched by StockError with errors.Is.
+var ErrInsufficientStock = errors.New("Insufficient stock")
+
+// StockError reports a variant that does not have enough stock for a requested quantity.
+type StockError struct {
+	VariantUUID string
+	Requested   uint
+	Available   uint
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("Insufficient stock for variant %s: requested %d, available %d", e.VariantUUID, e.Requested, e.Available)
+}
+
+// Is makes errors.Is(err, ErrInsufficientStock) match a StockError.
+func (e *StockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// LockVariant loads a variant and locks its row until the transaction ends.
+func LockVariant(tx *gorm.DB, variantUUID string) (models.Variant, error) {
+	var variant models.Variant
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("uuid = ?", variantUUID).First(&variant).Error
+	return variant, err
+}
+
+// LockOrderStock locks the variants and the components of those that are bundles in one pass
+// sorted by UUID, so orders sharing variants or components always lock them in the same order
+// and cannot deadlock. It returns the locked variants by UUID, leaving out those that do not
+// exist, and the components of each bundle. It must run inside a transaction.
+func LockOrderStock(tx *gorm.DB, variantUUIDs []string) (map[string]models.Variant, map[string][]models.BundleComponent, error) {
+	var components []models.BundleComponent
+	if len(variantUUIDs) > 0 {
+		if err := tx.Where("bundle_variant_uuid IN ?", variantUUIDs).Order("component_variant_uuid").Find(&components).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	lockUUIDs := append([]string(nil), variantUUIDs...)
+	componentsByBundle := make(map[string][]models.BundleComponent)
+	for _, component := range components {
+		bundleUUID := component.BundleVariantUUID.String()
+		componentsByBundle[bundleUUID] = append(componentsByBundle[bundleUUID], component)
+		lockUUIDs = append(lockUUIDs, component.ComponentVariantUUID.String())
+	}
+	sort.Strings(lockUUIDs)
+
+	stock := make(map[string]models.Variant, len(lockUUIDs))
+	for _, variantUUID := range lockUUIDs {
+		if _, ok := stock[variantUUID]; ok {
+			continue
+		}
+		variant, err := LockVariant(tx, variantUUID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		stock[variantUUID] = variant
+	}
+
+	return stock, componentsByBundle, nil
+}
+
+// DecrementStock removes quantity from the stock of a variant locked with LockOrderStock,
+// failing with a StockError when there is not enough, and keeps stock in step. Selling a
+// bundle removes its components from stock instead, and returns the bundle with the
+// components it took. It must run inside a transaction.
+func DecrementStock(tx *gorm.DB, variantUUID string, quantity uint, stock map[string]models.Variant, components map[string][]models.BundleComponent) (models.Variant, error) {
+	variant := stock[variantUUID]
+
+	if bundleComponents := components[variantUUID]; len(bundleComponents) > 0 {
+		// A deleted component leaves nothing to assemble
+		variant.Quantity = BundleAvailability(bundleComponents, stock)
+		if variant.Quantity < quantity {
+			return variant, &StockError{VariantUUID: variantUUID, Requested: quantity, Available: variant.Quantity}
+		}
+
+		for _, component := range bundleComponents {
+			componentUUID := component.ComponentVariantUUID.String()
+			componentVariant := stock[componentUUID]
+			previousQuantity := componentVariant.Quantity
+			componentVariant.Quantity -= quantity * component.Quantity
+			if err := tx.Model(&componentVariant).UpdateColumn("quantity", componentVariant.Quantity).Error; err != nil {
+				return variant, err
+			}
+			if err := RecordStockChange(tx, componentVariant, previousQuantity); err != nil {
+				return variant, err
+			}
+			stock[componentUUID] = componentVariant
+		}
+
+		variant.Quantity -= quantity
+		variant.Components = bundleComponents
+		return variant, nil
+	}
+
+	if variant.Quantity < quantity {
+		return variant, &StockError{VariantUUID: variantUUID, Requested: quantity, Available: variant.Quantity}
+	}
+
+	variant.Quantity -= quantity
+	if err := tx.Model(&variant).UpdateColumn("quantity", variant.Quantity).Error; err != nil {
+		return variant, err
+	}
+	if err := RecordStockChange(tx, variant, variant.Quantity+quantity); err != nil {
+		return variant, err
+	}
+	stock[variantUUID] = variant
+
+	return variant, nil
+}
+
+// IncrementStock adds quantity to the stock of a variant, or of the components of a bundle.
+// It must run inside a transaction.
+func IncrementStock(tx *gorm.DB, variantUUID string, quantity uint) (models.Variant, error) {
+	variant, err := LockVariant(tx, variantUUID)
+	if err != nil {
+		return variant, err
+	}
+
+	var components []models.BundleComponent
+	if err := tx.Where("bundle_variant_uuid = ?", variantUUID).Order("component_variant_uuid").Find(&components).Error; err != nil {
+		return variant, err
+	}
+	if len(components) > 0 {
+		for _, component := range components {
+			// Components deleted since the sale have no stock to restore
+			if _, err := IncrementStock(tx, component.ComponentVariantUUID.String(), quantity*component.Quantity); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return variant, err
+			}
+		}
+		return variant, nil
+	}
+
+	return incrementVariantStock(tx, variant, quantity)
+}
+
+// IncrementVariantStock adds quantity to the stock of the variant itself, even when it is a
+// bundle. It must run inside a transaction.
+func IncrementVariantStock(tx *gorm.DB, variantUUID string, quantity uint) (models.Variant, error) {
+	variant, err := LockVariant(tx, variantUUID)
+	if err != nil {
+		return variant, err
+	}
+	return incrementVariantStock(tx, variant, quantity)
+}
+
+// incrementVariantStock adds quantity to the stock of a locked variant.
+func incrementVariantStock(tx *gorm.DB, variant models.Variant, quantity uint) (models.Variant, error) {
+	variant.Quantity += quantity
+	if err := tx.Model(&variant).UpdateColumn("quantity", variant.Quantity).Error; err != nil {
+		return variant, err
+	}
+	if err := RecordStockChange(tx, variant, variant.Quantity-quantity); err != nil {
+		return variant, err
+	}
+
+	return variant, nil
+}
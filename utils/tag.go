@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"basictrade/models"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxTagLength is the longest a tag name can be.
+const MaxTagLength = 50
+
+// ErrInvalidTag is returned when a tag name is empty or too long.
+var ErrInvalidTag = errors.New("Tags must be between 1 and 50 characters")
+
+// NormalizeTags trims and lower-cases tag names and removes duplicates, keeping their order.
+func NormalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	tags := make([]string, 0, len(names))
+
+	for _, name := range names {
+		tag := strings.ToLower(strings.TrimSpace(name))
+		if tag == "" || len(tag) > MaxTagLength {
+			return nil, ErrInvalidTag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+// SplitTags splits a comma separated list of tag names, ignoring empty entries.
+func SplitTags(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if strings.TrimSpace(name) != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// FindOrCreateTags returns the admin's tags with the given normalized names, creating the missing ones.
+func FindOrCreateTags(db *gorm.DB, adminUUID uuid.UUID, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, models.Tag{Name: name, AdminUUID: adminUUID})
+	}
+
+	// Concurrent requests may create the same tag, so existing ones are left untouched
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var existing []models.Tag
+	if err := db.Where("admin_uuid = ? AND name IN ?", adminUUID, names).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// TaggedProducts returns a query selecting the UUIDs of the products tagged with any of the
+// names, or with all of them when matchAll is set.
+func TaggedProducts(db *gorm.DB, names []string, matchAll bool) *gorm.DB {
+	query := db.Table("product_tags").
+		Select("product_tags.product_uuid").
+		Joins("JOIN tags ON tags.uuid = product_tags.tag_uuid").
+		Where("tags.name IN ?", names)
+
+	if matchAll {
+		query = query.Group("product_tags.product_uuid").Having("COUNT(DISTINCT tags.name) = ?", len(names))
+	}
+
+	return query
+}
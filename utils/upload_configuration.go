@@ -2,10 +2,10 @@ package utils
 
 import (
 	"basictrade/helpers"
+	"basictrade/models"
 	"bytes"
 	"context"
 	"errors"
-	"fmt"
 	"io"
 	"mime/multipart"
 	"path"
@@ -14,6 +14,7 @@ import (
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"gorm.io/gorm"
 )
 
 const (
@@ -21,67 +22,118 @@ const (
 	MaxFileSize = 2 * 1024 * 1024
 )
 
-func UploadFile(fileHeader *multipart.FileHeader, fileName string) (string, error) {
-	c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// imageUploadTimeout bounds how long Cloudinary has to store an image.
+const imageUploadTimeout = time.Minute
+
+// ProductImageUpload is the payload of a job uploading the image of a product. The image is
+// stored in the job blob BlobUUID.
+type ProductImageUpload struct {
+	ProductUUID string `json:"product_uuid"`
+	FileName    string `json:"file_name"`
+	BlobUUID    string `json:"blob_uuid"`
+}
 
+// ReadImageFile checks that an uploaded file is an image of at most MaxFileSize and reads it.
+func ReadImageFile(fileHeader *multipart.FileHeader) ([]byte, error) {
 	// Check if the file size exceeds the maximum allowed size
 	if fileHeader.Size > MaxFileSize {
-		return "", errors.New("File size exceeds the maximum allowed size!")
+		return nil, errors.New("File size exceeds the maximum allowed size!")
 	}
 
 	// Check if the file is an image based on its content type
 	if !isImageFile(fileHeader.Header.Get("Content-Type")) {
-		return "", errors.New("File is not an image!")
+		return nil, errors.New("File is not an image!")
 	}
 
-	// Add Cloudinary product environment credentials.
-	cld, err := cloudinary.NewFromParams(helpers.EnvCloudName(), helpers.EnvCloudAPIKey(), helpers.EnvCloudAPISecret())
+	file, err := fileHeader.Open()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	fmt.Println(helpers.EnvCloudName())
+	defer file.Close()
 
-	// Convert file
-	fileReader, err := convertFile(fileHeader)
+	return io.ReadAll(io.LimitReader(file, MaxFileSize+1))
+}
+
+// UploadImage uploads an image to Cloudinary under fileName and returns its URL.
+func UploadImage(c context.Context, data []byte, fileName string) (string, error) {
+	// Add Cloudinary product environment credentials.
+	cld, err := cloudinary.NewFromParams(helpers.EnvCloudName(), helpers.EnvCloudAPIKey(), helpers.EnvCloudAPISecret())
 	if err != nil {
 		return "", err
 	}
 
 	// Upload file
-	uploadParam, err := cld.Upload.Upload(c, fileReader, uploader.UploadParams{
+	uploadParam, err := cld.Upload.Upload(c, bytes.NewReader(data), uploader.UploadParams{
 		PublicID: fileName,
 		Folder:   helpers.EnvCloudUploadFolder(),
 	})
 	if err != nil {
 		return "", err
 	}
+	if uploadParam.Error.Message != "" {
+		return "", errors.New(uploadParam.Error.Message)
+	}
 
 	return uploadParam.SecureURL, nil
 }
 
-func convertFile(fileHeader *multipart.FileHeader) (*bytes.Reader, error) {
-    if fileHeader == nil {
-        return nil, errors.New("File header is nil.")
-    }
-
-    file, err := fileHeader.Open()
-    if err != nil {
-        return nil, err
-    }
-    defer file.Close()
-
-    // Read the file content into an in-memory buffer
-    buffer := new(bytes.Buffer)
-    if _, err := io.Copy(buffer, file); err != nil {
-        return nil, err
-    }
-
-    // Create a bytes.Reader from the buffer
-    fileReader := bytes.NewReader(buffer.Bytes())
-    return fileReader, nil
+// EnqueueProductImageUpload queues the upload of an image read with ReadImageFile, which
+// becomes the image of the product once it is stored.
+func EnqueueProductImageUpload(tx *gorm.DB, product models.Product, fileName string, data []byte) (models.Job, error) {
+	blob := models.JobBlob{Data: data}
+	if err := tx.Create(&blob).Error; err != nil {
+		return models.Job{}, err
+	}
+
+	return EnqueueJob(tx, models.JobUploadProductImage, &product.AdminUUID, ProductImageUpload{
+		ProductUUID: product.UUID,
+		FileName:    fileName,
+		BlobUUID:    blob.UUID,
+	}, time.Time{})
 }
 
+// uploadProductImageJob uploads the image of a product and sets it as the product's image,
+// with the event reporting the change, then deletes the stored image. A product deleted
+// meanwhile is left alone.
+func uploadProductImageJob(ctx context.Context, db *gorm.DB, job models.Job) error {
+	var upload ProductImageUpload
+	if err := DecodeJobPayload(job, &upload); err != nil {
+		return err
+	}
+
+	var blob models.JobBlob
+	if err := db.Where("uuid = ?", upload.BlobUUID).First(&blob).Error; err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, imageUploadTimeout)
+	defer cancel()
+
+	imageURL, err := UploadImage(ctx, blob.Data, upload.FileName)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&blob).Error; err != nil {
+			return err
+		}
+
+		var product models.Product
+		if err := tx.Where("uuid = ?", upload.ProductUUID).First(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		product.ImageURL = imageURL
+		if err := tx.Model(&product).Update("image_url", imageURL).Error; err != nil {
+			return err
+		}
+		return RecordProductEvent(tx, models.EventProductUpdated, product)
+	})
+}
 
 func RemoveExtension(filename string) string {
 	return path.Base(filename[:len(filename)-len(path.Ext(filename))])
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"basictrade/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxVariantCombinations bounds how many variants one generator call can create.
+const MaxVariantCombinations = 500
+
+var (
+	// ErrInvalidOption is returned when a product option has no name or no values.
+	ErrInvalidOption = errors.New("Options need a name and at least one value")
+	// ErrInvalidAttribute is returned when a variant attribute does not match the product options.
+	ErrInvalidAttribute = errors.New("Attribute does not match the product options")
+	// ErrTooManyCombinations is returned when the options would generate too many variants.
+	ErrTooManyCombinations = errors.New("Options generate too many variants")
+)
+
+// NormalizeOptionValues trims option values and removes empty and duplicate ones, keeping their order.
+func NormalizeOptionValues(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		key := strings.ToLower(value)
+		if value != "" && !seen[key] {
+			seen[key] = true
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized
+}
+
+// ValidateVariantAttributes checks that every attribute names an option of the product and
+// holds one of its values. It returns the attributes using the option's spelling.
+func ValidateVariantAttributes(options []models.ProductOption, attributes map[string]string) (map[string]string, error) {
+	validated := make(map[string]string, len(attributes))
+
+	for name, value := range attributes {
+		var option *models.ProductOption
+		for i := range options {
+			if strings.EqualFold(options[i].Name, strings.TrimSpace(name)) {
+				option = &options[i]
+				break
+			}
+		}
+		if option == nil {
+			return nil, fmt.Errorf("%w: unknown option %s", ErrInvalidAttribute, name)
+		}
+
+		found := false
+		for _, allowed := range option.Values {
+			if strings.EqualFold(allowed, strings.TrimSpace(value)) {
+				validated[option.Name] = allowed
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s is not a value of %s", ErrInvalidAttribute, value, option.Name)
+		}
+	}
+
+	return validated, nil
+}
+
+// VariantCombinations returns every combination of the option values, the first option
+// varying slowest.
+func VariantCombinations(options []models.ProductOption) ([]map[string]string, error) {
+	total := 1
+	for _, option := range options {
+		if option.Name == "" || len(option.Values) == 0 {
+			return nil, ErrInvalidOption
+		}
+		total *= len(option.Values)
+		if total > MaxVariantCombinations {
+			return nil, ErrTooManyCombinations
+		}
+	}
+
+	combinations := []map[string]string{{}}
+	for _, option := range options {
+		next := make([]map[string]string, 0, len(combinations)*len(option.Values))
+		for _, combination := range combinations {
+			for _, value := range option.Values {
+				extended := make(map[string]string, len(combination)+1)
+				for name, existing := range combination {
+					extended[name] = existing
+				}
+				extended[option.Name] = value
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+
+	return combinations, nil
+}
+
+// VariantCombinationName joins the attribute values in option order, e.g. "Red / XL".
+func VariantCombinationName(options []models.ProductOption, attributes map[string]string) string {
+	parts := make([]string, 0, len(attributes))
+	for _, option := range options {
+		if value, ok := attributes[option.Name]; ok {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, " / ")
+}
+
+// VariantAttributeKey returns a case-insensitive key identifying a set of attributes.
+func VariantAttributeKey(attributes map[string]string) string {
+	pairs := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		pairs = append(pairs, strings.ToLower(name)+"="+strings.ToLower(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "|")
+}
+
+// CreateVariantWithAttributes creates a variant and its attributes, owned by the admin of
+// its product.
+func CreateVariantWithAttributes(tx *gorm.DB, variant *models.Variant, attributes map[string]string) error {
+	var product models.Product
+	if err := tx.Select("admin_uuid").Where("uuid = ?", variant.ProductUUID).First(&product).Error; err != nil {
+		return err
+	}
+	variant.AdminUUID = product.AdminUUID
+
+	if err := tx.Omit(clause.Associations).Create(variant).Error; err != nil {
+		return VariantIdentifierError(err, *variant)
+	}
+	return ReplaceVariantAttributes(tx, variant, attributes)
+}
+
+// ReplaceVariantAttributes replaces the attributes of a variant, sorted by name.
+func ReplaceVariantAttributes(tx *gorm.DB, variant *models.Variant, attributes map[string]string) error {
+	variantUUID := uuid.MustParse(variant.UUID)
+
+	if err := tx.Where("variant_uuid = ?", variantUUID).Delete(&models.VariantAttribute{}).Error; err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	variant.Attributes = make([]models.VariantAttribute, 0, len(names))
+	for _, name := range names {
+		variant.Attributes = append(variant.Attributes, models.VariantAttribute{
+			VariantUUID: variantUUID,
+			Name:        name,
+			Value:       attributes[name],
+		})
+	}
+
+	if len(variant.Attributes) == 0 {
+		return nil
+	}
+	return tx.Create(&variant.Attributes).Error
+}
+
+// VariantsWithAttribute returns a query selecting the UUIDs of the variants having an attribute value.
+func VariantsWithAttribute(db *gorm.DB, name string, value string) *gorm.DB {
+	return db.Model(&models.VariantAttribute{}).Select("variant_uuid").Where("name = ? AND value = ?", name, value)
+}
+
+// PruneVariantAttributes drops the attributes of the product's variants that no longer match
+// its options, after the options changed, and records an event for each variant changed. The
+// remaining attributes take the options' spelling.
+func PruneVariantAttributes(tx *gorm.DB, adminUUID uuid.UUID, productUUID uuid.UUID, options []models.ProductOption) error {
+	var variants []models.Variant
+	if err := tx.Preload("Attributes").Where("product_uuid = ?", productUUID).Find(&variants).Error; err != nil {
+		return err
+	}
+
+	for _, variant := range variants {
+		kept := make(map[string]string, len(variant.Attributes))
+		changed := false
+		for _, attribute := range variant.Attributes {
+			validated, err := ValidateVariantAttributes(options, map[string]string{attribute.Name: attribute.Value})
+			if err != nil {
+				changed = true
+				continue
+			}
+			for name, value := range validated {
+				if name != attribute.Name || value != attribute.Value {
+					changed = true
+				}
+				kept[name] = value
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if err := ReplaceVariantAttributes(tx, &variant, kept); err != nil {
+			return err
+		}
+		if err := RecordVariantEvent(tx, adminUUID, models.EventVariantUpdated, variant); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
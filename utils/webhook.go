@@ -0,0 +1,406 @@
+package utils
+
+import (
+	"basictrade/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// WebhookMaxAttempts is how many times a delivery is tried before it is marked failed.
+	WebhookMaxAttempts = 8
+	// webhookRetryDelay is the wait before the first retry, doubled after every failed attempt.
+	webhookRetryDelay = 30 * time.Second
+	// webhookMaxRetryDelay caps the wait between two attempts.
+	webhookMaxRetryDelay = 6 * time.Hour
+	// webhookTimeout bounds how long a receiver has to answer.
+	webhookTimeout = 10 * time.Second
+	// webhookBatchSize is how many due deliveries are sent on each tick.
+	webhookBatchSize = 50
+	// webhookResponseLimit is how much of a receiver's response is kept in the delivery log.
+	webhookResponseLimit = 4096
+)
+
+// Headers sent with every webhook delivery.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+var (
+	// ErrInvalidEventType is returned when a webhook subscribes to an unknown event type.
+	ErrInvalidEventType = errors.New("Invalid event type")
+	// ErrInvalidWebhookURL is returned when a webhook URL is not an http or https URL with a
+	// host that resolves.
+	ErrInvalidWebhookURL = errors.New("url must be an http or https URL with a resolvable host")
+	// ErrWebhookAddressBlocked is returned when a webhook URL points at a loopback, private or
+	// link-local address, which would let admins reach the internal network.
+	ErrWebhookAddressBlocked = errors.New("url must not point at a loopback, private or link-local address")
+)
+
+// webhookBlockedPrefixes are the ranges webhooks are not delivered to besides the loopback,
+// private, link-local, multicast and unspecified addresses.
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// WebhookEvent is the body posted to webhook receivers. Sequence increases with every event
+// recorded, so a receiver can drop an event about a product or variant older than one it
+// already handled, as deliveries are retried independently of each other.
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Sequence  uint        `json:"sequence"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookClient sends webhook deliveries.
+var webhookClient = NewWebhookClient()
+
+// NewWebhookClient returns a client that refuses to connect to the addresses blocked for
+// webhooks. The address is checked when dialing, after DNS resolution, so a host cannot pass
+// ValidateWebhookURL and then rebind to an internal address; redirects are checked the same
+// way. Proxies are not used, as the proxy would dial the receiver instead.
+func NewWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+	}
+}
+
+// webhookDialControl refuses the connections of webhook deliveries to blocked addresses.
+func webhookDialControl(network string, address string, conn syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if IsBlockedWebhookAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+// IsBlockedWebhookAddress reports whether webhooks may not be delivered to an address.
+func IsBlockedWebhookAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range webhookBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWebhookURL checks that a webhook URL uses http or https and that every address its
+// host resolves to may receive webhooks.
+func ValidateWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: cannot resolve %s", ErrInvalidWebhookURL, parsed.Hostname())
+	}
+	for _, addr := range addrs {
+		if IsBlockedWebhookAddress(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrWebhookAddressBlocked, parsed.Hostname(), addr.Unmap())
+		}
+	}
+	return nil
+}
+
+// NewWebhookSecret generates a random secret to sign the deliveries of a subscription with.
+func NewWebhookSecret() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buffer), nil
+}
+
+// ValidateEventTypes checks that every event type can be subscribed to.
+func ValidateEventTypes(events []string) error {
+	if len(events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidEventType)
+	}
+	for _, event := range events {
+		if !containsString(models.EventTypes, event) {
+			return fmt.Errorf("%w: %s", ErrInvalidEventType, event)
+		}
+	}
+	return nil
+}
+
+// QueueWebhookEvent queues a delivery of a domain event to each of the admin's active
+// subscriptions to its type. It subscribes the webhooks to the event bus, and skips the
+// subscriptions the event was already queued for when it is published again.
+func QueueWebhookEvent(db *gorm.DB, event models.OutboxEvent) error {
+	var subscriptions []models.WebhookSubscription
+	if err := db.Where("admin_uuid = ? AND active = ?", event.AdminUUID, true).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	var queued []string
+	if err := db.Model(&models.WebhookDelivery{}).Where("event_id = ? AND replay_of IS NULL", event.UUID).
+		Pluck("subscription_uuid", &queued).Error; err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(WebhookEvent{
+		ID:        event.UUID,
+		Sequence:  event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt.UTC(),
+		Data:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !containsString(subscription.Events, event.Type) || containsString(queued, subscription.UUID) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionUUID: uuid.MustParse(subscription.UUID),
+			EventID:          event.UUID,
+			EventType:        event.Type,
+			Payload:          string(payload),
+			Status:           models.WebhookDeliveryPending,
+			NextAttemptAt:    time.Now(),
+			AdminUUID:        event.AdminUUID,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return db.Create(&deliveries).Error
+}
+
+// SignWebhookPayload signs the timestamp and body of a delivery with the subscription's
+// secret, as sent in the X-Webhook-Signature header. Receivers recompute it over
+// "<timestamp>.<body>" to check a delivery.
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendWebhook posts a delivery to the subscription's URL and logs how the receiver answered.
+// Any 2xx status counts as success.
+func SendWebhook(client *http.Client, subscription models.WebhookSubscription, delivery models.WebhookDelivery) models.WebhookAttempt {
+	attempt := models.WebhookAttempt{
+		DeliveryUUID: uuid.MustParse(delivery.UUID),
+		Attempt:      delivery.Attempts + 1,
+	}
+
+	payload := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	request, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "basictrade-webhooks")
+	request.Header.Set(WebhookEventHeader, delivery.EventType)
+	request.Header.Set(WebhookDeliveryHeader, delivery.UUID)
+	request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(subscription.Secret, timestamp, payload))
+
+	start := time.Now()
+	response, err := client.Do(request)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, webhookResponseLimit))
+	attempt.StatusCode = response.StatusCode
+	attempt.ResponseBody = string(body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		attempt.Error = "Receiver answered " + response.Status
+	}
+	return attempt
+}
+
+// WebhookRetryDelay is the wait after a delivery's failed attempts before the next one.
+func WebhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryDelay
+	for i := 1; i < attempts && delay < webhookMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxRetryDelay {
+		delay = webhookMaxRetryDelay
+	}
+	return delay
+}
+
+// DeliverDueWebhooks sends the pending deliveries whose next attempt is due, and returns how
+// many were sent.
+func DeliverDueWebhooks(db *gorm.DB, client *http.Client, now time.Time) (int, error) {
+	var deliveries []models.WebhookDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("next_attempt_at").Limit(webhookBatchSize).Find(&deliveries).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, delivery := range deliveries {
+		// Claim the delivery so another instance does not send it at the same time
+		claim := db.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, models.WebhookDeliveryPending, delivery.NextAttemptAt).
+			Update("next_attempt_at", now.Add(2*webhookTimeout))
+		if claim.Error != nil {
+			return sent, claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+
+		if err := deliverWebhook(db, client, delivery, now); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// deliverWebhook makes one attempt at a delivery, logs it and schedules the next attempt
+// when it failed.
+func deliverWebhook(db *gorm.DB, client *http.Client, delivery models.WebhookDelivery, now time.Time) error {
+	var subscription models.WebhookSubscription
+	err := db.Where("uuid = ?", delivery.SubscriptionUUID).First(&subscription).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var attempt models.WebhookAttempt
+	if err != nil || !subscription.Active {
+		attempt = models.WebhookAttempt{
+			DeliveryUUID: uuid.MustParse(delivery.UUID),
+			Attempt:      delivery.Attempts + 1,
+			Error:        "Subscription is deleted or inactive",
+		}
+	} else {
+		attempt = SendWebhook(client, subscription, delivery)
+	}
+
+	updates := WebhookAttemptUpdates(attempt, err != nil || !subscription.Active, now)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error
+	})
+}
+
+// WebhookAttemptUpdates is how a delivery changes after an attempt: it succeeds on a 2xx
+// answer, fails once it runs out of attempts or when the subscription is gone, and is
+// otherwise tried again after WebhookRetryDelay.
+func WebhookAttemptUpdates(attempt models.WebhookAttempt, subscriptionGone bool, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{"attempts": attempt.Attempt}
+	switch {
+	case attempt.Error == "":
+		deliveredAt := time.Now()
+		updates["status"] = models.WebhookDeliverySucceeded
+		updates["delivered_at"] = &deliveredAt
+	case attempt.Attempt >= WebhookMaxAttempts || subscriptionGone:
+		updates["status"] = models.WebhookDeliveryFailed
+	default:
+		updates["next_attempt_at"] = now.Add(WebhookRetryDelay(attempt.Attempt))
+	}
+	return updates
+}
+
+// DeliverWebhooksEvery runs DeliverDueWebhooks on every tick of interval until stop is closed.
+func DeliverWebhooksEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := DeliverDueWebhooks(GetDB(), webhookClient, time.Now()); err != nil {
+			log.Println("Failed to deliver webhooks:", err)
+		}
+	}
+}
+
+// ReplayWebhookDelivery queues a delivery's event again for its subscription, keeping the
+// event ID so receivers can recognise it.
+func ReplayWebhookDelivery(db *gorm.DB, delivery models.WebhookDelivery) (models.WebhookDelivery, error) {
+	replay := NewWebhookReplay(delivery)
+	err := db.Create(&replay).Error
+	return replay, err
+}
+
+// NewWebhookReplay is a pending delivery of the same event as delivery, due now.
+func NewWebhookReplay(delivery models.WebhookDelivery) models.WebhookDelivery {
+	replayOf := uuid.MustParse(delivery.UUID)
+	return models.WebhookDelivery{
+		SubscriptionUUID: delivery.SubscriptionUUID,
+		EventID:          delivery.EventID,
+		EventType:        delivery.EventType,
+		Payload:          delivery.Payload,
+		Status:           models.WebhookDeliveryPending,
+		NextAttemptAt:    time.Now(),
+		ReplayOf:         &replayOf,
+		AdminUUID:        delivery.AdminUUID,
+	}
+}
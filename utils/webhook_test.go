@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"basictrade/models"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// webhookReceiver is a local receiver answering with the given statuses in turn, then 200.
+type webhookReceiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func (receiver *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+
+	receiver.requests = append(receiver.requests, receivedWebhook{header: r.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(receiver.statuses) > 0 {
+		status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+	}
+	w.WriteHeader(status)
+	w.Write([]byte("ok"))
+}
+
+func newTestDelivery(t *testing.T) (models.WebhookSubscription, models.WebhookDelivery) {
+	t.Helper()
+
+	eventID := uuid.New().String()
+	payload, err := json.Marshal(WebhookEvent{ID: eventID, Type: models.EventProductUpdated, CreatedAt: time.Now().UTC(), Data: map[string]string{"uuid": "p1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription := models.WebhookSubscription{UUID: uuid.New().String(), Secret: "whsec_test_secret_value", Active: true}
+	delivery := models.WebhookDelivery{
+		UUID:             uuid.New().String(),
+		SubscriptionUUID: uuid.MustParse(subscription.UUID),
+		EventID:          eventID,
+		EventType:        models.EventProductUpdated,
+		Payload:          string(payload),
+		Status:           models.WebhookDeliveryPending,
+	}
+	return subscription, delivery
+}
+
+func TestSendWebhookSignsDelivery(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	subscription, delivery := newTestDelivery(t)
+	subscription.URL = server.URL
+
+	attempt := SendWebhook(server.Client(), subscription, delivery)
+	if attempt.Error != "" || attempt.StatusCode != http.StatusOK || attempt.Attempt != 1 {
+		t.Fatalf("unexpected attempt %+v", attempt)
+	}
+	if len(receiver.requests) != 1 {
+		t.Fatalf("receiver got %d requests", len(receiver.requests))
+	}
+
+	request := receiver.requests[0]
+	if string(request.body) != delivery.Payload {
+		t.Errorf("body is %s", request.body)
+	}
+	if request.header.Get(WebhookEventHeader) != models.EventProductUpdated || request.header.Get(WebhookDeliveryHeader) != delivery.UUID {
+		t.Errorf("unexpected headers %v", request.header)
+	}
+
+	// Verify the signature the way a receiver would
+	timestamp := request.header.Get(WebhookTimestampHeader)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("invalid timestamp %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(subscription.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(request.body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(request.header.Get(WebhookSignatureHeader)), []byte(expected)) {
+		t.Errorf("signature is %q, expected %q", request.header.Get(WebhookSignatureHeader), expected)
+	}
+
+	// A different secret does not verify
+	other := SignWebhookPayload("whsec_other_secret_value", 0, request.body)
+	if other == expected {
+		t.Error("signatures of different secrets match")
+	}
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	subscription, delivery := newTestDelivery(t)
+	subscription.URL = server.URL
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	delays := []time.Duration{30 * time.Second, time.Minute}
+	for i, delay := range delays {
+		attempt := SendWebhook(server.Client(), subscription, delivery)
+		if attempt.Error == "" {
+			t.Fatalf("attempt %d succeeded", i+1)
+		}
+
+		updates := WebhookAttemptUpdates(attempt, false, now)
+		if _, ok := updates["status"]; ok {
+			t.Fatalf("attempt %d changed the status to %v", i+1, updates["status"])
+		}
+		if next := updates["next_attempt_at"].(time.Time); !next.Equal(now.Add(delay)) {
+			t.Errorf("attempt %d is retried at %s, expected %s", i+1, next, now.Add(delay))
+		}
+		delivery.Attempts = updates["attempts"].(int)
+	}
+
+	attempt := SendWebhook(server.Client(), subscription, delivery)
+	updates := WebhookAttemptUpdates(attempt, false, now)
+	if updates["status"] != models.WebhookDeliverySucceeded || updates["attempts"] != 3 {
+		t.Errorf("unexpected updates %v", updates)
+	}
+	if len(receiver.requests) != 3 {
+		t.Errorf("receiver got %d requests", len(receiver.requests))
+	}
+}
+
+func TestWebhookFailsWhenOutOfAttempts(t *testing.T) {
+	now := time.Now()
+
+	updates := WebhookAttemptUpdates(models.WebhookAttempt{Attempt: WebhookMaxAttempts, Error: "Receiver answered 500"}, false, now)
+	if updates["status"] != models.WebhookDeliveryFailed {
+		t.Errorf("last attempt left the delivery %v", updates["status"])
+	}
+
+	updates = WebhookAttemptUpdates(models.WebhookAttempt{Attempt: 1, Error: "Subscription is deleted or inactive"}, true, now)
+	if updates["status"] != models.WebhookDeliveryFailed {
+		t.Errorf("delivery to a deleted subscription is %v", updates["status"])
+	}
+
+	if delay := WebhookRetryDelay(20); delay != webhookMaxRetryDelay {
+		t.Errorf("retry delay is %s, expected the cap", delay)
+	}
+}
+
+func TestReplaySendsSameEvent(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	subscription, delivery := newTestDelivery(t)
+	subscription.URL = server.URL
+	delivery.Status = models.WebhookDeliveryFailed
+	delivery.Attempts = WebhookMaxAttempts
+
+	replay := NewWebhookReplay(delivery)
+	if replay.Status != models.WebhookDeliveryPending || replay.Attempts != 0 || replay.ReplayOf == nil || replay.ReplayOf.String() != delivery.UUID {
+		t.Fatalf("unexpected replay %+v", replay)
+	}
+	replay.UUID = uuid.New().String()
+
+	attempt := SendWebhook(server.Client(), subscription, replay)
+	if attempt.Error != "" || attempt.Attempt != 1 {
+		t.Fatalf("unexpected attempt %+v", attempt)
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(receiver.requests[0].body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != delivery.EventID {
+		t.Errorf("replayed event ID is %s, expected %s", event.ID, delivery.EventID)
+	}
+	if receiver.requests[0].header.Get(WebhookDeliveryHeader) != replay.UUID {
+		t.Error("replay is not sent as a new delivery")
+	}
+}
+
+func TestWebhookClientRefusesBlockedAddresses(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	subscription, delivery := newTestDelivery(t)
+	subscription.URL = server.URL
+
+	attempt := SendWebhook(NewWebhookClient(), subscription, delivery)
+	if attempt.Error == "" || len(receiver.requests) != 0 {
+		t.Fatalf("delivery to %s was not refused", server.URL)
+	}
+
+	_, err := NewWebhookClient().Get(server.URL)
+	if !errors.Is(err, ErrWebhookAddressBlocked) {
+		t.Errorf("error is %v", err)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://93.184.216.34/hooks", nil},
+		{"http://[2606:2800:220:1::]/hooks", nil},
+		{"ftp://93.184.216.34/hooks", ErrInvalidWebhookURL},
+		{"https:///hooks", ErrInvalidWebhookURL},
+		{"http://127.0.0.1:8080/hooks", ErrWebhookAddressBlocked},
+		{"http://localhost/hooks", ErrWebhookAddressBlocked},
+		{"http://169.254.169.254/latest/meta-data", ErrWebhookAddressBlocked},
+		{"http://10.0.0.5/hooks", ErrWebhookAddressBlocked},
+		{"http://172.16.3.4/hooks", ErrWebhookAddressBlocked},
+		{"http://192.168.1.1/hooks", ErrWebhookAddressBlocked},
+		{"http://100.64.0.1/hooks", ErrWebhookAddressBlocked},
+		{"http://0.0.0.0/hooks", ErrWebhookAddressBlocked},
+		{"http://[::1]/hooks", ErrWebhookAddressBlocked},
+		{"http://[fd00::1]/hooks", ErrWebhookAddressBlocked},
+		{"http://[::ffff:127.0.0.1]/hooks", ErrWebhookAddressBlocked},
+	}
+
+	for _, test := range tests {
+		err := ValidateWebhookURL(context.Background(), test.url)
+		if test.want == nil && err != nil || test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("ValidateWebhookURL(%q) = %v, expected %v", test.url, err, test.want)
+		}
+	}
+
+	if !IsBlockedWebhookAddress(netip.MustParseAddr("::ffff:10.1.2.3")) {
+		t.Error("IPv4-mapped private address is not blocked")
+	}
+}